@@ -0,0 +1,92 @@
+// Command gbbench measures the synthetic instruction mixes, and any ROMs
+// given, and compares them against a stored baseline.
+//
+//	gbbench [-frames n] [-baseline file] [-threshold f] [-update] [-blockcache] [rom...]
+//
+// ROMs are named after their file name without the extension. The exit code
+// is 1 when any of them got slower by more than -threshold, or started
+// allocating more, than in the baseline, and 2 on errors. With -update the
+// baseline is rewritten with the new results instead.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ifamakes/emu/pkg/bench"
+	"github.com/ifamakes/emu/pkg/hardware"
+)
+
+func main() {
+	frames := flag.Uint64("frames", 600, "frames to run each ROM for")
+	baseline := flag.String("baseline", "", "baseline JSON to compare against")
+	threshold := flag.Float64("threshold", 0.1, "allowed drop in throughput, as a fraction of the baseline")
+	update := flag.Bool("update", false, "write the results to -baseline instead of comparing")
+	blockCache := flag.Bool("blockcache", false, "run with the pre-decoded block cache")
+	flag.Parse()
+
+	roms := map[string][]byte{}
+	for name, rom := range bench.Mixes {
+		roms[name] = rom
+	}
+	for _, path := range flag.Args() {
+		rom, err := ioutil.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		roms[strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))] = rom
+	}
+	names := make([]string, 0, len(roms))
+	for name := range roms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var opts []hardware.Option
+	if *blockCache {
+		opts = append(opts, hardware.WithBlockCache())
+	}
+	results := bench.Baseline{}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "ROM\tFRAMES/S\tINSTRUCTIONS/S\tALLOCS/FRAME\t")
+	for _, name := range names {
+		r, err := bench.Run(roms[name], *frames, opts...)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			os.Exit(2)
+		}
+		results[name] = r
+		fmt.Fprintf(tw, "%s\t%.0f\t%.0f\t%.2f\t\n", name, r.FramesPerSecond, r.InstructionsPerSecond, r.AllocsPerFrame)
+	}
+	tw.Flush()
+
+	if *baseline == "" {
+		return
+	}
+	if *update {
+		if err := results.Save(*baseline); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		return
+	}
+	base, err := bench.Load(*baseline)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	regressions := base.Compare(results, *threshold)
+	for _, r := range regressions {
+		fmt.Println(r)
+	}
+	if len(regressions) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,404 @@
+// Command gbdbg is an interactive command-line debugger for the emulator.
+//
+//	gbdbg [-sym file] rom
+//
+// Labels from -sym, or the .sym file next to the ROM, are shown in the
+// disassembly and can be used wherever an address is expected.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+
+	"github.com/ifamakes/emu/pkg/debugger"
+	"github.com/ifamakes/emu/pkg/disasm"
+	. "github.com/ifamakes/emu/pkg/hardware"
+	"github.com/ifamakes/emu/pkg/symbols"
+)
+
+const help = `commands:
+  s, step [n]              execute n instructions (default 1)
+  n, next                  step over CALL and RST
+  finish                   run until the current function returns
+  c, continue              run until a breakpoint or watchpoint
+  b, break ADDR            break when PC reaches ADDR
+  b, break BANK:ADDR       break at ADDR with BANK mapped
+  b, break LABEL           break at LABEL, in its bank
+  b, break op XX           break before opcode XX
+  b, break int [VEC]       break on any interrupt, or the one at VEC
+  w, watch [r|w|rw] A[-B]  stop after a read/write of A or A-B (default w)
+  d, delete ID             remove a breakpoint or watchpoint
+  i, info                  list breakpoints and watchpoints
+  r, regs                  show registers
+  set REG VALUE            set a register (a-l, af, bc, de, hl, sp, pc, ime)
+  x ADDR [LEN]             hexdump memory
+  poke ADDR BYTE...        write memory
+  dis [ADDR] [N]           disassemble around PC or from ADDR
+  q, quit                  exit
+numbers other than IDs are hex and any ADDR can be a label; an empty line repeats the last command`
+
+// syms are the labels from the symbol file, or nil without one.
+var syms *symbols.Table
+
+func main() {
+	sym := flag.String("sym", "", "RGBDS or wla-dx symbol file (default: the rom's name with .sym)")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: gbdbg [-sym file] rom")
+		os.Exit(2)
+	}
+	rom, err := ioutil.ReadFile(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if syms, err = symbols.Open(*sym, flag.Arg(0)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	gbc, err := New(rom)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	d := debugger.New(gbc)
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		for range sig {
+			d.Pause()
+		}
+	}()
+
+	printLocation(d)
+	in := bufio.NewScanner(os.Stdin)
+	var last string
+	for {
+		fmt.Print("(gbdbg) ")
+		if !in.Scan() {
+			fmt.Println()
+			return
+		}
+		line := strings.TrimSpace(in.Text())
+		if line == "" {
+			line = last
+		}
+		last = line
+		args := strings.Fields(line)
+		if len(args) == 0 {
+			continue
+		}
+		if args[0] == "q" || args[0] == "quit" {
+			return
+		}
+		if err := command(d, args[0], args[1:]); err != nil {
+			fmt.Println("error:", err)
+		}
+	}
+}
+
+func command(d *debugger.Debugger, cmd string, args []string) error {
+	gbc := d.GBC
+	switch cmd {
+	case "s", "step":
+		n := uint64(1)
+		if len(args) > 0 {
+			v, err := parseHex(args[0], 64)
+			if err != nil {
+				return err
+			}
+			n = v
+		}
+		for i := uint64(0); i < n; i++ {
+			stop, err := d.Step()
+			if err != nil || stop.Kind != debugger.STOP_STEP {
+				return stopped(d)(stop, err)
+			}
+		}
+		printLocation(d)
+	case "n", "next":
+		return stopped(d)(d.Next())
+	case "finish":
+		return stopped(d)(d.Finish())
+	case "c", "continue":
+		return stopped(d)(d.Continue())
+	case "b", "break":
+		b, err := parseBreakpoint(args)
+		if err != nil {
+			return err
+		}
+		if name, ok := syms.Name(b.Bank, b.Addr); ok && (b.Kind == debugger.BREAK_PC || b.Kind == debugger.BREAK_BANK_ADDR) {
+			fmt.Println("breakpoint", d.AddBreakpoint(b), name)
+		} else {
+			fmt.Println("breakpoint", d.AddBreakpoint(b))
+		}
+	case "w", "watch":
+		w, err := parseWatchpoint(args)
+		if err != nil {
+			return err
+		}
+		fmt.Println("watchpoint", d.AddWatchpoint(w))
+	case "d", "delete":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: delete ID")
+		}
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			return err
+		}
+		return d.Delete(id)
+	case "i", "info":
+		for _, b := range d.Breakpoints() {
+			fmt.Println("breakpoint", b)
+		}
+		for _, w := range d.Watchpoints() {
+			fmt.Println("watchpoint", w)
+		}
+	case "r", "regs":
+		printRegisters(gbc)
+	case "set":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: set REG VALUE")
+		}
+		v, err := parseHex(args[1], 16)
+		if err != nil {
+			return err
+		}
+		if err := d.SetRegister(args[0], uint16(v)); err != nil {
+			return err
+		}
+		printRegisters(gbc)
+	case "x":
+		if len(args) < 1 {
+			return fmt.Errorf("usage: x ADDR [LEN]")
+		}
+		addr, err := parseAddr(args[0])
+		if err != nil {
+			return err
+		}
+		n := uint64(0x40)
+		if len(args) > 1 {
+			if n, err = parseHex(args[1], 16); err != nil {
+				return err
+			}
+		}
+		hexdump(gbc, addr, int(n))
+	case "poke":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: poke ADDR BYTE...")
+		}
+		addr, err := parseAddr(args[0])
+		if err != nil {
+			return err
+		}
+		for i, arg := range args[1:] {
+			v, err := parseHex(arg, 8)
+			if err != nil {
+				return err
+			}
+			gbc.Poke(addr+uint16(i), byte(v))
+		}
+	case "dis":
+		n := uint64(10)
+		if len(args) > 1 {
+			v, err := parseHex(args[1], 64)
+			if err != nil {
+				return err
+			}
+			n = v
+		}
+		if len(args) == 0 {
+			printDisasm(gbc, disasm.Around(gbc, gbc.PC, int(n/2), int(n/2)))
+			return nil
+		}
+		addr, err := parseAddr(args[0])
+		if err != nil {
+			return err
+		}
+		var insts []disasm.Instruction
+		for i, a := uint64(0), addr; i < n; i++ {
+			inst := disasm.Decode(gbc, a)
+			insts = append(insts, inst)
+			a += uint16(inst.Len())
+		}
+		printDisasm(gbc, insts)
+	case "h", "help":
+		fmt.Println(help)
+	default:
+		return fmt.Errorf("unknown command %q, try help", cmd)
+	}
+	return nil
+}
+
+// stopped reports why a run ended and where.
+func stopped(d *debugger.Debugger) func(debugger.Stop, error) error {
+	return func(stop debugger.Stop, err error) error {
+		if err != nil {
+			return err
+		}
+		if stop.Kind != debugger.STOP_STEP {
+			fmt.Println(stop)
+		}
+		printLocation(d)
+		return nil
+	}
+}
+
+func parseHex(s string, bits int) (uint64, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(s), "$"), "0x")
+	return strconv.ParseUint(s, 16, bits)
+}
+
+// parseAddr reads a label or a hex address.
+func parseAddr(s string) (uint16, error) {
+	if sym, ok := syms.Lookup(s); ok {
+		return sym.Addr, nil
+	}
+	v, err := parseHex(s, 16)
+	return uint16(v), err
+}
+
+func parseBreakpoint(args []string) (debugger.Breakpoint, error) {
+	if len(args) == 0 {
+		return debugger.Breakpoint{}, fmt.Errorf("usage: break ADDR | BANK:ADDR | LABEL | op XX | int [VEC]")
+	}
+	switch args[0] {
+	case "op":
+		if len(args) != 2 {
+			return debugger.Breakpoint{}, fmt.Errorf("usage: break op XX")
+		}
+		op, err := parseHex(args[1], 8)
+		return debugger.Breakpoint{Kind: debugger.BREAK_OPCODE, Opcode: byte(op)}, err
+	case "int":
+		b := debugger.Breakpoint{Kind: debugger.BREAK_INTERRUPT}
+		if len(args) > 1 {
+			v, err := parseHex(args[1], 16)
+			if err != nil {
+				return b, err
+			}
+			b.Vector = uint16(v)
+		}
+		return b, nil
+	}
+	if sym, ok := syms.Lookup(args[0]); ok {
+		if sym.Addr >= 0x4000 && sym.Addr < 0x8000 {
+			return debugger.Breakpoint{Kind: debugger.BREAK_BANK_ADDR, Bank: sym.Bank, Addr: sym.Addr}, nil
+		}
+		return debugger.Breakpoint{Kind: debugger.BREAK_PC, Addr: sym.Addr}, nil
+	}
+	if i := strings.IndexByte(args[0], ':'); i >= 0 {
+		bank, err := parseHex(args[0][:i], 16)
+		if err != nil {
+			return debugger.Breakpoint{}, err
+		}
+		addr, err := parseHex(args[0][i+1:], 16)
+		return debugger.Breakpoint{Kind: debugger.BREAK_BANK_ADDR, Bank: int(bank), Addr: uint16(addr)}, err
+	}
+	addr, err := parseHex(args[0], 16)
+	return debugger.Breakpoint{Kind: debugger.BREAK_PC, Addr: uint16(addr)}, err
+}
+
+func parseWatchpoint(args []string) (debugger.Watchpoint, error) {
+	w := debugger.Watchpoint{Kind: debugger.WATCH_WRITE}
+	if len(args) == 2 {
+		switch args[0] {
+		case "r":
+			w.Kind = debugger.WATCH_READ
+		case "w":
+		case "rw":
+			w.Kind = debugger.WATCH_ACCESS
+		default:
+			return w, fmt.Errorf("watch kind must be r, w or rw")
+		}
+		args = args[1:]
+	}
+	if len(args) != 1 {
+		return w, fmt.Errorf("usage: watch [r|w|rw] ADDR[-ADDR]")
+	}
+	from, to := args[0], args[0]
+	if i := strings.IndexByte(args[0], '-'); i >= 0 {
+		from, to = args[0][:i], args[0][i+1:]
+	}
+	f, err := parseAddr(from)
+	if err != nil {
+		return w, err
+	}
+	t, err := parseAddr(to)
+	if err != nil {
+		return w, err
+	}
+	if t < f {
+		return w, fmt.Errorf("empty range %s", args[0])
+	}
+	w.From, w.To = f, t
+	return w, nil
+}
+
+func printRegisters(gbc *GBC) {
+	fmt.Printf("AF=%04X BC=%04X DE=%04X HL=%04X SP=%04X PC=%02X:%04X IME=%t\n",
+		gbc.Reg16(AF), gbc.Reg16(BC), gbc.Reg16(DE), gbc.Reg16(HL), gbc.SP, gbc.BankOf(gbc.PC), gbc.PC, gbc.IME)
+	f := gbc.REG[F]
+	flag := func(mask byte, c string) string {
+		if f&mask != 0 {
+			return c
+		}
+		return "-"
+	}
+	fmt.Printf("flags %s%s%s%s\n", flag(0x80, "Z"), flag(0x40, "N"), flag(0x20, "H"), flag(0x10, "C"))
+}
+
+func printLocation(d *debugger.Debugger) {
+	gbc := d.GBC
+	inst := disasm.Decode(gbc, gbc.PC)
+	if name, ok := syms.Describe(gbc.BankOf(gbc.PC), gbc.PC); ok {
+		fmt.Printf("<%s>\n", name)
+	}
+	fmt.Printf("%02X:%04X  %-9s %-20s  AF=%04X BC=%04X DE=%04X HL=%04X SP=%04X\n",
+		gbc.BankOf(gbc.PC), gbc.PC, hexBytes(inst.Bytes), inst.Format(syms.Names(gbc.BankOf(0x4000))), gbc.Reg16(AF), gbc.Reg16(BC), gbc.Reg16(DE), gbc.Reg16(HL), gbc.SP)
+}
+
+func printDisasm(gbc *GBC, insts []disasm.Instruction) {
+	names := syms.Names(gbc.BankOf(0x4000))
+	for _, inst := range insts {
+		marker := " "
+		if inst.Addr == gbc.PC {
+			marker = ">"
+		}
+		if name, ok := names(inst.Addr); ok {
+			fmt.Printf("%s:\n", name)
+		}
+		fmt.Printf("%s %02X:%04X  %-9s %s\n", marker, gbc.BankOf(inst.Addr), inst.Addr, hexBytes(inst.Bytes), inst.Format(names))
+	}
+}
+
+func hexdump(gbc *GBC, addr uint16, n int) {
+	for row := 0; row < n; row += 16 {
+		base := addr + uint16(row)
+		var hex, text strings.Builder
+		for i := 0; i < 16 && row+i < n; i++ {
+			v := gbc.Peek(base + uint16(i))
+			fmt.Fprintf(&hex, "%02X ", v)
+			if v >= 0x20 && v < 0x7F {
+				text.WriteByte(v)
+			} else {
+				text.WriteByte('.')
+			}
+		}
+		fmt.Printf("%04X  %-48s %s\n", base, hex.String(), text.String())
+	}
+}
+
+func hexBytes(b []byte) string {
+	s := make([]string, len(b))
+	for i, v := range b {
+		s[i] = fmt.Sprintf("%02X", v)
+	}
+	return strings.Join(s, " ")
+}
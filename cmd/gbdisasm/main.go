@@ -0,0 +1,60 @@
+// Command gbdisasm prints a disassembly listing of a ROM bank. Labels come
+// from -sym, or the .sym file next to the ROM, where there is one.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	"github.com/ifamakes/emu/pkg/disasm"
+	"github.com/ifamakes/emu/pkg/symbols"
+)
+
+func main() {
+	bank := flag.Int("bank", 0, "ROM bank to disassemble")
+	recursive := flag.Bool("recursive", false, "follow control flow instead of sweeping the whole bank")
+	sym := flag.String("sym", "", "RGBDS or wla-dx symbol file (default: the rom's name with .sym)")
+	var entries []uint16
+	flag.Func("entry", "entry point for -recursive, in hex (repeatable)", func(s string) error {
+		v, err := strconv.ParseUint(s, 16, 16)
+		entries = append(entries, uint16(v))
+		return err
+	})
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: gbdisasm [-bank n] [-recursive] [-entry addr] [-sym file] rom")
+		os.Exit(2)
+	}
+
+	rom, err := ioutil.ReadFile(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *bank < 0 || *bank*0x4000 >= len(rom) {
+		fmt.Fprintf(os.Stderr, "bank %d is outside the %d byte rom\n", *bank, len(rom))
+		os.Exit(1)
+	}
+
+	table, err := symbols.Open(*sym, flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	mode := disasm.LINEAR
+	if *recursive {
+		mode = disasm.RECURSIVE
+	}
+	listing := disasm.List(rom, *bank, mode, entries...)
+	for addr, name := range table.Visible(*bank) {
+		listing.Labels[addr] = name
+	}
+	if _, err := listing.WriteTo(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,38 @@
+// Command gbgdb runs a ROM under a GDB remote protocol stub.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/ifamakes/emu/pkg/debugger"
+	"github.com/ifamakes/emu/pkg/gdbstub"
+	. "github.com/ifamakes/emu/pkg/hardware"
+)
+
+func main() {
+	listen := flag.String("listen", "127.0.0.1:2345", "address to accept GDB connections on")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: gbgdb [-listen addr] rom")
+		os.Exit(2)
+	}
+	rom, err := ioutil.ReadFile(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	gbc, err := New(rom)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, "waiting for gdb on %s\n", *listen)
+	if err := gdbstub.NewServer(debugger.New(gbc)).ListenAndServe(*listen); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
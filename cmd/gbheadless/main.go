@@ -0,0 +1,148 @@
+// Command gbheadless runs a ROM without a frontend until an exit condition
+// is met, then optionally saves a screenshot and a memory dump.
+//
+//	gbheadless [-frames n] [-cycles n] [-pc addr] [-ldbb] [-pass text] [-fail text]
+//	           [-palette name] [-png file] [-gif file] [-gif-frames n] [-dump file] rom
+//
+// The -gif animation is recorded by running on after the exit condition.
+//
+// The exit code is 0 when the ROM passed: it reached -pc, printed -pass, or
+// hit LD B,B with the mooneye pass signature in its registers. It is 1 when
+// it failed or ran out of frames or cycles before any of the conditions it
+// was given, and 2 on errors. Without any conditions, running out of frames
+// or cycles counts as success.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	"github.com/ifamakes/emu/pkg/hardware"
+	"github.com/ifamakes/emu/pkg/headless"
+)
+
+func main() {
+	frames := flag.Uint64("frames", 0, "stop after this many frames")
+	cycles := flag.Uint64("cycles", 0, "stop after this many T-cycles")
+	pc := flag.String("pc", "", "stop when PC reaches this address, in hex")
+	ldbb := flag.Bool("ldbb", false, "stop at LD B,B and check for the mooneye pass signature")
+	pass := flag.String("pass", "", "stop with success when the serial output contains this")
+	fail := flag.String("fail", "", "stop with failure when the serial output contains this")
+	shot := flag.String("png", "", "write a screenshot to this file")
+	anim := flag.String("gif", "", "write an animated GIF of the frames after the run to this file")
+	animFrames := flag.Int("gif-frames", 120, "frames to record with -gif")
+	palette := flag.String("palette", "grey", "DMG palette: grey, green, or four hex colours like e0f8d0,88c070,346856,081820")
+	dump := flag.String("dump", "", "write the 64K address space to this file")
+	verbose := flag.Bool("v", false, "print the serial output")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: gbheadless [flags] rom")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	rom, err := ioutil.ReadFile(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	pal, err := hardware.ParsePalette(*palette)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "bad -palette:", err)
+		os.Exit(2)
+	}
+	gbc, err := hardware.New(rom, hardware.WithPalette(pal))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	c := headless.Config{
+		Frames:     *frames,
+		Cycles:     *cycles,
+		LDBB:       *ldbb,
+		SerialPass: []byte(*pass),
+		SerialFail: []byte(*fail),
+	}
+	if *pc != "" {
+		v, err := strconv.ParseUint(*pc, 16, 16)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "bad -pc:", err)
+			os.Exit(2)
+		}
+		addr := uint16(v)
+		c.UntilPC = &addr
+	}
+	conditions := c.UntilPC != nil || c.LDBB || *pass != "" || *fail != ""
+
+	res, err := headless.Run(gbc, c)
+	if *verbose && len(res.Serial) > 0 {
+		fmt.Printf("%s\n", res.Serial)
+	}
+	fmt.Printf("%s after %d frames (%d cycles), PC %04X\n", res.Reason, res.Frames, res.Cycles, gbc.PC)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if *shot != "" {
+		if err := writeFile(*shot, gbc.WritePNG); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	if *dump != "" {
+		mem := make([]byte, 0x10000)
+		for i := range mem {
+			mem[i] = gbc.Peek(uint16(i))
+		}
+		if err := ioutil.WriteFile(*dump, mem, 0644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	if *anim != "" {
+		err := writeFile(*anim, func(w io.Writer) error { return gbc.WriteGIF(w, *animFrames) })
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	switch res.Reason {
+	case headless.REASON_PC, headless.REASON_SERIAL_PASS:
+	case headless.REASON_LDBB:
+		if !headless.MooneyePassed(gbc) {
+			os.Exit(1)
+		}
+	case headless.REASON_SERIAL_FAIL:
+		os.Exit(1)
+	default:
+		if conditions {
+			os.Exit(1)
+		}
+	}
+}
+
+func writeFile(name string, write func(io.Writer) error) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err := write(w); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
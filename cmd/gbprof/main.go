@@ -0,0 +1,151 @@
+// Command gbprof runs a ROM for a number of frames and reports where it spent
+// its time and which memory it used.
+//
+//	gbprof [-frames n] [-functions n] [-pprof file] [-heatmaps dir] [-top n] [-free n] [-sym file] rom
+//
+// It prints the functions that took the most T-cycles, the most executed
+// instructions and the runs of ROM that were never read or executed. With
+// -pprof it writes the call graph for go tool pprof, for example
+//
+//	go tool pprof -http :8080 game.pprof
+//
+// to see it as a flame graph. With -heatmaps it writes a PNG per ROM bank
+// and for WRAM and HRAM: writes are red, reads green and executes blue.
+//
+// Functions and addresses are named from -sym, or the .sym file next to the
+// ROM, where there is one.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/ifamakes/emu/pkg/disasm"
+	"github.com/ifamakes/emu/pkg/hardware"
+	"github.com/ifamakes/emu/pkg/profile"
+	"github.com/ifamakes/emu/pkg/symbols"
+)
+
+func main() {
+	frames := flag.Uint64("frames", 600, "frames to run")
+	functions := flag.Int("functions", 20, "list this many of the functions that took the most cycles")
+	pprof := flag.String("pprof", "", "write the cycle profile in pprof format to this file")
+	heatmaps := flag.String("heatmaps", "", "write heatmaps to this directory")
+	top := flag.Int("top", 20, "list this many of the most executed instructions")
+	free := flag.Int("free", 256, "list unused runs of ROM at least this long")
+	sym := flag.String("sym", "", "RGBDS or wla-dx symbol file (default: the rom's name with .sym)")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: gbprof [flags] rom")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+
+	rom, err := ioutil.ReadFile(flag.Arg(0))
+	if err != nil {
+		fatal(err)
+	}
+	syms, err := symbols.Open(*sym, flag.Arg(0))
+	if err != nil {
+		fatal(err)
+	}
+	gbc, err := hardware.New(rom)
+	if err != nil {
+		fatal(err)
+	}
+	mem := profile.NewMemoryProfile(gbc)
+	cycles := profile.NewCycleProfile(gbc)
+	cycles.Names = func(fn profile.Function) (string, bool) { return syms.Name(fn.Bank, fn.Addr) }
+	for i := uint64(0); i < *frames; i++ {
+		if err := gbc.RunFrame(); err != nil {
+			fatal(err)
+		}
+	}
+	banks := (len(rom) + 0x3FFF) / 0x4000
+
+	if *functions > 0 {
+		cycles.WriteTop(os.Stdout, *functions)
+	}
+	if *pprof != "" {
+		err := writeFile(*pprof, func(w io.Writer) error { return cycles.WritePprof(w, filepath.Base(flag.Arg(0))) })
+		if err != nil {
+			fatal(err)
+		}
+	}
+	if *top > 0 {
+		fmt.Println("most executed:")
+		for _, h := range mem.Hottest(*top) {
+			inst := disasm.Decode(disasm.Bank(rom, h.Bank), h.Addr)
+			fmt.Printf("  %02X:%04X  %10d  %s%s\n", h.Bank, h.Addr, h.Executes, inst.Format(syms.Names(h.Bank)), comment(syms, h.Bank, h.Addr))
+		}
+	}
+	if *free > 0 {
+		fmt.Printf("unused runs of %d bytes or more:\n", *free)
+		total := 0
+		for bank := 0; bank < banks; bank++ {
+			for _, r := range mem.Unused(bank, *free) {
+				fmt.Printf("  %02X:%04X-%04X  %5d bytes%s\n", r.Bank, r.Start, r.End, r.Len(), comment(syms, r.Bank, r.Start))
+				total += r.Len()
+			}
+		}
+		fmt.Printf("  %d bytes in all\n", total)
+	}
+
+	if *heatmaps != "" {
+		if err := os.MkdirAll(*heatmaps, 0755); err != nil {
+			fatal(err)
+		}
+		for bank := 0; bank < banks; bank++ {
+			if err := writePNG(filepath.Join(*heatmaps, fmt.Sprintf("rom%02X.png", bank)), mem.ROMHeatmap(bank)); err != nil {
+				fatal(err)
+			}
+		}
+		if err := writePNG(filepath.Join(*heatmaps, "wram.png"), mem.WRAMHeatmap()); err != nil {
+			fatal(err)
+		}
+		if err := writePNG(filepath.Join(*heatmaps, "hram.png"), mem.HRAMHeatmap()); err != nil {
+			fatal(err)
+		}
+	}
+}
+
+// comment is " ; " and the label at or before addr, if there is one.
+func comment(syms *symbols.Table, bank int, addr uint16) string {
+	if name, ok := syms.Describe(bank, addr); ok {
+		return " ; " + name
+	}
+	return ""
+}
+
+func fatal(v ...interface{}) {
+	fmt.Fprintln(os.Stderr, v...)
+	os.Exit(2)
+}
+
+func writePNG(name string, img image.Image) error {
+	return writeFile(name, func(w io.Writer) error { return png.Encode(w, img) })
+}
+
+func writeFile(name string, write func(io.Writer) error) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err := write(w); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
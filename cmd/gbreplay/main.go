@@ -0,0 +1,82 @@
+// Command gbreplay plays a movie back headless and checks that the screen at
+// the end matches the one recorded.
+//
+//	gbreplay [-expect hash] [-o movie.gbm] rom movie
+//
+// The movie can be in our own format, as recorded by gbterm -record, a
+// BizHawk .bk2 or a VisualBoyAdvance .vbm file. It exits 1 if the screen hash differs from the one in the
+// movie, or from -expect for formats that don't store one, so bug reports
+// and known-good TAS runs can be replayed in CI. -o writes the movie
+// in our format with the replayed hash.
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/ifamakes/emu/pkg/movie"
+)
+
+func main() {
+	expect := flag.String("expect", "", "expected screen hash, in hex")
+	out := flag.String("o", "", "write the movie with the replayed screen hash to this file")
+	flag.Parse()
+	if flag.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, "usage: gbreplay [-expect hash] [-o file] rom movie")
+		os.Exit(2)
+	}
+
+	rom, err := ioutil.ReadFile(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	data, err := ioutil.ReadFile(flag.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	m, err := movie.Import(flag.Arg(1), data, rom)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	want := m.ScreenHash
+	if *expect != "" {
+		b, err := hex.DecodeString(*expect)
+		if err != nil || len(b) != len(want) {
+			fmt.Fprintln(os.Stderr, "bad -expect hash")
+			os.Exit(2)
+		}
+		copy(want[:], b)
+	}
+
+	gbc, err := m.Play(rom)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	got := movie.ScreenHash(gbc)
+	fmt.Printf("%d frames, screen %x\n", len(m.Input), got)
+
+	if *out != "" {
+		m.ScreenHash = got
+		var buf bytes.Buffer
+		if _, err := m.WriteTo(&buf); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		if err := ioutil.WriteFile(*out, buf.Bytes(), 0644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+	if want != [len(want)]byte{} && got != want {
+		fmt.Printf("mismatch: expected %x\n", want)
+		os.Exit(1)
+	}
+}
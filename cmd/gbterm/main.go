@@ -0,0 +1,247 @@
+// Command gbterm plays a ROM in a terminal. Each character cell shows two
+// pixels with the upper half block and 24-bit colour, so the screen takes
+// 160 columns and 72 rows. It needs nothing but a terminal, which makes it
+// usable over SSH.
+//
+//	gbterm [-palette name] [-sidebar] [-sym file] [-record movie.gbm] rom
+//
+// Keys: arrows or WASD for the d-pad, X or K for A, Z or J for B, Enter for
+// Start, Space or Backspace for Select, Tab to toggle the register and
+// disassembly sidebar, P to pause and Q or Ctrl-C to quit.
+//
+// Terminals don't report key releases, so a button stays held for a few
+// frames after each key press and key repeat keeps it held.
+//
+// The sidebar shows labels from -sym, or the .sym file next to the ROM.
+//
+// -record saves the session from power on as a movie, written on quitting,
+// that gbreplay plays back and checks against the final screen.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/ifamakes/emu/pkg/disasm"
+	"github.com/ifamakes/emu/pkg/hardware"
+	"github.com/ifamakes/emu/pkg/movie"
+	"github.com/ifamakes/emu/pkg/symbols"
+)
+
+// HOLD_FRAMES is how long a key press holds its button. It has to outlast
+// the delay before key repeat starts, which is commonly 250-500ms.
+const HOLD_FRAMES = 30
+
+const FRAME_TIME = time.Second * hardware.CYCLES_PER_FRAME / 4194304
+
+const SIDEBAR_WIDTH = 34
+
+func main() {
+	palette := flag.String("palette", "grey", "DMG palette: grey, green, or four hex colours like e0f8d0,88c070,346856,081820")
+	sidebar := flag.Bool("sidebar", false, "show registers and disassembly next to the screen")
+	sym := flag.String("sym", "", "RGBDS or wla-dx symbol file (default: the rom's name with .sym)")
+	record := flag.String("record", "", "record the session as a movie in this file")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: gbterm [flags] rom")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+	pal, err := hardware.ParsePalette(*palette)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "bad -palette:", err)
+		os.Exit(2)
+	}
+	rom, err := ioutil.ReadFile(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	gbc, err := hardware.New(rom, hardware.WithPalette(pal))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	syms, err := symbols.Open(*sym, flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	var rec *movie.Movie
+	if *record != "" {
+		if rec, err = movie.Record(gbc, rom); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	restore, err := makeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "can't put the terminal in raw mode:", err)
+		os.Exit(2)
+	}
+	t := &term{w: bufio.NewWriterSize(os.Stdout, 1<<16), sidebar: *sidebar}
+	// Switch to the alternate screen and hide the cursor.
+	t.w.WriteString("\x1b[?1049h\x1b[?25l\x1b[2J")
+	err = play(gbc, t, syms, rec)
+	t.w.WriteString("\x1b[0m\x1b[?25h\x1b[?1049l")
+	t.w.Flush()
+	restore()
+	if rec != nil {
+		// Keep the movie even if the game crashed; that's when it's wanted.
+		rec.Finish(gbc)
+		var buf bytes.Buffer
+		if _, werr := rec.WriteTo(&buf); werr != nil {
+			fmt.Fprintln(os.Stderr, werr)
+			os.Exit(2)
+		}
+		if werr := ioutil.WriteFile(*record, buf.Bytes(), 0644); werr != nil {
+			fmt.Fprintln(os.Stderr, werr)
+			os.Exit(2)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+var keys = map[string]hardware.BUTTON{
+	"\x1b[A": hardware.BUTTON_UP, "\x1b[B": hardware.BUTTON_DOWN,
+	"\x1b[C": hardware.BUTTON_RIGHT, "\x1b[D": hardware.BUTTON_LEFT,
+	"\x1bOA": hardware.BUTTON_UP, "\x1bOB": hardware.BUTTON_DOWN,
+	"\x1bOC": hardware.BUTTON_RIGHT, "\x1bOD": hardware.BUTTON_LEFT,
+	"w": hardware.BUTTON_UP, "s": hardware.BUTTON_DOWN,
+	"d": hardware.BUTTON_RIGHT, "a": hardware.BUTTON_LEFT,
+	"x": hardware.BUTTON_A, "k": hardware.BUTTON_A,
+	"z": hardware.BUTTON_B, "j": hardware.BUTTON_B,
+	"\r": hardware.BUTTON_START, "\n": hardware.BUTTON_START,
+	" ": hardware.BUTTON_SELECT, "\x7f": hardware.BUTTON_SELECT,
+}
+
+// play runs gbc in real time until the player quits, adding each frame's
+// buttons to rec if it isn't nil.
+func play(gbc *hardware.GBC, t *term, syms *symbols.Table, rec *movie.Movie) error {
+	input := make(chan []byte, 16)
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				close(input)
+				return
+			}
+			input <- append([]byte(nil), buf[:n]...)
+		}
+	}()
+
+	var held [8]int // frames left on each button
+	paused := false
+	img := image.NewPaletted(image.Rect(0, 0, hardware.SCREEN_WIDTH, hardware.SCREEN_HEIGHT), nil)
+	tick := time.NewTicker(FRAME_TIME)
+	defer tick.Stop()
+	for {
+		select {
+		case in, ok := <-input:
+			if !ok {
+				return nil
+			}
+			for len(in) > 0 {
+				key := nextKey(in)
+				in = in[len(key):]
+				switch key {
+				case "q", "Q", "\x03":
+					return nil
+				case "p", "P":
+					paused = !paused
+				case "\t":
+					t.sidebar = !t.sidebar
+					t.invalidate()
+				default:
+					if b, ok := keys[key]; ok {
+						for i := range held {
+							if b&(1<<uint(i)) != 0 {
+								held[i] = HOLD_FRAMES
+							}
+						}
+					}
+				}
+			}
+			continue
+		case <-tick.C:
+		}
+
+		if !paused {
+			var buttons hardware.BUTTON
+			for i := range held {
+				if held[i] > 0 {
+					held[i]--
+					buttons |= 1 << uint(i)
+				}
+			}
+			gbc.SetButtons(buttons)
+			if rec != nil {
+				rec.Frame(gbc)
+			}
+			if err := gbc.RunFrame(); err != nil {
+				return err
+			}
+		}
+		gbc.DrawScreen(img)
+		var side []string
+		if t.sidebar {
+			side = sidebar(gbc, syms, paused)
+		}
+		if err := t.draw(img, side); err != nil {
+			return err
+		}
+	}
+}
+
+// nextKey returns the first key in in: an escape sequence or one byte.
+func nextKey(in []byte) string {
+	if len(in) >= 3 && in[0] == 0x1B && (in[1] == '[' || in[1] == 'O') {
+		return string(in[:3])
+	}
+	return string(in[:1])
+}
+
+func sidebar(gbc *hardware.GBC, syms *symbols.Table, paused bool) []string {
+	f := gbc.REG[hardware.F]
+	flag := func(mask byte, c string) string {
+		if f&mask != 0 {
+			return c
+		}
+		return "-"
+	}
+	state := fmt.Sprintf("frame %d", gbc.FrameCount())
+	if paused {
+		state += "  PAUSED"
+	}
+	lines := []string{
+		fmt.Sprintf("AF %04X  BC %04X", gbc.Reg16(hardware.AF), gbc.Reg16(hardware.BC)),
+		fmt.Sprintf("DE %04X  HL %04X", gbc.Reg16(hardware.DE), gbc.Reg16(hardware.HL)),
+		fmt.Sprintf("SP %04X  PC %02X:%04X", gbc.SP, gbc.BankOf(gbc.PC), gbc.PC),
+		fmt.Sprintf("IME %-5t %s%s%s%s", gbc.IME, flag(0x80, "Z"), flag(0x40, "N"), flag(0x20, "H"), flag(0x10, "C")),
+		state,
+		"",
+	}
+	names := syms.Names(gbc.BankOf(0x4000))
+	for _, inst := range disasm.Around(gbc, gbc.PC, 8, 16) {
+		mark := "  "
+		if inst.Addr == gbc.PC {
+			mark = "> "
+		}
+		if name, ok := names(inst.Addr); ok {
+			lines = append(lines, name+":")
+		}
+		lines = append(lines, fmt.Sprintf("%s%04X  %s", mark, inst.Addr, inst.Format(names)))
+	}
+	return lines
+}
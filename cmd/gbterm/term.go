@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// term draws frames with the upper half block, redrawing only the rows
+// that changed since the last frame to keep the output small over SSH.
+type term struct {
+	w       *bufio.Writer
+	sidebar bool
+
+	last    []byte
+	palette color.Palette
+	side    []string
+	valid   bool
+}
+
+// invalidate makes the next draw redraw everything.
+func (t *term) invalidate() {
+	t.valid = false
+	t.w.WriteString("\x1b[2J")
+}
+
+func (t *term) draw(img *image.Paletted, side []string) error {
+	if !t.valid || !samePalette(t.palette, img.Palette) {
+		t.valid = false
+		t.last = make([]byte, len(img.Pix))
+		t.palette = append(t.palette[:0], img.Palette...)
+	}
+	w := img.Stride
+	for row := 0; row < img.Rect.Dy()/2; row++ {
+		pix := img.Pix[row*2*w : (row*2+2)*w]
+		line := ""
+		if row < len(side) {
+			line = side[row]
+		}
+		oldLine := ""
+		if row < len(t.side) {
+			oldLine = t.side[row]
+		}
+		if t.valid && string(pix) == string(t.last[row*2*w:(row*2+2)*w]) {
+			if line != oldLine && t.sidebar {
+				fmt.Fprintf(t.w, "\x1b[%d;%dH", row+1, w+1)
+				t.drawSide(line)
+			}
+			continue
+		}
+		fmt.Fprintf(t.w, "\x1b[%d;1H", row+1)
+		var fg, bg color.Color
+		for x := 0; x < w; x++ {
+			top, bottom := img.Palette[pix[x]], img.Palette[pix[w+x]]
+			if top != fg {
+				r, g, b, _ := top.RGBA()
+				fmt.Fprintf(t.w, "\x1b[38;2;%d;%d;%dm", r>>8, g>>8, b>>8)
+				fg = top
+			}
+			if bottom != bg {
+				r, g, b, _ := bottom.RGBA()
+				fmt.Fprintf(t.w, "\x1b[48;2;%d;%d;%dm", r>>8, g>>8, b>>8)
+				bg = bottom
+			}
+			t.w.WriteString("▀")
+		}
+		t.w.WriteString("\x1b[0m")
+		if t.sidebar {
+			t.drawSide(line)
+		}
+	}
+	copy(t.last, img.Pix)
+	t.side = append(t.side[:0], side...)
+	t.valid = true
+	return t.w.Flush()
+}
+
+// drawSide writes a sidebar line with the cursor just right of the screen.
+func (t *term) drawSide(line string) {
+	if len(line) > SIDEBAR_WIDTH {
+		line = line[:SIDEBAR_WIDTH]
+	}
+	fmt.Fprintf(t.w, "  %s\x1b[K", line)
+}
+
+func samePalette(a, b color.Palette) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,180 @@
+// Command gbvram runs a ROM, or loads a save state, and writes what is in
+// video memory to PNGs for debugging graphics.
+//
+//	gbvram [-frames n] [-state file] [-palette name] [-scale n] [-o name] view rom
+//
+// The view is one of:
+//
+//	tiles     every tile in VRAM, both banks on a CGB
+//	map0      the background map at 9800 with the SCX/SCY viewport outlined
+//	map1      the background map at 9C00 with the SCX/SCY viewport outlined
+//	oam       the 40 sprites, also printed as a table
+//	palettes  the DMG palette registers or CGB palette RAM
+//	all       all of the above
+//
+// A single view is written to -o, by default the view's name with ".png".
+// With "all", -o is a directory to write them to.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/ifamakes/emu/pkg/hardware"
+)
+
+var views = []string{"tiles", "map0", "map1", "oam", "palettes"}
+
+func main() {
+	frames := flag.Uint64("frames", 60, "frames to run before drawing")
+	state := flag.String("state", "", "load this save state instead of running from power on")
+	palette := flag.String("palette", "grey", "DMG palette: grey, green, or four hex colours like e0f8d0,88c070,346856,081820")
+	scale := flag.Int("scale", 2, "scale the images up by this factor")
+	out := flag.String("o", "", "file, or directory for all, to write to")
+	flag.Parse()
+	if flag.NArg() != 2 || *scale < 1 {
+		fmt.Fprintln(os.Stderr, "usage: gbvram [flags] tiles|map0|map1|oam|palettes|all rom")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+	view := flag.Arg(0)
+
+	pal, err := hardware.ParsePalette(*palette)
+	if err != nil {
+		fatal("bad -palette:", err)
+	}
+	rom, err := ioutil.ReadFile(flag.Arg(1))
+	if err != nil {
+		fatal(err)
+	}
+	gbc, err := hardware.New(rom, hardware.WithPalette(pal))
+	if err != nil {
+		fatal(err)
+	}
+	if *state != "" {
+		f, err := os.Open(*state)
+		if err != nil {
+			fatal(err)
+		}
+		err = gbc.LoadState(bufio.NewReader(f))
+		f.Close()
+		if err != nil {
+			fatal(err)
+		}
+	} else {
+		for i := uint64(0); i < *frames; i++ {
+			if err := gbc.RunFrame(); err != nil {
+				fatal(err)
+			}
+		}
+	}
+
+	if view != "all" {
+		name := *out
+		if name == "" {
+			name = view + ".png"
+		}
+		if err := write(gbc, view, name, *scale); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	dir := *out
+	if dir == "" {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fatal(err)
+	}
+	for _, v := range views {
+		if err := write(gbc, v, filepath.Join(dir, v+".png"), *scale); err != nil {
+			fatal(err)
+		}
+	}
+}
+
+func fatal(v ...interface{}) {
+	fmt.Fprintln(os.Stderr, v...)
+	os.Exit(2)
+}
+
+func write(gbc *hardware.GBC, view, name string, scale int) error {
+	var img image.Image
+	switch view {
+	case "tiles":
+		img = gbc.Tiles()
+	case "map0":
+		img = gbc.TileMap(0)
+	case "map1":
+		img = gbc.TileMap(1)
+	case "oam":
+		printOAM(gbc)
+		img = gbc.OAMSheet()
+	case "palettes":
+		img = gbc.PaletteSwatches()
+	default:
+		return fmt.Errorf("unknown view %q", view)
+	}
+
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err := png.Encode(w, scaleUp(img, scale)); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func printOAM(gbc *hardware.GBC) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', 0)
+	fmt.Fprintln(tw, "#\tY\tX\tTILE\tATTR\tPRI\tFLIP\tPAL\tBANK")
+	for i, s := range gbc.OAM() {
+		flip := ""
+		if s.Attr&0x20 != 0 {
+			flip += "X"
+		}
+		if s.Attr&0x40 != 0 {
+			flip += "Y"
+		}
+		pri := "above"
+		if s.Attr&0x80 != 0 {
+			pri = "behind"
+		}
+		pal := fmt.Sprintf("OBP%d", s.Attr>>4&1)
+		bank := "-"
+		if gbc.Model() == hardware.CGB {
+			pal, bank = fmt.Sprint(s.Attr&7), fmt.Sprint(s.Attr>>3&1)
+		}
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%02X\t%02X\t%s\t%s\t%s\t%s\n", i, int(s.Y)-16, int(s.X)-8, s.Tile, s.Attr, pri, flip, pal, bank)
+	}
+	tw.Flush()
+}
+
+// scaleUp enlarges img by an integer factor without smoothing.
+func scaleUp(img image.Image, scale int) image.Image {
+	if scale == 1 {
+		return img
+	}
+	b := img.Bounds()
+	big := image.NewRGBA(image.Rect(0, 0, b.Dx()*scale, b.Dy()*scale))
+	for y := 0; y < big.Rect.Dy(); y++ {
+		for x := 0; x < big.Rect.Dx(); x++ {
+			big.Set(x, y, img.At(b.Min.X+x/scale, b.Min.Y+y/scale))
+		}
+	}
+	return big
+}
@@ -0,0 +1,170 @@
+// Command tracediff runs a ROM against a reference Gameboy Doctor log and
+// reports the first line where the two diverge. The disassembly around the
+// divergence is labelled from -sym, or the .sym file next to the ROM.
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/ifamakes/emu/pkg/disasm"
+	. "github.com/ifamakes/emu/pkg/hardware"
+	"github.com/ifamakes/emu/pkg/symbols"
+)
+
+type executed struct {
+	line string
+	pc   uint16
+}
+
+func main() {
+	context := flag.Int("context", 5, "lines of context to print around the divergence")
+	skip := flag.Uint64("skip", 0, "run this many instructions before comparing")
+	sym := flag.String("sym", "", "RGBDS or wla-dx symbol file (default: the rom's name with .sym)")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: tracediff [-context n] [-skip n] [-sym file] rom reference.log[.gz]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *context < 0 {
+		fmt.Fprintln(os.Stderr, "tracediff: -context must not be negative")
+		os.Exit(2)
+	}
+	os.Exit(run(flag.Arg(0), flag.Arg(1), *sym, *context, *skip))
+}
+
+func run(romPath, refPath, symPath string, context int, skip uint64) int {
+	rom, err := ioutil.ReadFile(romPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	syms, err := symbols.Open(symPath, romPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	ref, err := openReference(refPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	defer ref.Close()
+
+	gbc, err := New(rom)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	scanner := bufio.NewScanner(ref)
+	history := make([]executed, 0, context)
+	var line uint64
+	for ; scanner.Scan(); line++ {
+		got := capture(gbc)
+		if line >= skip && scanner.Text() != got.line {
+			report(gbc, syms, scanner, history, got, line+1, context)
+			return 1
+		}
+		if context > 0 {
+			if len(history) == context {
+				history = append(history[:0], history[1:]...)
+			}
+			history = append(history, got)
+		}
+		if err := gbc.Step(); err != nil {
+			fmt.Fprintf(os.Stderr, "line %d: %v\n", line+1, err)
+			return 2
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	fmt.Printf("no divergence in %d lines\n", line)
+	return 0
+}
+
+// openReference opens a reference log, transparently decompressing it if it
+// starts with the gzip magic.
+func openReference(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(f)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1F && magic[1] == 0x8B {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return struct {
+			io.Reader
+			io.Closer
+		}{zr, f}, nil
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{br, f}, nil
+}
+
+func capture(gbc *GBC) executed {
+	return executed{line: string(AppendDoctorLine(nil, gbc)), pc: gbc.PC}
+}
+
+func report(gbc *GBC, syms *symbols.Table, scanner *bufio.Scanner, history []executed, got executed, line uint64, context int) {
+	around := disasm.Around(gbc, got.pc, context, context)
+	names := syms.Names(gbc.BankOf(0x4000))
+
+	if name, ok := syms.Describe(gbc.BankOf(got.pc), got.pc); ok {
+		fmt.Printf("divergence at line %d in %s\n\n", line, name)
+	} else {
+		fmt.Printf("divergence at line %d\n\n", line)
+	}
+	for i, h := range history {
+		fmt.Printf("  %8d  %s\n", line-uint64(len(history)-i), h.line)
+	}
+	fmt.Printf("- %8d  %s\n", line, scanner.Text())
+	fmt.Printf("+ %8d  %s\n", line, got.line)
+
+	for i := 0; i < context && scanner.Scan(); i++ {
+		fmt.Printf("- %8d  %s\n", line+uint64(i)+1, scanner.Text())
+	}
+	for i := 0; i < context; i++ {
+		if gbc.Step() != nil {
+			break
+		}
+		fmt.Printf("+ %8d  %s\n", line+uint64(i)+1, AppendDoctorLine(nil, gbc))
+	}
+
+	fmt.Println()
+	for _, inst := range around {
+		marker := " "
+		if inst.Addr == got.pc {
+			marker = ">"
+		}
+		if name, ok := names(inst.Addr); ok {
+			fmt.Printf("%s:\n", name)
+		}
+		fmt.Printf("%s %04X  %-9s %s\n", marker, inst.Addr, hexBytes(inst.Bytes), inst.Format(names))
+	}
+}
+
+func hexBytes(b []byte) string {
+	s := ""
+	for _, v := range b {
+		s += fmt.Sprintf("%02X ", v)
+	}
+	return s
+}
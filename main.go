@@ -1,37 +1,80 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"io/ioutil"
-	"log"
 	"os"
 
 	. "github.com/ifamakes/emu/pkg/hardware"
+	"github.com/ifamakes/emu/pkg/symbols"
 )
 
+type blarggOutput struct{}
+
+func (blarggOutput) Transfer(out byte) byte {
+	fmt.Printf("blargg output: %q\n", out)
+	return 0xFF
+}
+
 func main() {
-	log_file, err := os.Create("emu_log")
-	if err != nil {
-		panic(err)
+	trace := flag.String("trace", "", "write a Gameboy Doctor trace to this file")
+	compare := flag.String("compare", "", "stop on the first mismatch against this Gameboy Doctor trace")
+	sym := flag.String("sym", "", "label -trace lines from this RGBDS or wla-dx symbol file")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: emu [-trace file [-sym file]] [-compare file] rom")
+		os.Exit(2)
+	}
+	if err := run(flag.Arg(0), *trace, *compare, *sym); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
-	defer log_file.Close()
-	log.SetFlags(0)
-	log.SetOutput(log_file)
+}
 
-	file, err := ioutil.ReadFile(os.Args[1])
+// run plays rom until it fails or, with compare set, leaves the reference
+// trace. It returns instead of exiting so the trace is flushed first.
+func run(rom, trace, compare, sym string) error {
+	file, err := ioutil.ReadFile(rom)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	compare, err := os.Open(os.Args[2])
-	if err != nil {
-		panic(err)
+	opts := []Option{WithSerial(blarggOutput{})}
+	if trace != "" {
+		log_file, err := os.Create(trace)
+		if err != nil {
+			return err
+		}
+		defer log_file.Close()
+		doctor := NewDoctorWriter(log_file)
+		defer doctor.Flush()
+		if sym != "" {
+			table, err := symbols.Load(sym)
+			if err != nil {
+				return err
+			}
+			doctor.Names = table.Describe
+		}
+		opts = append(opts, WithTracer(doctor))
+	}
+	if compare != "" {
+		compare_file, err := os.Open(compare)
+		if err != nil {
+			return err
+		}
+		defer compare_file.Close()
+		opts = append(opts, WithTraceCompare(compare_file))
 	}
-	defer compare.Close()
 
-	g := NewGBC(file, compare)
+	g, err := New(file, opts...)
+	if err != nil {
+		return err
+	}
 
 	for {
-		g.Step()
+		if err := g.Step(); err != nil {
+			return err
+		}
 	}
-
 }
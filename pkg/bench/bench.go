@@ -0,0 +1,122 @@
+// Package bench measures how fast the emulator runs ROMs, and compares the
+// results against a stored baseline. It is the engine behind cmd/gbbench and
+// the benchmarks in this package.
+package bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/ifamakes/emu/pkg/hardware"
+)
+
+// Result is the throughput of one ROM.
+type Result struct {
+	FramesPerSecond       float64 `json:"frames_per_second"`
+	InstructionsPerSecond float64 `json:"instructions_per_second"`
+	AllocsPerFrame        float64 `json:"allocs_per_frame"`
+}
+
+// Baseline maps ROM names to the results they are expected to reach.
+type Baseline map[string]Result
+
+// Run runs rom for the given number of frames and measures it, after one
+// frame to warm up caches.
+func Run(rom []byte, frames uint64, opts ...hardware.Option) (Result, error) {
+	gbc, err := hardware.New(rom, opts...)
+	if err != nil {
+		return Result{}, err
+	}
+	if err := gbc.RunFrame(); err != nil {
+		return Result{}, err
+	}
+	return Frames(gbc, frames)
+}
+
+// Frames runs gbc for the given number of frames and measures it.
+func Frames(gbc *hardware.GBC, frames uint64) (Result, error) {
+	var before, after runtime.MemStats
+	instructions := gbc.Instructions()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	for i := uint64(0); i < frames; i++ {
+		if err := gbc.RunFrame(); err != nil {
+			return Result{}, err
+		}
+	}
+	elapsed := time.Since(start).Seconds()
+	runtime.ReadMemStats(&after)
+	return Result{
+		FramesPerSecond:       float64(frames) / elapsed,
+		InstructionsPerSecond: float64(gbc.Instructions()-instructions) / elapsed,
+		AllocsPerFrame:        float64(after.Mallocs-before.Mallocs) / float64(frames),
+	}, nil
+}
+
+// Load reads a baseline written by Save.
+func Load(name string) (Baseline, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var b Baseline
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return b, nil
+}
+
+// Save writes b as indented JSON.
+func (b Baseline) Save(name string) error {
+	data, err := json.MarshalIndent(b, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(name, append(data, '\n'), 0644)
+}
+
+// Regression is a ROM that got slower, or started allocating more, than its
+// baseline allows.
+type Regression struct {
+	Name     string
+	Metric   string
+	Got      float64
+	Baseline float64
+}
+
+func (r Regression) String() string {
+	return fmt.Sprintf("%s: %s %.4g, baseline %.4g", r.Name, r.Metric, r.Got, r.Baseline)
+}
+
+// Compare returns the regressions in results against b. Throughput may
+// drop by up to threshold, a fraction of the baseline, and allocations may
+// not grow at all. ROMs missing from either side are ignored.
+func (b Baseline) Compare(results Baseline, threshold float64) []Regression {
+	var regressions []Regression
+	for name, got := range results {
+		want, ok := b[name]
+		if !ok {
+			continue
+		}
+		if got.FramesPerSecond < want.FramesPerSecond*(1-threshold) {
+			regressions = append(regressions, Regression{name, "frames/s", got.FramesPerSecond, want.FramesPerSecond})
+		}
+		if got.InstructionsPerSecond < want.InstructionsPerSecond*(1-threshold) {
+			regressions = append(regressions, Regression{name, "instructions/s", got.InstructionsPerSecond, want.InstructionsPerSecond})
+		}
+		if got.AllocsPerFrame > want.AllocsPerFrame {
+			regressions = append(regressions, Regression{name, "allocs/frame", got.AllocsPerFrame, want.AllocsPerFrame})
+		}
+	}
+	sort.Slice(regressions, func(i, j int) bool {
+		if regressions[i].Name != regressions[j].Name {
+			return regressions[i].Name < regressions[j].Name
+		}
+		return regressions[i].Metric < regressions[j].Metric
+	})
+	return regressions
+}
@@ -0,0 +1,80 @@
+package bench
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/ifamakes/emu/pkg/hardware"
+)
+
+// BenchmarkFrames runs every synthetic mix, and every ROM under
+// $GB_BENCH_ROMS, one frame per iteration.
+func BenchmarkFrames(b *testing.B) {
+	roms := map[string][]byte{}
+	for name, rom := range Mixes {
+		roms[name] = rom
+	}
+	if dir := os.Getenv("GB_BENCH_ROMS"); dir != "" {
+		paths, _ := filepath.Glob(filepath.Join(dir, "*.gb*"))
+		for _, path := range paths {
+			rom, err := ioutil.ReadFile(path)
+			if err != nil {
+				b.Fatal(err)
+			}
+			roms[strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))] = rom
+		}
+	}
+	names := make([]string, 0, len(roms))
+	for name := range roms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		rom := roms[name]
+		b.Run(name, func(b *testing.B) { benchmarkFrames(b, rom) })
+		b.Run(name+"/blockcache", func(b *testing.B) { benchmarkFrames(b, rom, hardware.WithBlockCache()) })
+	}
+}
+
+func benchmarkFrames(b *testing.B, rom []byte, opts ...hardware.Option) {
+	gbc, err := hardware.New(rom, opts...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := gbc.RunFrame(); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	r, err := Frames(gbc, uint64(b.N))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportMetric(r.FramesPerSecond, "frames/s")
+	b.ReportMetric(r.InstructionsPerSecond, "instructions/s")
+	b.ReportMetric(r.AllocsPerFrame, "allocs/frame")
+}
+
+func TestCompare(t *testing.T) {
+	base := Baseline{
+		"alu": {FramesPerSecond: 1000, InstructionsPerSecond: 1e7},
+		"cb":  {FramesPerSecond: 1000, InstructionsPerSecond: 1e7},
+	}
+	got := base.Compare(Baseline{
+		"alu": {FramesPerSecond: 950, InstructionsPerSecond: 9.5e6},
+		"cb":  {FramesPerSecond: 800, InstructionsPerSecond: 8e6, AllocsPerFrame: 1},
+		"new": {FramesPerSecond: 1},
+	}, 0.1)
+	if len(got) != 3 {
+		t.Fatalf("got %v, want the three cb regressions", got)
+	}
+	for _, r := range got {
+		if r.Name != "cb" {
+			t.Errorf("unexpected regression %v", r)
+		}
+	}
+}
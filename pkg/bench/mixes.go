@@ -0,0 +1,75 @@
+package bench
+
+// The synthetic mixes are endless loops that each stress one part of the
+// core, so a regression shows up in the mix it belongs to.
+
+func synthetic(code ...byte) []byte {
+	rom := make([]byte, 0x8000)
+	copy(rom[0x100:], code)
+	return rom
+}
+
+// Mixes maps the name of each synthetic instruction mix to its ROM.
+var Mixes = map[string][]byte{
+	// Register to register arithmetic and logic.
+	"alu": synthetic(
+		0x3E, 0x01, // LD A, 01
+		0x06, 0x03, // LD B, 03
+		0x80,       // loop: ADD A, B
+		0x90,       // SUB B
+		0xA8,       // XOR B
+		0xB0,       // OR B
+		0xA0,       // AND B
+		0x88,       // ADC A, B
+		0x98,       // SBC A, B
+		0xB8,       // CP B
+		0x3C,       // INC A
+		0x04,       // INC B
+		0x27,       // DAA
+		0x2F,       // CPL
+		0x18, 0xF2, // JR loop
+	),
+	// Loads and stores to work RAM and HRAM, and the stack.
+	"memory": synthetic(
+		0x31, 0xFE, 0xFF, // LD SP, FFFE
+		0x21, 0x00, 0xC0, // LD HL, C000
+		0x22,             // loop: LD (HL+), A
+		0x7E,             // LD A, (HL)
+		0xC5,             // PUSH BC
+		0xD1,             // POP DE
+		0xEA, 0x00, 0xC1, // LD (C100), A
+		0xFA, 0x00, 0xC1, // LD A, (C100)
+		0xE0, 0x80, // LDH (80), A
+		0xF0, 0x80, // LDH A, (80)
+		0xCB, 0x64, // BIT 4, H
+		0x28, 0xEE, // JR Z, loop
+		0x21, 0x00, 0xC0, // LD HL, C000
+		0x18, 0xE9, // JR loop
+	),
+	// Calls, returns and jumps.
+	"branch": synthetic(
+		0x31, 0xFE, 0xFF, // LD SP, FFFE
+		0xCD, 0x10, 0x01, // loop: CALL 0110
+		0x05,       // DEC B
+		0x20, 0xFA, // JR NZ, loop
+		0xC3, 0x03, 0x01, // JP loop
+		0x00, 0x00, 0x00, 0x00,
+		0xC5, // 0110: PUSH BC
+		0xC1, // POP BC
+		0xC9, // RET
+	),
+	// CB prefixed rotates, shifts and bit operations.
+	"cb": synthetic(
+		0x06, 0x5A, // LD B, 5A
+		0xCB, 0x00, // loop: RLC B
+		0xCB, 0x19, // RR C
+		0xCB, 0x22, // SLA D
+		0xCB, 0x2B, // SRA E
+		0xCB, 0x3C, // SRL H
+		0xCB, 0x35, // SWAP L
+		0xCB, 0x47, // BIT 0, A
+		0xCB, 0xC7, // SET 0, A
+		0xCB, 0x87, // RES 0, A
+		0x18, 0xEC, // JR loop
+	),
+}
@@ -0,0 +1,301 @@
+// Package debugger drives a GBC with breakpoints and watchpoints. It is the
+// engine behind cmd/gbdbg and the GDB stub.
+package debugger
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/ifamakes/emu/pkg/disasm"
+	"github.com/ifamakes/emu/pkg/hardware"
+)
+
+type BreakKind int
+
+const (
+	BREAK_PC        BreakKind = iota // PC reaches Addr in any bank
+	BREAK_BANK_ADDR                  // PC reaches Addr with Bank mapped
+	BREAK_OPCODE                     // the next opcode is Opcode
+	BREAK_INTERRUPT                  // the CPU is dispatched to Vector, or any if 0
+)
+
+type Breakpoint struct {
+	ID     int
+	Kind   BreakKind
+	Addr   uint16
+	Bank   int
+	Opcode byte
+	Vector uint16
+}
+
+func (b *Breakpoint) String() string {
+	switch b.Kind {
+	case BREAK_BANK_ADDR:
+		return fmt.Sprintf("#%d at %02X:%04X", b.ID, b.Bank, b.Addr)
+	case BREAK_OPCODE:
+		return fmt.Sprintf("#%d on opcode %02X", b.ID, b.Opcode)
+	case BREAK_INTERRUPT:
+		if b.Vector == 0 {
+			return fmt.Sprintf("#%d on any interrupt", b.ID)
+		}
+		return fmt.Sprintf("#%d on interrupt %04X", b.ID, b.Vector)
+	}
+	return fmt.Sprintf("#%d at %04X", b.ID, b.Addr)
+}
+
+type WatchKind int
+
+const (
+	WATCH_READ WatchKind = 1 << iota
+	WATCH_WRITE
+	WATCH_ACCESS = WATCH_READ | WATCH_WRITE
+)
+
+// Watchpoint stops execution after an instruction touches From-To, inclusive.
+type Watchpoint struct {
+	ID       int
+	Kind     WatchKind
+	From, To uint16
+}
+
+func (w *Watchpoint) String() string {
+	kind := map[WatchKind]string{WATCH_READ: "read", WATCH_WRITE: "write", WATCH_ACCESS: "access"}[w.Kind]
+	if w.From == w.To {
+		return fmt.Sprintf("#%d %s %04X", w.ID, kind, w.From)
+	}
+	return fmt.Sprintf("#%d %s %04X-%04X", w.ID, kind, w.From, w.To)
+}
+
+type StopKind int
+
+const (
+	STOP_STEP StopKind = iota
+	STOP_BREAKPOINT
+	STOP_WATCHPOINT
+	STOP_INTERRUPTED
+)
+
+// Stop says why execution last stopped.
+type Stop struct {
+	Kind       StopKind
+	Breakpoint *Breakpoint
+	Watchpoint *Watchpoint
+	// Addr and Value describe the access that hit a watchpoint.
+	Addr  uint16
+	Value byte
+	Write bool
+}
+
+func (s Stop) String() string {
+	switch s.Kind {
+	case STOP_BREAKPOINT:
+		return "breakpoint " + s.Breakpoint.String()
+	case STOP_WATCHPOINT:
+		verb := "read"
+		if s.Write {
+			verb = "write"
+		}
+		return fmt.Sprintf("watchpoint %s: %s %02X at %04X", s.Watchpoint, verb, s.Value, s.Addr)
+	case STOP_INTERRUPTED:
+		return "interrupted"
+	}
+	return "stepped"
+}
+
+var ErrNoSuchID = errors.New("no breakpoint or watchpoint with that id")
+
+// Debugger wraps a GBC. It installs itself as the GBC's tracer, forwarding
+// events to any tracer that was already there.
+type Debugger struct {
+	GBC *hardware.GBC
+
+	breakpoints []*Breakpoint
+	watchpoints []*Watchpoint
+	nextID      int
+	next        hardware.Tracer
+	pending     *Stop
+	paused      int32
+}
+
+func New(gbc *hardware.GBC) *Debugger {
+	d := &Debugger{GBC: gbc, nextID: 1, next: gbc.Tracer()}
+	gbc.SetTracer(d)
+	return d
+}
+
+func (d *Debugger) Breakpoints() []*Breakpoint { return d.breakpoints }
+func (d *Debugger) Watchpoints() []*Watchpoint { return d.watchpoints }
+
+func (d *Debugger) AddBreakpoint(b Breakpoint) *Breakpoint {
+	b.ID = d.nextID
+	d.nextID++
+	d.breakpoints = append(d.breakpoints, &b)
+	return &b
+}
+
+func (d *Debugger) AddWatchpoint(w Watchpoint) *Watchpoint {
+	w.ID = d.nextID
+	d.nextID++
+	d.watchpoints = append(d.watchpoints, &w)
+	return &w
+}
+
+// Delete removes the breakpoint or watchpoint with the given id.
+func (d *Debugger) Delete(id int) error {
+	for i, b := range d.breakpoints {
+		if b.ID == id {
+			d.breakpoints = append(d.breakpoints[:i], d.breakpoints[i+1:]...)
+			return nil
+		}
+	}
+	for i, w := range d.watchpoints {
+		if w.ID == id {
+			d.watchpoints = append(d.watchpoints[:i], d.watchpoints[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNoSuchID
+}
+
+// Pause makes a running Continue, Next or Finish return at the next
+// instruction boundary. It is safe to call from another goroutine.
+func (d *Debugger) Pause() { atomic.StoreInt32(&d.paused, 1) }
+
+func (d *Debugger) Instruction(gbc *hardware.GBC, pc uint16, op byte) {
+	if d.next != nil {
+		d.next.Instruction(gbc, pc, op)
+	}
+}
+
+func (d *Debugger) MemoryAccess(addr uint16, value byte, write bool) {
+	if d.next != nil {
+		d.next.MemoryAccess(addr, value, write)
+	}
+	if d.pending != nil {
+		return
+	}
+	kind := WATCH_READ
+	if write {
+		kind = WATCH_WRITE
+	}
+	for _, w := range d.watchpoints {
+		if w.Kind&kind != 0 && addr >= w.From && addr <= w.To {
+			d.pending = &Stop{Kind: STOP_WATCHPOINT, Watchpoint: w, Addr: addr, Value: value, Write: write}
+			return
+		}
+	}
+}
+
+func (d *Debugger) Interrupt(vector uint16) {
+	if d.next != nil {
+		d.next.Interrupt(vector)
+	}
+	if d.pending != nil {
+		return
+	}
+	for _, b := range d.breakpoints {
+		if b.Kind == BREAK_INTERRUPT && (b.Vector == 0 || b.Vector == vector) {
+			d.pending = &Stop{Kind: STOP_BREAKPOINT, Breakpoint: b}
+			return
+		}
+	}
+}
+
+// breakpointAt returns the breakpoint that fires before the instruction at
+// the current PC is executed.
+func (d *Debugger) breakpointAt() *Breakpoint {
+	pc := d.GBC.PC
+	for _, b := range d.breakpoints {
+		switch b.Kind {
+		case BREAK_PC:
+			if b.Addr == pc {
+				return b
+			}
+		case BREAK_BANK_ADDR:
+			if b.Addr == pc && d.GBC.BankOf(pc) == b.Bank {
+				return b
+			}
+		case BREAK_OPCODE:
+			if d.GBC.Peek(pc) == b.Opcode {
+				return b
+			}
+		}
+	}
+	return nil
+}
+
+// Step executes exactly one instruction, ignoring breakpoints at the current
+// PC, and reports a watchpoint or interrupt breakpoint it triggered. An
+// interrupt due first is dispatched on its own, so an interrupt breakpoint
+// stops at the vector before the handler runs.
+func (d *Debugger) Step() (Stop, error) {
+	d.pending = nil
+	if _, ok := d.GBC.InterruptDue(); ok {
+		d.GBC.HandleInterrupts()
+		if d.pending != nil {
+			return *d.pending, nil
+		}
+	}
+	err := d.GBC.Step()
+	if d.pending != nil {
+		return *d.pending, err
+	}
+	return Stop{Kind: STOP_STEP}, err
+}
+
+// run steps until done reports true or something stops execution. The first
+// instruction is always executed so continuing from a breakpoint works.
+func (d *Debugger) run(done func() bool) (Stop, error) {
+	atomic.StoreInt32(&d.paused, 0)
+	for first := true; ; first = false {
+		if !first {
+			if atomic.LoadInt32(&d.paused) != 0 {
+				return Stop{Kind: STOP_INTERRUPTED}, nil
+			}
+			if b := d.breakpointAt(); b != nil {
+				return Stop{Kind: STOP_BREAKPOINT, Breakpoint: b}, nil
+			}
+		}
+		stop, err := d.Step()
+		if err != nil || stop.Kind != STOP_STEP {
+			return stop, err
+		}
+		if done != nil && done() {
+			return stop, nil
+		}
+	}
+}
+
+// Continue runs until a breakpoint or watchpoint is hit.
+func (d *Debugger) Continue() (Stop, error) {
+	return d.run(nil)
+}
+
+// Next steps over CALL and RST, running until the call returns.
+func (d *Debugger) Next() (Stop, error) {
+	inst := disasm.Decode(d.GBC, d.GBC.PC)
+	if !inst.IsCall() {
+		return d.Step()
+	}
+	ret, sp := d.GBC.PC+uint16(inst.Len()), d.GBC.SP
+	return d.run(func() bool {
+		return d.GBC.PC == ret && d.GBC.SP >= sp
+	})
+}
+
+// Finish runs until the current function returns to its caller.
+func (d *Debugger) Finish() (Stop, error) {
+	sp := d.GBC.SP
+	return d.run(func() bool {
+		return d.GBC.SP > sp && isReturn(d.GBC.LastOpcode())
+	})
+}
+
+func isReturn(op byte) bool {
+	switch op {
+	case 0xC9, 0xD9, 0xC0, 0xC8, 0xD0, 0xD8:
+		return true
+	}
+	return false
+}
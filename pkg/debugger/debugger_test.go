@@ -0,0 +1,114 @@
+package debugger
+
+import (
+	"testing"
+
+	"github.com/ifamakes/emu/pkg/hardware"
+)
+
+// testGBC runs a 64KB MBC1 ROM that switches to bank 2, touches C000, takes
+// the interrupt at 0048 and then loops at 4000.
+func testGBC(t *testing.T) *hardware.GBC {
+	rom := make([]byte, 0x10000)
+	copy(rom[0x100:], []byte{
+		0x3E, 0x02, // 0100 LD A, 02
+		0xEA, 0x00, 0x20, // 0102 LD (2000), A
+		0xEA, 0x00, 0xC0, // 0105 LD (C000), A
+		0xFA, 0x00, 0xC0, // 0108 LD A, (C000)
+		0xFB,             // 010B EI
+		0x00,             // 010C NOP
+		0xC3, 0x00, 0x40, // 010D JP 4000
+	})
+	copy(rom[0x48:], []byte{0x00, 0xD9}) // NOP; RETI
+	loop := []byte{0x00, 0x18, 0xFD}     // 4000 NOP; JR 4000
+	copy(rom[0x4000:], loop)
+	copy(rom[0x8000:], loop)
+	rom[0x147] = 0x01
+	gbc, err := hardware.New(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gbc.Poke(hardware.IE, 0x02)
+	gbc.Poke(0xFF0F, 0x02)
+	return gbc
+}
+
+func TestContinue(t *testing.T) {
+	// fallback catches the cases that should never stop on their own.
+	fallback := Breakpoint{Kind: BREAK_PC, Addr: 0x4001}
+	tests := []struct {
+		name string
+		bp   *Breakpoint
+		wp   *Watchpoint
+		// want is the kind of stop and the PC it leaves, and fell whether
+		// the fallback breakpoint is what stopped it.
+		want StopKind
+		pc   uint16
+		fell bool
+	}{
+		{"pc", &Breakpoint{Kind: BREAK_PC, Addr: 0x0108}, nil, STOP_BREAKPOINT, 0x0108, false},
+		{"bank and address", &Breakpoint{Kind: BREAK_BANK_ADDR, Bank: 2, Addr: 0x4000}, nil, STOP_BREAKPOINT, 0x4000, false},
+		{"other bank", &Breakpoint{Kind: BREAK_BANK_ADDR, Bank: 1, Addr: 0x4000}, nil, STOP_BREAKPOINT, 0x4001, true},
+		{"opcode", &Breakpoint{Kind: BREAK_OPCODE, Opcode: 0xFB}, nil, STOP_BREAKPOINT, 0x010B, false},
+		{"interrupt", &Breakpoint{Kind: BREAK_INTERRUPT, Vector: 0x48}, nil, STOP_BREAKPOINT, 0x0048, false},
+		{"any interrupt", &Breakpoint{Kind: BREAK_INTERRUPT}, nil, STOP_BREAKPOINT, 0x0048, false},
+		{"other interrupt", &Breakpoint{Kind: BREAK_INTERRUPT, Vector: 0x40}, nil, STOP_BREAKPOINT, 0x4001, true},
+		{"write", nil, &Watchpoint{Kind: WATCH_WRITE, From: 0xC000, To: 0xC000}, STOP_WATCHPOINT, 0x0108, false},
+		{"read", nil, &Watchpoint{Kind: WATCH_READ, From: 0xBFFF, To: 0xC001}, STOP_WATCHPOINT, 0x010B, false},
+		{"untouched", nil, &Watchpoint{Kind: WATCH_ACCESS, From: 0xC001, To: 0xC0FF}, STOP_BREAKPOINT, 0x4001, true},
+	}
+	for _, tt := range tests {
+		d := New(testGBC(t))
+		var b *Breakpoint
+		if tt.bp != nil {
+			b = d.AddBreakpoint(*tt.bp)
+		}
+		if tt.wp != nil {
+			d.AddWatchpoint(*tt.wp)
+		}
+		fb := d.AddBreakpoint(fallback)
+
+		stop, err := d.Continue()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if stop.Kind != tt.want || d.GBC.PC != tt.pc {
+			t.Errorf("%s: stopped with %v at %04X, want kind %d at %04X", tt.name, stop, d.GBC.PC, tt.want, tt.pc)
+			continue
+		}
+		switch {
+		case tt.fell && stop.Breakpoint != fb:
+			t.Errorf("%s: stopped by %v, want the fallback", tt.name, stop)
+		case !tt.fell && tt.bp != nil && stop.Breakpoint != b:
+			t.Errorf("%s: stopped by %v, want %v", tt.name, stop, b)
+		}
+	}
+}
+
+func TestInterruptBreakpointResumes(t *testing.T) {
+	d := New(testGBC(t))
+	d.AddBreakpoint(Breakpoint{Kind: BREAK_INTERRUPT})
+	if _, err := d.Continue(); err != nil {
+		t.Fatal(err)
+	}
+	sp := d.GBC.SP
+	// The handler's first instruction runs next, without a second dispatch.
+	if stop, err := d.Step(); err != nil || stop.Kind != STOP_STEP || d.GBC.PC != 0x0049 || d.GBC.SP != sp {
+		t.Errorf("step from the vector stopped with %v, %v at %04X, SP %04X, want 0049, SP %04X", stop, err, d.GBC.PC, d.GBC.SP, sp)
+	}
+}
+
+func TestWatchpointStop(t *testing.T) {
+	d := New(testGBC(t))
+	d.AddWatchpoint(Watchpoint{Kind: WATCH_WRITE, From: 0xC000, To: 0xC000})
+	stop, err := d.Continue()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stop.Addr != 0xC000 || stop.Value != 0x02 || !stop.Write {
+		t.Errorf("stop %+v, want a write of 02 to C000", stop)
+	}
+	if bank := d.GBC.ROMBank(); bank != 2 {
+		t.Errorf("ROM bank %d, want 2", bank)
+	}
+}
@@ -0,0 +1,66 @@
+package debugger
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/ifamakes/emu/pkg/hardware"
+)
+
+var ErrUnknownRegister = errors.New("unknown register")
+
+var registers8 = map[string]hardware.REGISTER8{
+	"a": hardware.A, "f": hardware.F, "b": hardware.B, "c": hardware.C,
+	"d": hardware.D, "e": hardware.E, "h": hardware.H, "l": hardware.L,
+}
+
+var registers16 = map[string]hardware.REGISTER16{
+	"af": hardware.AF, "bc": hardware.BC, "de": hardware.DE, "hl": hardware.HL,
+}
+
+// Register reads a register by name: a-l, af, bc, de, hl, sp, pc or ime.
+func (d *Debugger) Register(name string) (uint16, error) {
+	name = strings.ToLower(name)
+	if r, ok := registers8[name]; ok {
+		return uint16(d.GBC.REG[r]), nil
+	}
+	if r, ok := registers16[name]; ok {
+		return d.GBC.Reg16(r), nil
+	}
+	switch name {
+	case "sp":
+		return d.GBC.SP, nil
+	case "pc":
+		return d.GBC.PC, nil
+	case "ime":
+		if d.GBC.IME {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	return 0, ErrUnknownRegister
+}
+
+// SetRegister writes a register by name. The low nibble of F always reads
+// back as zero, as on hardware.
+func (d *Debugger) SetRegister(name string, value uint16) error {
+	name = strings.ToLower(name)
+	if r, ok := registers8[name]; ok {
+		d.GBC.REG[r] = byte(value)
+	} else if r, ok := registers16[name]; ok {
+		d.GBC.SetReg16(r, value)
+	} else {
+		switch name {
+		case "sp":
+			d.GBC.SP = value
+		case "pc":
+			d.GBC.PC = value
+		case "ime":
+			d.GBC.IME = value != 0
+		default:
+			return ErrUnknownRegister
+		}
+	}
+	d.GBC.REG[hardware.F] &= 0xF0
+	return nil
+}
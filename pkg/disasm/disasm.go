@@ -0,0 +1,266 @@
+// Package disasm decodes SM83 machine code using the labels of the opcode
+// tables in package hardware.
+package disasm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ifamakes/emu/pkg/hardware"
+)
+
+// Memory is anything instructions can be decoded from. *hardware.GBC
+// satisfies it, as does a ROM bank returned by Bank.
+type Memory interface {
+	Peek(addr uint16) byte
+}
+
+type OperandKind int
+
+const (
+	REGISTER  OperandKind = iota // A, HL, SP...
+	CONDITION                    // NZ, Z, NC, C
+	INDIRECT                     // (HL), (BC), (HL+), (FF00+C)...
+	IMM8                         // u8
+	IMM16                        // u16
+	ADDRESS                      // (u16) or (FF00+u8), a memory operand
+	TARGET                       // jump, call or restart destination
+	OFFSET                       // signed i8 added to SP
+	BIT                          // bit number of BIT, RES and SET
+)
+
+type Operand struct {
+	Kind  OperandKind
+	Text  string
+	Value uint16
+}
+
+// Instruction is a single decoded instruction.
+type Instruction struct {
+	Addr     uint16
+	Bytes    []byte
+	Mnemonic string
+	Operands []Operand
+	// Cycles is the T-cycle cost, or the cost when the branch is taken for
+	// conditional control flow. CyclesNotTaken is only set for the latter.
+	Cycles         int
+	CyclesNotTaken int
+	// Flags lists the effect on Z, N, H and C, e.g. "Z0HC"; see flags.
+	Flags   string
+	Invalid bool
+}
+
+func (i Instruction) Len() int { return len(i.Bytes) }
+
+// Target returns the destination of a jump, call or restart.
+func (i Instruction) Target() (uint16, bool) {
+	for _, op := range i.Operands {
+		if op.Kind == TARGET {
+			return op.Value, true
+		}
+	}
+	return 0, false
+}
+
+func (i Instruction) Conditional() bool {
+	return i.CyclesNotTaken != 0
+}
+
+// EndsBlock reports whether execution never falls through to the next
+// instruction.
+func (i Instruction) EndsBlock() bool {
+	if i.Invalid {
+		return true
+	}
+	if i.Conditional() {
+		return false
+	}
+	switch i.Mnemonic {
+	case "JP", "JR", "RET", "RETI", "RST":
+		return true
+	}
+	return false
+}
+
+func (i Instruction) IsCall() bool {
+	return i.Mnemonic == "CALL" || i.Mnemonic == "RST"
+}
+
+func (i Instruction) String() string {
+	return i.Format(nil)
+}
+
+// Format renders the instruction, replacing targets and addresses with
+// names from names where one is found.
+func (i Instruction) Format(names func(addr uint16) (string, bool)) string {
+	if len(i.Operands) == 0 {
+		return i.Mnemonic
+	}
+	ops := make([]string, len(i.Operands))
+	for n, op := range i.Operands {
+		ops[n] = op.Text
+		if names == nil {
+			continue
+		}
+		switch op.Kind {
+		case TARGET:
+			if name, ok := names(op.Value); ok {
+				ops[n] = name
+			}
+		case ADDRESS:
+			if name, ok := names(op.Value); ok {
+				ops[n] = "(" + name + ")"
+			}
+		}
+	}
+	return i.Mnemonic + " " + strings.Join(ops, ", ")
+}
+
+// Decode decodes the instruction at addr.
+func Decode(mem Memory, addr uint16) Instruction {
+	op := mem.Peek(addr)
+	inst := Instruction{Addr: addr, Bytes: []byte{op}}
+	label, prefixed := hardware.Label(op, false), false
+	if op == 0xCB {
+		op = mem.Peek(addr + 1)
+		inst.Bytes = append(inst.Bytes, op)
+		label, prefixed = hardware.Label(op, true), true
+	}
+
+	mnemonic, operands := splitLabel(label)
+	inst.Mnemonic = mnemonic
+	if mnemonic == "INVALID" {
+		inst.Invalid = true
+		return inst
+	}
+	if prefixed {
+		inst.Cycles = int(cbCycles(op))
+	} else {
+		inst.Cycles = int(cycles[op])
+		inst.CyclesNotTaken = int(cyclesNotTaken[op])
+	}
+	inst.Flags = flags(mnemonic, operands)
+
+	next := addr + uint16(len(inst.Bytes))
+	imm8 := func() byte {
+		b := mem.Peek(next)
+		inst.Bytes = append(inst.Bytes, b)
+		return b
+	}
+	imm16 := func() uint16 {
+		l, h := imm8(), mem.Peek(next+1)
+		inst.Bytes = append(inst.Bytes, h)
+		return uint16(h)<<8 | uint16(l)
+	}
+
+	for n, text := range operands {
+		var o Operand
+		switch {
+		case text == "u8":
+			v := imm8()
+			o = Operand{IMM8, fmt.Sprintf("$%02X", v), uint16(v)}
+		case text == "u16" && (mnemonic == "JP" || mnemonic == "CALL"):
+			v := imm16()
+			o = Operand{TARGET, fmt.Sprintf("$%04X", v), v}
+		case text == "u16":
+			v := imm16()
+			o = Operand{IMM16, fmt.Sprintf("$%04X", v), v}
+		case text == "(u16)":
+			v := imm16()
+			o = Operand{ADDRESS, fmt.Sprintf("($%04X)", v), v}
+		case text == "(FF00+u8)":
+			v := 0xFF00 + uint16(imm8())
+			o = Operand{ADDRESS, fmt.Sprintf("($%04X)", v), v}
+		case text == "i8" && mnemonic == "JR":
+			d := int8(imm8())
+			v := uint16(int32(next) + 1 + int32(d))
+			o = Operand{TARGET, fmt.Sprintf("$%04X", v), v}
+		case text == "i8":
+			d := int8(imm8())
+			o = Operand{OFFSET, signed(d), uint16(int16(d))}
+		case text == "SP+i8":
+			d := int8(imm8())
+			o = Operand{OFFSET, "SP" + signed(d), uint16(int16(d))}
+		case mnemonic == "RST":
+			var v uint16
+			fmt.Sscanf(text, "%xh", &v)
+			o = Operand{TARGET, fmt.Sprintf("$%02X", v), v}
+		case n == 0 && (mnemonic == "BIT" || mnemonic == "RES" || mnemonic == "SET"):
+			o = Operand{BIT, text, uint16(text[0] - '0')}
+		case strings.HasPrefix(text, "("):
+			o = Operand{INDIRECT, text, 0}
+		case n == 0 && len(operands) > 1 && isCondition(text) && (mnemonic == "JP" || mnemonic == "JR" || mnemonic == "CALL"):
+			o = Operand{CONDITION, text, 0}
+		case len(operands) == 1 && isCondition(text) && mnemonic == "RET":
+			o = Operand{CONDITION, text, 0}
+		default:
+			o = Operand{REGISTER, text, 0}
+		}
+		inst.Operands = append(inst.Operands, o)
+	}
+	if mnemonic == "STOP" {
+		inst.Bytes = append(inst.Bytes, mem.Peek(next))
+	}
+	return inst
+}
+
+// splitLabel turns a table label like "0x20; JR NZ, i8" into its mnemonic
+// and operand templates, smoothing over the odd spacing and case in the
+// tables.
+func splitLabel(label string) (string, []string) {
+	if i := strings.Index(label, "; "); i >= 0 {
+		label = label[i+2:]
+	}
+	fields := strings.SplitN(label, " ", 2)
+	mnemonic := strings.TrimSuffix(fields[0], ",")
+	if len(fields) == 1 {
+		return mnemonic, nil
+	}
+	var operands []string
+	for _, op := range strings.Split(fields[1], ",") {
+		op = strings.TrimSpace(op)
+		if len(op) == 1 {
+			op = strings.ToUpper(op)
+		}
+		operands = append(operands, op)
+	}
+	return mnemonic, operands
+}
+
+func isCondition(s string) bool {
+	return s == "NZ" || s == "Z" || s == "NC" || s == "C"
+}
+
+func signed(d int8) string {
+	if d < 0 {
+		return fmt.Sprintf("-$%02X", -int(d))
+	}
+	return fmt.Sprintf("+$%02X", d)
+}
+
+// Around decodes up to before instructions leading up to pc, the instruction
+// at pc, and after instructions following it. Decoding backwards is a guess:
+// it picks the earliest start address whose instruction stream lands on pc.
+func Around(mem Memory, pc uint16, before, after int) []Instruction {
+	var insts []Instruction
+	for back := 3 * before; back > 0 && insts == nil; back-- {
+		var try []Instruction
+		for addr := pc - uint16(back); addr != pc && int(pc-addr) <= back; {
+			inst := Decode(mem, addr)
+			try = append(try, inst)
+			addr += uint16(inst.Len())
+			if addr == pc {
+				insts = try
+			}
+		}
+	}
+	if len(insts) > before {
+		insts = insts[len(insts)-before:]
+	}
+	for i, addr := 0, pc; i <= after; i++ {
+		inst := Decode(mem, addr)
+		insts = append(insts, inst)
+		addr += uint16(inst.Len())
+	}
+	return insts
+}
@@ -0,0 +1,131 @@
+package disasm
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type flat []byte
+
+func (f flat) Peek(addr uint16) byte { return f[addr] }
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		code   []byte
+		addr   uint16
+		text   string
+		cycles int
+		flags  string
+	}{
+		{[]byte{0x00}, 0, "NOP", 4, "----"},
+		{[]byte{0x01, 0x34, 0x12}, 0, "LD BC, $1234", 12, "----"},
+		{[]byte{0x18, 0xFE}, 0x10, "JR $0010", 12, "----"},
+		{[]byte{0x20, 0x05}, 0x10, "JR NZ, $0017", 12, "----"},
+		{[]byte{0xBC}, 0, "CP A, H", 4, "Z1HC"},
+		{[]byte{0xCD, 0x50, 0x01}, 0, "CALL $0150", 24, "----"},
+		{[]byte{0xCE, 0x01}, 0, "ADC A, $01", 8, "Z0HC"},
+		{[]byte{0xE0, 0x40}, 0, "LD ($FF40), A", 12, "----"},
+		{[]byte{0xE8, 0xFE}, 0, "ADD SP, -$02", 16, "00HC"},
+		{[]byte{0xFA, 0x00, 0xC0}, 0, "LD A, ($C000)", 16, "----"},
+		{[]byte{0xEF}, 0, "RST $28", 16, "----"},
+		{[]byte{0xCB, 0x7E}, 0, "BIT 7, (HL)", 12, "Z01-"},
+		{[]byte{0xCB, 0x1F}, 0, "RR A", 8, "Z00C"},
+	}
+	for _, tt := range tests {
+		mem := make(flat, 0x10000)
+		copy(mem[tt.addr:], tt.code)
+		inst := Decode(mem, tt.addr)
+		if inst.String() != tt.text || inst.Len() != len(tt.code) || inst.Cycles != tt.cycles || inst.Flags != tt.flags {
+			t.Errorf("% X: got %q len %d cycles %d flags %s, want %q len %d cycles %d flags %s",
+				tt.code, inst, inst.Len(), inst.Cycles, inst.Flags, tt.text, len(tt.code), tt.cycles, tt.flags)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	code := []byte{
+		0xCD, 0x10, 0x00, // 0000 CALL $0010
+		0x18, 0x03, // 0003 JR $0008
+		0x3E, 0x01, // 0005 LD A, $01, jumped over
+		0x00,             // 0007 NOP
+		0xC2, 0x14, 0x00, // 0008 JP NZ, $0014
+		0xC9,                   // 000B RET
+		0x01, 0x02, 0x03, 0x04, // 000C data
+		0x3E, 0x01, // 0010 LD A, $01
+		0xC9,       // 0012 RET
+		0x00,       // 0013 data
+		0x18, 0xFE, // 0014 JR $0014
+		0x00, 0x00,
+	}
+	bank1 := make([]byte, 0x4003)
+	copy(bank1[0x4000:], []byte{0x00, 0xC9, 0x00}) // NOP; RET; data
+
+	tests := []struct {
+		name    string
+		rom     []byte
+		bank    int
+		mode    Mode
+		entries []uint16
+		want    []uint16
+		labels  map[uint16]string
+	}{
+		{"linear", code, 0, LINEAR, nil,
+			[]uint16{0x00, 0x03, 0x05, 0x07, 0x08, 0x0B, 0x0C, 0x0F, 0x10, 0x12, 0x13, 0x14, 0x16, 0x17},
+			map[uint16]string{0x08: "loc_0008", 0x10: "sub_0010", 0x14: "loc_0014"}},
+		{"linear stops before a cut off operand", []byte{0x00, 0xCD, 0x00}, 0, LINEAR, nil,
+			[]uint16{0x00}, map[uint16]string{}},
+		{"recursive", code, 0, RECURSIVE, []uint16{0x00},
+			[]uint16{0x00, 0x03, 0x08, 0x0B, 0x10, 0x12, 0x14},
+			map[uint16]string{0x08: "loc_0008", 0x10: "sub_0010", 0x14: "loc_0014"}},
+		{"recursive from a subroutine", code, 0, RECURSIVE, []uint16{0x10},
+			[]uint16{0x10, 0x12}, map[uint16]string{}},
+		{"recursive from the vectors", code, 0, RECURSIVE, nil,
+			[]uint16{0x00, 0x03, 0x08, 0x0B, 0x10, 0x12, 0x14},
+			map[uint16]string{0x08: "loc_0008", 0x10: "sub_0010", 0x14: "loc_0014"}},
+		{"recursive from the start of bank 1", bank1, 1, RECURSIVE, nil,
+			[]uint16{0x4000, 0x4001}, map[uint16]string{}},
+	}
+	for _, tt := range tests {
+		l := List(tt.rom, tt.bank, tt.mode, tt.entries...)
+		var got []uint16
+		for _, inst := range l.Instructions {
+			got = append(got, inst.Addr)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: decoded % X, want % X", tt.name, got, tt.want)
+		}
+		if fmt.Sprint(l.Labels) != fmt.Sprint(tt.labels) {
+			t.Errorf("%s: labels %v, want %v", tt.name, l.Labels, tt.labels)
+		}
+	}
+}
+
+func TestWriteTo(t *testing.T) {
+	rom := []byte{
+		0xFA, 0x08, 0x00, // 0000 LD A, ($0008)
+		0xCD, 0x09, 0x00, // 0003 CALL $0009
+		0x18, 0xFE, // 0006 JR $0006
+		0xAA, // 0008 data
+		0xC9, // 0009 RET
+	}
+	l := List(rom, 0, RECURSIVE, 0)
+	l.Labels[0x0008] = "table"
+	want := "" +
+		"    LD A, (table)            ; 00:0000 FA 08 00\n" +
+		"    CALL sub_0009            ; 00:0003 CD 09 00\n" +
+		"loc_0006:\n" +
+		"    JR loc_0006              ; 00:0006 18 FE\n" +
+		"table:\n" +
+		"    db $AA                   ; 00:0008\n" +
+		"sub_0009:\n" +
+		"    RET                      ; 00:0009 C9\n"
+	var b strings.Builder
+	n, err := l.WriteTo(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != want || n != int64(len(want)) {
+		t.Errorf("wrote %d bytes:\n%s\nwant %d bytes:\n%s", n, b.String(), len(want), want)
+	}
+}
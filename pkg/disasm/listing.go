@@ -0,0 +1,217 @@
+package disasm
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+type Mode int
+
+const (
+	// LINEAR decodes every byte of the bank in order.
+	LINEAR Mode = iota
+	// RECURSIVE follows control flow from the entry points and leaves
+	// anything it never reaches as data.
+	RECURSIVE
+)
+
+type romBank struct {
+	rom   []byte
+	start uint16
+	end   uint16
+	base  int
+}
+
+// Bank returns bank n of rom mapped at the address it is seen at by the CPU:
+// 0x0000-0x3FFF for bank 0 and 0x4000-0x7FFF for the rest. Addresses outside
+// the window read as 0xFF.
+func Bank(rom []byte, n int) Memory {
+	start, end := bankWindow(n)
+	return &romBank{rom: rom, start: start, end: end, base: n * 0x4000}
+}
+
+func bankWindow(n int) (uint16, uint16) {
+	if n == 0 {
+		return 0x0000, 0x3FFF
+	}
+	return 0x4000, 0x7FFF
+}
+
+func (b *romBank) Peek(addr uint16) byte {
+	if addr < b.start || addr > b.end {
+		return 0xFF
+	}
+	i := b.base + int(addr-b.start)
+	if i >= len(b.rom) {
+		return 0xFF
+	}
+	return b.rom[i]
+}
+
+// Listing is the disassembly of one ROM bank.
+type Listing struct {
+	Bank         int
+	Instructions []Instruction
+	// Labels names every jump, call and restart target inside the bank.
+	// Callers may add their own, such as labels from a symbol file, before
+	// calling WriteTo.
+	Labels map[uint16]string
+
+	mem        Memory
+	start, end uint16
+}
+
+// List disassembles bank n of rom. In RECURSIVE mode decoding starts at
+// entries, or at the restart and interrupt vectors and 0x0100 for bank 0
+// and the start of the bank otherwise when none are given.
+func List(rom []byte, n int, mode Mode, entries ...uint16) *Listing {
+	l := &Listing{Bank: n, Labels: map[uint16]string{}, mem: Bank(rom, n)}
+	l.start, l.end = bankWindow(n)
+	avail := len(rom) - n*0x4000
+	if avail <= 0 {
+		return l
+	}
+	if avail < 0x4000 {
+		l.end = l.start + uint16(avail) - 1
+	}
+
+	if mode == LINEAR {
+		for addr := int(l.start); addr <= int(l.end); {
+			inst := Decode(l.mem, uint16(addr))
+			if addr+inst.Len()-1 > int(l.end) {
+				break
+			}
+			l.Instructions = append(l.Instructions, inst)
+			addr += inst.Len()
+		}
+	} else {
+		l.recurse(entries)
+	}
+
+	for _, inst := range l.Instructions {
+		target, ok := inst.Target()
+		if !ok || target < l.start || target > l.end {
+			continue
+		}
+		if inst.IsCall() {
+			l.Labels[target] = fmt.Sprintf("sub_%04X", target)
+		} else if _, named := l.Labels[target]; !named {
+			l.Labels[target] = fmt.Sprintf("loc_%04X", target)
+		}
+	}
+	return l
+}
+
+func (l *Listing) recurse(entries []uint16) {
+	if len(entries) == 0 {
+		if l.Bank == 0 {
+			for v := uint16(0); v <= 0x60; v += 8 {
+				entries = append(entries, v)
+			}
+			entries = append(entries, 0x0100)
+		} else {
+			entries = append(entries, l.start)
+		}
+	}
+
+	seen := map[uint16]bool{}
+	work := append([]uint16(nil), entries...)
+	for len(work) > 0 {
+		addr := work[len(work)-1]
+		work = work[:len(work)-1]
+		for !seen[addr] && addr >= l.start && addr <= l.end {
+			inst := Decode(l.mem, addr)
+			if int(addr)+inst.Len()-1 > int(l.end) {
+				break
+			}
+			seen[addr] = true
+			l.Instructions = append(l.Instructions, inst)
+			if target, ok := inst.Target(); ok {
+				work = append(work, target)
+			}
+			if inst.EndsBlock() {
+				break
+			}
+			addr += uint16(inst.Len())
+		}
+	}
+	sort.Slice(l.Instructions, func(i, j int) bool {
+		return l.Instructions[i].Addr < l.Instructions[j].Addr
+	})
+}
+
+// Name returns the label at addr, for use with Instruction.Format.
+func (l *Listing) Name(addr uint16) (string, bool) {
+	name, ok := l.Labels[addr]
+	return name, ok
+}
+
+// WriteTo writes the listing as assembly, with bytes that were never decoded
+// written out as db lines.
+func (l *Listing) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	addr := int(l.start)
+	for _, inst := range l.Instructions {
+		if int(inst.Addr) < addr {
+			continue
+		}
+		l.writeData(cw, addr, int(inst.Addr))
+		if name, ok := l.Labels[inst.Addr]; ok {
+			fmt.Fprintf(cw, "%s:\n", name)
+		}
+		fmt.Fprintf(cw, "    %-24s ; %02X:%04X %s\n", inst.Format(l.Name), l.Bank, inst.Addr, hexBytes(inst.Bytes))
+		addr = int(inst.Addr) + inst.Len()
+	}
+	l.writeData(cw, addr, int(l.end)+1)
+	if err := cw.w.Flush(); err != nil {
+		return cw.n, err
+	}
+	return cw.n, cw.err
+}
+
+func (l *Listing) writeData(w io.Writer, from, to int) {
+	for from < to {
+		if name, ok := l.Labels[uint16(from)]; ok {
+			fmt.Fprintf(w, "%s:\n", name)
+		}
+		n := 1
+		for n < 8 && from+n < to {
+			if _, ok := l.Labels[uint16(from+n)]; ok {
+				break
+			}
+			n++
+		}
+		vals := make([]string, n)
+		for i := range vals {
+			vals[i] = fmt.Sprintf("$%02X", l.mem.Peek(uint16(from+i)))
+		}
+		fmt.Fprintf(w, "    db %-21s ; %02X:%04X\n", strings.Join(vals, ", "), l.Bank, from)
+		from += n
+	}
+}
+
+func hexBytes(b []byte) string {
+	s := make([]string, len(b))
+	for i, v := range b {
+		s[i] = fmt.Sprintf("%02X", v)
+	}
+	return strings.Join(s, " ")
+}
+
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	if err != nil && c.err == nil {
+		c.err = err
+	}
+	return n, err
+}
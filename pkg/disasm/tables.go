@@ -0,0 +1,97 @@
+package disasm
+
+// cycles holds the T-cycles taken by each unprefixed opcode. For conditional
+// jumps, calls and returns this is the cost when the branch is taken; the
+// cost when it isn't is in cyclesNotTaken.
+var cycles = [256]byte{
+	//  0   1   2   3   4   5   6   7   8   9   A   B   C   D   E   F
+	4, 12, 8, 8, 4, 4, 8, 4, 20, 8, 8, 8, 4, 4, 8, 4, // 0x
+	4, 12, 8, 8, 4, 4, 8, 4, 12, 8, 8, 8, 4, 4, 8, 4, // 1x
+	12, 12, 8, 8, 4, 4, 8, 4, 12, 8, 8, 8, 4, 4, 8, 4, // 2x
+	12, 12, 8, 8, 12, 12, 12, 4, 12, 8, 8, 8, 4, 4, 8, 4, // 3x
+	4, 4, 4, 4, 4, 4, 8, 4, 4, 4, 4, 4, 4, 4, 8, 4, // 4x
+	4, 4, 4, 4, 4, 4, 8, 4, 4, 4, 4, 4, 4, 4, 8, 4, // 5x
+	4, 4, 4, 4, 4, 4, 8, 4, 4, 4, 4, 4, 4, 4, 8, 4, // 6x
+	8, 8, 8, 8, 8, 8, 4, 8, 4, 4, 4, 4, 4, 4, 8, 4, // 7x
+	4, 4, 4, 4, 4, 4, 8, 4, 4, 4, 4, 4, 4, 4, 8, 4, // 8x
+	4, 4, 4, 4, 4, 4, 8, 4, 4, 4, 4, 4, 4, 4, 8, 4, // 9x
+	4, 4, 4, 4, 4, 4, 8, 4, 4, 4, 4, 4, 4, 4, 8, 4, // Ax
+	4, 4, 4, 4, 4, 4, 8, 4, 4, 4, 4, 4, 4, 4, 8, 4, // Bx
+	20, 12, 16, 16, 24, 16, 8, 16, 20, 16, 16, 4, 24, 24, 8, 16, // Cx
+	20, 12, 16, 0, 24, 16, 8, 16, 20, 16, 16, 0, 24, 0, 8, 16, // Dx
+	12, 12, 8, 0, 0, 16, 8, 16, 16, 4, 16, 0, 0, 0, 8, 16, // Ex
+	12, 12, 8, 4, 0, 16, 8, 16, 12, 8, 16, 4, 0, 0, 8, 16, // Fx
+}
+
+var cyclesNotTaken = map[byte]byte{
+	0x20: 8, 0x28: 8, 0x30: 8, 0x38: 8,
+	0xC0: 8, 0xC8: 8, 0xD0: 8, 0xD8: 8,
+	0xC2: 12, 0xCA: 12, 0xD2: 12, 0xDA: 12,
+	0xC4: 12, 0xCC: 12, 0xD4: 12, 0xDC: 12,
+}
+
+func cbCycles(op byte) byte {
+	switch {
+	case op&7 != 6:
+		return 8
+	case op>>6 == 1:
+		return 12
+	default:
+		return 16
+	}
+}
+
+// flags describes how an instruction affects Z, N, H and C, in that order:
+// the flag letter if it depends on the result, 0 or 1 if it is forced, and
+// - if it is left alone.
+func flags(mnemonic string, operands []string) string {
+	switch mnemonic {
+	case "INC", "DEC":
+		if len(operands[0]) == 2 && operands[0] != "(HL)" {
+			return "----"
+		}
+		if mnemonic == "INC" {
+			return "Z0H-"
+		}
+		return "Z1H-"
+	case "ADD":
+		switch operands[0] {
+		case "HL":
+			return "-0HC"
+		case "SP":
+			return "00HC"
+		}
+		return "Z0HC"
+	case "ADC":
+		return "Z0HC"
+	case "SUB", "SBC", "CP":
+		return "Z1HC"
+	case "AND":
+		return "Z010"
+	case "OR", "XOR", "SWAP":
+		return "Z000"
+	case "LD":
+		if len(operands) == 2 && operands[1] == "SP+i8" {
+			return "00HC"
+		}
+	case "RLCA", "RLA", "RRCA", "RRA":
+		return "000C"
+	case "RLC", "RL", "RRC", "RR", "SLA", "SRA", "SRL":
+		return "Z00C"
+	case "BIT":
+		return "Z01-"
+	case "DAA":
+		return "Z-0C"
+	case "CPL":
+		return "-11-"
+	case "SCF":
+		return "-001"
+	case "CCF":
+		return "-00C"
+	case "POP":
+		if operands[0] == "AF" {
+			return "ZNHC"
+		}
+	}
+	return "----"
+}
@@ -0,0 +1,409 @@
+// Package gdbstub serves a debugger.Debugger over the GDB remote serial
+// protocol.
+//
+// Registers are exposed as six 16-bit little-endian registers in the order
+// af, bc, de, hl, sp, pc, described to the client through target.xml.
+package gdbstub
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/ifamakes/emu/pkg/debugger"
+)
+
+var registerNames = []string{"af", "bc", "de", "hl", "sp", "pc"}
+
+const targetXML = `<?xml version="1.0"?>
+<!DOCTYPE target SYSTEM "gdb-target.dtd">
+<target version="1.0">
+  <feature name="org.gnu.gdb.sm83.cpu">
+    <reg name="af" bitsize="16" type="int"/>
+    <reg name="bc" bitsize="16" type="int"/>
+    <reg name="de" bitsize="16" type="int"/>
+    <reg name="hl" bitsize="16" type="data_ptr"/>
+    <reg name="sp" bitsize="16" type="data_ptr"/>
+    <reg name="pc" bitsize="16" type="code_ptr"/>
+  </feature>
+</target>`
+
+// Server speaks the remote protocol for one client connection at a time.
+type Server struct {
+	d      *debugger.Debugger
+	points map[string]int
+}
+
+func NewServer(d *debugger.Debugger) *Server {
+	return &Server{d: d, points: map[string]int{}}
+}
+
+// ListenAndServe accepts clients on addr, such as "127.0.0.1:2345", and
+// serves them one after another until the listener fails.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		err = s.Serve(conn)
+		conn.Close()
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, errDetached) {
+			return err
+		}
+	}
+}
+
+var errDetached = errors.New("client detached")
+
+// packet is a decoded packet, or an out of band Ctrl-C when brk is set.
+type packet struct {
+	data string
+	brk  bool
+	err  error
+}
+
+// Serve handles a single client until it detaches or disconnects. The
+// goroutine reading conn exits once conn is closed, which is up to the
+// caller.
+func (s *Server) Serve(conn io.ReadWriter) error {
+	packets := make(chan packet)
+	done := make(chan struct{})
+	defer close(done)
+	go readPackets(bufio.NewReader(conn), conn, packets, done)
+
+	for p := range packets {
+		if p.err != nil {
+			return p.err
+		}
+		if p.brk {
+			continue
+		}
+		var reply string
+		switch {
+		case strings.HasPrefix(p.data, "c"):
+			s.setPC(p.data[1:])
+			reply = s.resume(packets, s.d.Continue)
+		case strings.HasPrefix(p.data, "s"):
+			s.setPC(p.data[1:])
+			reply = s.resume(packets, s.d.Step)
+		case p.data == "k":
+			return errDetached
+		case p.data == "D":
+			writePacket(conn, "OK")
+			return errDetached
+		default:
+			reply = s.handle(p.data)
+		}
+		if err := writePacket(conn, reply); err != nil {
+			return err
+		}
+	}
+	return io.EOF
+}
+
+// resume runs the debugger in the background, pausing it if the client
+// sends Ctrl-C, and returns the stop reply.
+func (s *Server) resume(packets chan packet, run func() (debugger.Stop, error)) string {
+	type result struct {
+		stop debugger.Stop
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		stop, err := run()
+		done <- result{stop, err}
+	}()
+	for {
+		select {
+		case r := <-done:
+			if r.err != nil {
+				return "S05"
+			}
+			return stopReply(r.stop)
+		case p, ok := <-packets:
+			if !ok || p.err != nil || p.brk {
+				s.d.Pause()
+			}
+			if !ok {
+				packets = nil
+			}
+		}
+	}
+}
+
+func stopReply(stop debugger.Stop) string {
+	switch stop.Kind {
+	case debugger.STOP_BREAKPOINT:
+		if stop.Breakpoint.Kind == debugger.BREAK_PC {
+			return "T05swbreak:;"
+		}
+	case debugger.STOP_WATCHPOINT:
+		kind := "watch"
+		switch stop.Watchpoint.Kind {
+		case debugger.WATCH_READ:
+			kind = "rwatch"
+		case debugger.WATCH_ACCESS:
+			kind = "awatch"
+		}
+		return fmt.Sprintf("T05%s:%x;", kind, stop.Addr)
+	case debugger.STOP_INTERRUPTED:
+		return "S02"
+	}
+	return "S05"
+}
+
+func (s *Server) setPC(arg string) {
+	if arg == "" {
+		return
+	}
+	if pc, err := strconv.ParseUint(arg, 16, 64); err == nil {
+		s.d.GBC.PC = uint16(pc)
+	}
+}
+
+func (s *Server) handle(data string) string {
+	gbc := s.d.GBC
+	switch {
+	case data == "?":
+		return "S05"
+	case data == "g":
+		var b strings.Builder
+		for _, name := range registerNames {
+			v, _ := s.d.Register(name)
+			fmt.Fprintf(&b, "%02x%02x", byte(v), byte(v>>8))
+		}
+		return b.String()
+	case strings.HasPrefix(data, "G"):
+		raw, err := hex.DecodeString(data[1:])
+		if err != nil || len(raw) < 2*len(registerNames) {
+			return "E01"
+		}
+		for i, name := range registerNames {
+			s.d.SetRegister(name, uint16(raw[2*i])|uint16(raw[2*i+1])<<8)
+		}
+		return "OK"
+	case strings.HasPrefix(data, "p"):
+		n, err := strconv.ParseUint(data[1:], 16, 8)
+		if err != nil || int(n) >= len(registerNames) {
+			return "E01"
+		}
+		v, _ := s.d.Register(registerNames[n])
+		return fmt.Sprintf("%02x%02x", byte(v), byte(v>>8))
+	case strings.HasPrefix(data, "P"):
+		parts := strings.SplitN(data[1:], "=", 2)
+		n, err := strconv.ParseUint(parts[0], 16, 8)
+		if err != nil || len(parts) != 2 || int(n) >= len(registerNames) {
+			return "E01"
+		}
+		raw, err := hex.DecodeString(parts[1])
+		if err != nil || len(raw) < 2 {
+			return "E01"
+		}
+		s.d.SetRegister(registerNames[n], uint16(raw[0])|uint16(raw[1])<<8)
+		return "OK"
+	case strings.HasPrefix(data, "m"):
+		addr, n, ok := parseAddrLen(data[1:])
+		if !ok {
+			return "E01"
+		}
+		buf := make([]byte, n)
+		for i := range buf {
+			buf[i] = gbc.Peek(addr + uint16(i))
+		}
+		return hex.EncodeToString(buf)
+	case strings.HasPrefix(data, "M"):
+		parts := strings.SplitN(data[1:], ":", 2)
+		addr, n, ok := parseAddrLen(parts[0])
+		if !ok || len(parts) != 2 {
+			return "E01"
+		}
+		raw, err := hex.DecodeString(parts[1])
+		if err != nil || len(raw) != n {
+			return "E01"
+		}
+		for i, v := range raw {
+			gbc.Poke(addr+uint16(i), v)
+		}
+		return "OK"
+	case strings.HasPrefix(data, "Z"), strings.HasPrefix(data, "z"):
+		return s.point(data)
+	case strings.HasPrefix(data, "qSupported"):
+		return "PacketSize=4000;qXfer:features:read+;swbreak+"
+	case strings.HasPrefix(data, "qXfer:features:read:target.xml:"):
+		off, n, ok := parseAddrLen(strings.TrimPrefix(data, "qXfer:features:read:target.xml:"))
+		if !ok || int(off) >= len(targetXML) {
+			return "l"
+		}
+		chunk := targetXML[off:]
+		if len(chunk) > n {
+			return "m" + chunk[:n]
+		}
+		return "l" + chunk
+	case data == "qAttached":
+		return "1"
+	case data == "qfThreadInfo":
+		return "m1"
+	case data == "qsThreadInfo":
+		return "l"
+	case data == "qC":
+		return "QC1"
+	case strings.HasPrefix(data, "H"):
+		return "OK"
+	}
+	return ""
+}
+
+// point handles Z and z packets: type 0 and 1 are breakpoints, 2 to 4 are
+// write, read and access watchpoints.
+func (s *Server) point(data string) string {
+	fields := strings.Split(data[1:], ",")
+	if len(fields) < 3 {
+		return "E01"
+	}
+	addr, err := strconv.ParseUint(fields[1], 16, 64)
+	if err != nil {
+		return "E01"
+	}
+	length, err := strconv.ParseUint(fields[2], 16, 64)
+	if err != nil {
+		return "E01"
+	}
+	key := fields[0] + "," + fields[1] + "," + fields[2]
+
+	if data[0] == 'z' {
+		id, ok := s.points[key]
+		if !ok {
+			return "E01"
+		}
+		delete(s.points, key)
+		s.d.Delete(id)
+		return "OK"
+	}
+	if _, ok := s.points[key]; ok {
+		return "OK"
+	}
+
+	var id int
+	switch fields[0] {
+	case "0", "1":
+		id = s.d.AddBreakpoint(debugger.Breakpoint{Kind: debugger.BREAK_PC, Addr: uint16(addr)}).ID
+	case "2", "3", "4":
+		kind := map[string]debugger.WatchKind{"2": debugger.WATCH_WRITE, "3": debugger.WATCH_READ, "4": debugger.WATCH_ACCESS}[fields[0]]
+		if length == 0 {
+			length = 1
+		}
+		w := debugger.Watchpoint{Kind: kind, From: uint16(addr), To: uint16(addr + length - 1)}
+		id = s.d.AddWatchpoint(w).ID
+	default:
+		return ""
+	}
+	s.points[key] = id
+	return "OK"
+}
+
+func parseAddrLen(s string) (uint16, int, bool) {
+	parts := strings.SplitN(s, ",", 2)
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	addr, err := strconv.ParseUint(parts[0], 16, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	n, err := strconv.ParseUint(parts[1], 16, 16)
+	if err != nil {
+		return 0, 0, false
+	}
+	return uint16(addr), int(n), true
+}
+
+// readPackets decodes packets from r, acknowledging each one, until r fails
+// or done is closed.
+func readPackets(r *bufio.Reader, w io.Writer, out chan<- packet, done <-chan struct{}) {
+	defer close(out)
+	send := func(p packet) bool {
+		select {
+		case out <- p:
+			return p.err == nil
+		case <-done:
+			return false
+		}
+	}
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			send(packet{err: err})
+			return
+		}
+		switch c {
+		case 0x03:
+			if !send(packet{brk: true}) {
+				return
+			}
+			continue
+		case '$':
+		default:
+			continue
+		}
+		body, err := r.ReadString('#')
+		if err != nil {
+			send(packet{err: err})
+			return
+		}
+		body = body[:len(body)-1]
+		var sum [2]byte
+		if _, err := io.ReadFull(r, sum[:]); err != nil {
+			send(packet{err: err})
+			return
+		}
+		want, err := strconv.ParseUint(string(sum[:]), 16, 8)
+		if err != nil || byte(want) != checksum(body) {
+			w.Write([]byte{'-'})
+			continue
+		}
+		w.Write([]byte{'+'})
+		if !send(packet{data: unescape(body)}) {
+			return
+		}
+	}
+}
+
+func unescape(s string) string {
+	if !strings.Contains(s, "}") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '}' && i+1 < len(s) {
+			i++
+			b.WriteByte(s[i] ^ 0x20)
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func checksum(s string) byte {
+	var sum byte
+	for i := 0; i < len(s); i++ {
+		sum += s[i]
+	}
+	return sum
+}
+
+func writePacket(w io.Writer, data string) error {
+	_, err := fmt.Fprintf(w, "$%s#%02x", data, checksum(data))
+	return err
+}
@@ -0,0 +1,107 @@
+package gdbstub
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ifamakes/emu/pkg/debugger"
+	"github.com/ifamakes/emu/pkg/hardware"
+)
+
+// client speaks the client side of the protocol over one end of a pipe.
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// send writes data as a packet, checks that it is acknowledged and returns
+// the reply.
+func (c *client) send(data string) string {
+	c.t.Helper()
+	if err := writePacket(c.conn, data); err != nil {
+		c.t.Fatalf("%s: %v", data, err)
+	}
+	if ack, err := c.r.ReadByte(); err != nil || ack != '+' {
+		c.t.Fatalf("%s: ack %q, %v", data, ack, err)
+	}
+	if _, err := c.r.ReadString('$'); err != nil {
+		c.t.Fatalf("%s: %v", data, err)
+	}
+	body, err := c.r.ReadString('#')
+	if err != nil {
+		c.t.Fatalf("%s: %v", data, err)
+	}
+	var sum [2]byte
+	if _, err := io.ReadFull(c.r, sum[:]); err != nil {
+		c.t.Fatalf("%s: %v", data, err)
+	}
+	body = strings.TrimSuffix(body, "#")
+	if want := fmt.Sprintf("%02x", checksum(body)); string(sum[:]) != want {
+		c.t.Errorf("%s: reply %q has checksum %s, want %s", data, body, sum, want)
+	}
+	return body
+}
+
+func TestServe(t *testing.T) {
+	rom := make([]byte, 0x8000)
+	copy(rom[0x100:], []byte{0x00, 0x00, 0x18, 0xFC}) // NOP; NOP; JR -4
+	gbc, err := hardware.New(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := debugger.New(gbc)
+	goroutines := runtime.NumGoroutine()
+
+	server, conn := net.Pipe()
+	served := make(chan error, 1)
+	go func() {
+		served <- NewServer(d).Serve(server)
+		server.Close()
+	}()
+	c := &client{t, conn, bufio.NewReader(conn)}
+
+	if reply := c.send("?"); reply != "S05" {
+		t.Errorf("? = %q, want S05", reply)
+	}
+	var regs strings.Builder
+	for _, name := range registerNames {
+		v, _ := d.Register(name)
+		fmt.Fprintf(&regs, "%02x%02x", byte(v), byte(v>>8))
+	}
+	if reply := c.send("g"); reply != regs.String() || !strings.HasSuffix(reply, "feff0001") {
+		t.Errorf("g = %q, want %q ending in sp FFFE and pc 0100", reply, regs.String())
+	}
+	if reply := c.send("m100,4"); reply != "000018fc" {
+		t.Errorf("m100,4 = %q, want 000018fc", reply)
+	}
+	if reply := c.send("Z0,101,1"); reply != "OK" {
+		t.Errorf("Z0,101,1 = %q, want OK", reply)
+	}
+	if reply := c.send("c"); reply != "T05swbreak:;" || gbc.PC != 0x101 {
+		t.Errorf("c = %q at %04X, want T05swbreak:; at 0101", reply, gbc.PC)
+	}
+
+	if err := writePacket(conn, "k"); err != nil {
+		t.Fatal(err)
+	}
+	c.r.ReadByte()
+	if err := <-served; err != errDetached {
+		t.Errorf("Serve returned %v after k, want errDetached", err)
+	}
+	conn.Close()
+
+	// The reader must not be left blocked once Serve has returned.
+	for start := time.Now(); runtime.NumGoroutine() > goroutines; {
+		if time.Since(start) > time.Second {
+			t.Fatalf("%d goroutines left running, want %d", runtime.NumGoroutine(), goroutines)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
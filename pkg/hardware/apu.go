@@ -1 +1,6 @@
 package hardware
+
+// AudioSink receives the stereo samples produced by the APU.
+type AudioSink interface {
+	PushSample(left, right int16)
+}
@@ -0,0 +1,127 @@
+package hardware
+
+import "testing"
+
+// benchROM is a synthetic mix of loads, ALU, CB, stack and memory
+// instructions in a loop that never ends.
+func benchROM() []byte {
+	return testROM(
+		0x31, 0xFE, 0xFF, // LD SP, FFFE
+		0x21, 0x00, 0xC0, // LD HL, C000
+		0x06, 0x10, // loop: LD B, 10
+		0x3E, 0x01, // LD A, 01
+		0x80,       // ADD A, B
+		0xCB, 0x37, // SWAP A
+		0x22,       // LD (HL+), A
+		0xC5,       // PUSH BC
+		0xC1,       // POP BC
+		0xA8,       // XOR B
+		0xCB, 0x18, // RR B
+		0x05,       // DEC B
+		0x20, 0xF3, // JR NZ, -13
+		0xCB, 0x7C, // BIT 7, H
+		0x28, 0xE9, // JR Z, loop
+		0x21, 0x00, 0xC0, // LD HL, C000
+		0x18, 0xE4, // JR loop
+	)
+}
+
+func benchmarkFrames(b *testing.B, opts ...Option) {
+	gbc, err := New(benchROM(), opts...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := gbc.RunFrame(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRunFrame(b *testing.B)           { benchmarkFrames(b) }
+func BenchmarkRunFrameBlockCache(b *testing.B) { benchmarkFrames(b, WithBlockCache()) }
+
+func TestBlockCacheMatchesStep(t *testing.T) {
+	plain, err := New(benchROM())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached, err := New(benchROM(), WithBlockCache())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100000; i++ {
+		plain.Step()
+		cached.Step()
+		if plain.Register != cached.Register || plain.Cycles() != cached.Cycles() {
+			t.Fatalf("step %d: cached %+v after %d cycles, want %+v after %d",
+				i, cached.Register, cached.Cycles(), plain.Register, plain.Cycles())
+		}
+	}
+}
+
+func TestBlockCacheMatchesRunFrame(t *testing.T) {
+	// The loop raises the timer interrupt through IF every pass, and the
+	// handler at 0050 counts them in C.
+	irq := testROM(
+		0x31, 0xFE, 0xFF, // LD SP, FFFE
+		0x3E, 0x04, // LD A, 04
+		0xE0, 0xFF, // LDH (FF), A: IE
+		0xFB,       // EI
+		0x04,       // loop: INC B
+		0x3E, 0x04, // LD A, 04
+		0xE0, 0x0F, // LDH (0F), A: IF
+		0x00,       // NOP
+		0x18, 0xF8, // JR loop
+	)
+	copy(irq[0x50:], []byte{0x0C, 0xD9}) // INC C; RETI
+	// The routine at C000 starts as INC B and is rewritten to DEC B through
+	// its echo at E000 once it has run.
+	echo := testROM(
+		0x31, 0xFE, 0xFF, // LD SP, FFFE
+		0x3E, 0x04, // LD A, 04: INC B
+		0xEA, 0x00, 0xC0, // LD (C000), A
+		0x3E, 0xC9, // LD A, C9: RET
+		0xEA, 0x01, 0xC0, // LD (C001), A
+		0xCD, 0x00, 0xC0, // loop: CALL C000
+		0x3E, 0x05, // LD A, 05: DEC B
+		0xEA, 0x00, 0xE0, // LD (E000), A
+		0x18, 0xF6, // JR loop
+	)
+	for name, rom := range map[string][]byte{"bench": benchROM(), "interrupts": irq, "echo": echo} {
+		plain, err := New(rom)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cached, err := New(rom, WithBlockCache())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 60; i++ {
+			plain.RunFrame()
+			cached.RunFrame()
+			if plain.Register != cached.Register || plain.Cycles() != cached.Cycles() {
+				t.Fatalf("%s frame %d: cached %+v after %d cycles, want %+v after %d",
+					name, i, cached.Register, cached.Cycles(), plain.Register, plain.Cycles())
+			}
+		}
+		if name == "interrupts" && plain.REG[C] == 0 {
+			t.Errorf("no interrupts taken")
+		}
+	}
+}
+
+func TestStepDoesNotAllocate(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithBlockCache()}} {
+		gbc, err := New(benchROM(), opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gbc.RunFrame()
+		if n := testing.AllocsPerRun(10000, func() { gbc.Step() }); n != 0 {
+			t.Errorf("Step allocated %v times per call with %d options", n, len(opts))
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package hardware
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// BESS (Best Effort Save State) is the block format SameBoy and other
+// emulators append to their own save states. The file ends with the offset
+// of the first block and the "BESS" magic; each block is a four byte id, a
+// little-endian uint32 length and a payload. Large buffers such as RAM are
+// stored earlier in the file and referenced from the CORE block by size and
+// offset.
+
+var ErrNotBESS = errors.New("no BESS footer")
+
+const bessCoreSize = 0xD0
+
+type bessBuffer struct {
+	Size   uint32
+	Offset uint32
+}
+
+type bessCore struct {
+	Major, Minor           uint16
+	Model                  [4]byte
+	PC, AF, BC, DE, HL, SP uint16
+	IME, IE, State, _      byte
+	IO                     [0x80]byte
+	RAM, VRAM, MBCRAM      bessBuffer
+	OAM, HRAM              bessBuffer
+	BGPalettes, OBPalettes bessBuffer
+}
+
+type bessMBCWrite struct {
+	Addr  uint16
+	Value byte
+}
+
+// SaveStateBESS writes a native save state with a BESS footer appended, which
+// LoadState and other emulators' BESS loaders can both read.
+func (gbc *GBC) SaveStateBESS(w io.Writer) error {
+	var file bytes.Buffer
+	if err := gbc.SaveState(&file); err != nil {
+		return err
+	}
+
+	buffer := func(data []byte) bessBuffer {
+		b := bessBuffer{Size: uint32(len(data)), Offset: uint32(file.Len())}
+		file.Write(data)
+		return b
+	}
+	core := bessCore{
+		Major: 1, Minor: 1,
+		Model: [4]byte{'G', 'D', 'B', ' '},
+		PC:    gbc.PC, AF: gbc.Reg16(AF), BC: gbc.Reg16(BC),
+		DE: gbc.Reg16(DE), HL: gbc.Reg16(HL), SP: gbc.SP,
+		IE: gbc.Peek(0xFFFF),
+	}
+	if gbc.model == CGB {
+		core.Model = [4]byte{'C', 'C', 'E', ' '}
+	}
+	if gbc.IME {
+		core.IME = 1
+	}
+	switch {
+	case gbc.halted:
+		core.State = 1
+	case gbc.stoped:
+		core.State = 2
+	}
+	for i := range core.IO {
+		core.IO[i] = gbc.Peek(0xFF00 + uint16(i))
+	}
+
+	core.RAM = buffer(gbc.wramBanks())
+	core.VRAM = buffer(gbc.video.vram[0][:])
+	if gbc.model == CGB {
+		core.VRAM = buffer(append(gbc.video.vram[0][:], gbc.video.vram[1][:]...))
+	}
+	core.MBCRAM = buffer(gbc.SaveRAM())
+	core.OAM = buffer(gbc.peekRange(0xFE00, 0xA0))
+	core.HRAM = buffer(gbc.peekRange(0xFF80, 0x7F))
+	if gbc.model == CGB {
+		bg, ob := gbc.PaletteRAM(false), gbc.PaletteRAM(true)
+		core.BGPalettes = buffer(bg[:])
+		core.OBPalettes = buffer(ob[:])
+	}
+
+	first := file.Len()
+	block := func(id string, payload interface{}) {
+		var body bytes.Buffer
+		binary.Write(&body, binary.LittleEndian, payload)
+		file.WriteString(id)
+		binary.Write(&file, binary.LittleEndian, uint32(body.Len()))
+		file.Write(body.Bytes())
+	}
+	block("NAME", []byte("emu"))
+	var info [0x12]byte
+	copy(info[:], gbc.cart.header[:0x10])
+	copy(info[0x10:], gbc.cart.header[0x1A:0x1C])
+	block("INFO", info)
+	block("CORE", core)
+	if writes := gbc.mbcWrites(); len(writes) > 0 {
+		block("MBC ", writes)
+	}
+	block("END ", []byte{})
+	binary.Write(&file, binary.LittleEndian, uint32(first))
+	file.WriteString("BESS")
+
+	_, err := w.Write(file.Bytes())
+	return err
+}
+
+// LoadBESS restores the machine from the BESS blocks at the end of a save
+// state written by any BESS-compatible emulator.
+func (gbc *GBC) LoadBESS(file []byte) error {
+	if len(file) < 8 || string(file[len(file)-4:]) != "BESS" {
+		return ErrNotBESS
+	}
+	offset := binary.LittleEndian.Uint32(file[len(file)-8:])
+	if uint64(offset) > uint64(len(file)-8) {
+		return ErrNotBESS
+	}
+	r := bytes.NewReader(file[offset : len(file)-8])
+
+	var core *bessCore
+	var writes []bessMBCWrite
+	for {
+		var id [4]byte
+		var n uint32
+		if _, err := io.ReadFull(r, id[:]); err != nil {
+			return ErrNotBESS
+		}
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return ErrNotBESS
+		}
+		if uint64(n) > uint64(r.Len()) {
+			return ErrNotBESS
+		}
+		payload := make([]byte, n)
+		io.ReadFull(r, payload)
+		switch string(id[:]) {
+		case "CORE":
+			if n < bessCoreSize {
+				return ErrNotBESS
+			}
+			core = &bessCore{}
+			binary.Read(bytes.NewReader(payload), binary.LittleEndian, core)
+		case "INFO":
+			if len(payload) >= 0x10 && !bytes.Equal(payload[:0x10], gbc.cart.header[:0x10]) {
+				return ErrStateCartridge
+			}
+		case "MBC ":
+			writes = make([]bessMBCWrite, n/3)
+			binary.Read(bytes.NewReader(payload), binary.LittleEndian, writes)
+		}
+		if string(id[:]) == "END " {
+			break
+		}
+	}
+	if core == nil {
+		return ErrNotBESS
+	}
+
+	buffer := func(b bessBuffer) []byte {
+		if b.Size == 0 || uint64(b.Offset)+uint64(b.Size) > uint64(len(file)) {
+			return nil
+		}
+		return file[b.Offset : b.Offset+b.Size]
+	}
+	gbc.loadWRAM(buffer(core.RAM))
+	gbc.loadVRAM(buffer(core.VRAM))
+	if sram := buffer(core.MBCRAM); len(sram) == gbc.cart.ramSize {
+		gbc.loadSaveRAM(sram)
+	}
+	gbc.pokeRange(0xFE00, buffer(core.OAM))
+	gbc.pokeRange(0xFF80, buffer(core.HRAM))
+	gbc.loadPaletteRAM(false, buffer(core.BGPalettes))
+	gbc.loadPaletteRAM(true, buffer(core.OBPalettes))
+	for i, v := range core.IO {
+		if addr := 0xFF00 + uint16(i); !memoryRegisters[addr] {
+			gbc.Poke(addr, v)
+		}
+	}
+	gbc.Poke(0xFFFF, core.IE)
+	for _, w := range writes {
+		gbc.Poke(w.Addr, w.Value)
+	}
+
+	gbc.PC, gbc.SP = core.PC, core.SP
+	gbc.SetReg16(AF, core.AF&0xFFF0)
+	gbc.SetReg16(BC, core.BC)
+	gbc.SetReg16(DE, core.DE)
+	gbc.SetReg16(HL, core.HL)
+	gbc.IME = core.IME != 0
+	gbc.setPendingIME = false
+	gbc.halted = core.State == 1
+	gbc.stoped = core.State == 2
+	gbc.bootROM = nil
+	return nil
+}
+
+func (gbc *GBC) peekRange(addr uint16, n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = gbc.Peek(addr + uint16(i))
+	}
+	return b
+}
+
+func (gbc *GBC) pokeRange(addr uint16, data []byte) {
+	for i, v := range data {
+		gbc.Poke(addr+uint16(i), v)
+	}
+}
+
+// mbcWrites returns the register writes that put the MBC back in its
+// current banking state.
+func (gbc *GBC) mbcWrites() []bessMBCWrite {
+	bank := gbc.romBank
+	enable := bessMBCWrite{0x0000, 0x00}
+	if gbc.ramEnabled {
+		enable.Value = 0x0A
+	}
+	switch gbc.cart.mbc {
+	case MBC1:
+		return []bessMBCWrite{enable, {0x6000, gbc.mbcMode}, {0x2000, mbc1LowBank(bank, gbc.cart.romMask)}, {0x4000, byte(gbc.ramBank)}}
+	case MBC2:
+		return []bessMBCWrite{enable, {0x2100, byte(bank)}}
+	case MBC3:
+		return []bessMBCWrite{enable, {0x2000, byte(bank)}, {0x4000, byte(gbc.ramBank)}}
+	case MBC5:
+		return []bessMBCWrite{enable, {0x2000, byte(bank)}, {0x3000, byte(bank >> 8)}, {0x4000, byte(gbc.ramBank)}}
+	}
+	return nil
+}
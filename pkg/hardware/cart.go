@@ -1,5 +1,10 @@
 package hardware
 
+import (
+	"errors"
+	"strings"
+)
+
 type MODEL byte
 
 type MBC byte
@@ -22,9 +27,243 @@ const (
 	HuC1
 )
 
+var (
+	ErrROMTooSmall     = errors.New("rom is smaller than the cartridge header")
+	ErrUnsupportedCart = errors.New("unsupported cartridge type")
+)
+
 type Cartridge struct {
-	title  string
-	mode   MODEL
-	header [50]byte
-	mbc    MBC
+	title   string
+	mode    MODEL
+	header  [50]byte
+	mbc     MBC
+	ramSize int
+	romMask int
+}
+
+func parseCartridge(rom []byte) (Cartridge, error) {
+	if len(rom) < 0x150 {
+		return Cartridge{}, ErrROMTooSmall
+	}
+	var cart Cartridge
+	copy(cart.header[:], rom[0x134:0x150])
+	cart.title = strings.TrimRight(string(rom[0x134:0x143]), "\x00")
+	if rom[0x143]&0x80 != 0 {
+		cart.mode = CGB
+	}
+
+	switch rom[0x147] {
+	case 0x00, 0x08, 0x09:
+		cart.mbc = MBC0
+	case 0x01, 0x02, 0x03:
+		cart.mbc = MBC1
+	case 0x05, 0x06:
+		cart.mbc = MBC2
+	case 0x0F, 0x10, 0x11, 0x12, 0x13:
+		cart.mbc = MBC3
+	case 0x19, 0x1A, 0x1B, 0x1C, 0x1D, 0x1E:
+		cart.mbc = MBC5
+	case 0x20:
+		cart.mbc = MBC6
+	case 0x22:
+		cart.mbc = MBC7
+	case 0xFF:
+		cart.mbc = HuC1
+	default:
+		return Cartridge{}, ErrUnsupportedCart
+	}
+
+	switch rom[0x149] {
+	case 0x02:
+		cart.ramSize = 0x2000
+	case 0x03:
+		cart.ramSize = 0x8000
+	case 0x04:
+		cart.ramSize = 0x20000
+	case 0x05:
+		cart.ramSize = 0x10000
+	}
+	if cart.mbc == MBC2 {
+		cart.ramSize = 0x200
+	}
+	// The MBC drops bank bits the ROM has no lines for, so a bank number
+	// wraps at the next power of two up from the ROM size.
+	for cart.romMask = 1; (cart.romMask+1)*0x4000 < len(rom); {
+		cart.romMask = cart.romMask<<1 | 1
+	}
+	return cart, nil
+}
+
+// Title returns the game title stored in the cartridge header.
+func (c *Cartridge) Title() string { return c.title }
+
+// trackBank follows writes to the MBC's banking registers so debugging
+// tools can tell which bank is mapped at 0x4000-0x7FFF, and so the RAM
+// enable, RAM bank and mode can be put back after eachRAMBank and saved in
+// states.
+func (gbc *GBC) trackBank(addr uint16, value byte) {
+	switch gbc.cart.mbc {
+	case MBC1:
+		switch {
+		case addr < 0x2000:
+			gbc.ramEnabled = value&0x0F == 0x0A
+		case addr < 0x4000:
+			bank := int(value & 0x1F)
+			if bank == 0 {
+				bank = 1
+			}
+			gbc.romBank = (gbc.ramBank<<5 | bank) & gbc.cart.romMask
+		case addr < 0x6000:
+			// One register holds both the upper ROM bank bits and the RAM
+			// bank; the mode picks which of them the MBC uses. Carts of
+			// 512KB or less have no lines for the ROM bits and ignore them.
+			gbc.ramBank = int(value & 0x03)
+			gbc.romBank = (gbc.ramBank<<5 | gbc.romBank&0x1F) & gbc.cart.romMask
+		default:
+			gbc.mbcMode = value & 1
+		}
+	case MBC2:
+		switch {
+		case addr >= 0x4000:
+		case addr&0x100 == 0:
+			gbc.ramEnabled = value&0x0F == 0x0A
+		default:
+			gbc.romBank = int(value & 0x0F)
+			if gbc.romBank == 0 {
+				gbc.romBank = 1
+			}
+		}
+	case MBC3:
+		switch {
+		case addr < 0x2000:
+			gbc.ramEnabled = value&0x0F == 0x0A
+		case addr < 0x4000:
+			gbc.romBank = int(value & 0x7F)
+			if gbc.romBank == 0 {
+				gbc.romBank = 1
+			}
+		case addr < 0x6000:
+			// 0x08-0x0C map an RTC register instead of a RAM bank.
+			gbc.ramBank = int(value)
+		}
+	case MBC5:
+		switch {
+		case addr < 0x2000:
+			gbc.ramEnabled = value&0x0F == 0x0A
+		case addr < 0x3000:
+			gbc.romBank = gbc.romBank&0x100 | int(value)
+		case addr < 0x4000:
+			gbc.romBank = gbc.romBank&0xFF | int(value&1)<<8
+		case addr < 0x6000:
+			gbc.ramBank = int(value & 0x0F)
+		}
+	}
+}
+
+// ROMBank returns the bank mapped at 0x4000-0x7FFF.
+func (gbc *GBC) ROMBank() int { return gbc.romBank }
+
+// BankOf returns the ROM bank addr currently reads from, which is 0 for
+// anything outside 0x4000-0x7FFF.
+func (gbc *GBC) BankOf(addr uint16) int {
+	if addr >= 0x4000 && addr < 0x8000 {
+		return gbc.romBank
+	}
+	return 0
+}
+
+// ErrSaveRAMSize is returned when a save RAM image doesn't match the size
+// declared in the cartridge header.
+var ErrSaveRAMSize = errors.New("save ram size does not match cartridge header")
+
+// loadSaveRAM copies sram into the cartridge RAM by driving the MBC registers
+// the same way a game would, one 8KB bank at a time.
+func (gbc *GBC) loadSaveRAM(sram []byte) error {
+	if len(sram) != gbc.cart.ramSize {
+		return ErrSaveRAMSize
+	}
+	gbc.eachRAMBank(func(bank int, base uint16, size int) {
+		for i := 0; i < size; i++ {
+			gbc.Poke(base+uint16(i), sram[bank*0x2000+i])
+		}
+	})
+	return nil
+}
+
+// SaveRAM returns a copy of the cartridge RAM, suitable for writing to a .sav
+// file and passing back in with WithSaveRAM.
+func (gbc *GBC) SaveRAM() []byte {
+	sram := make([]byte, gbc.cart.ramSize)
+	gbc.eachRAMBank(func(bank int, base uint16, size int) {
+		for i := 0; i < size; i++ {
+			sram[bank*0x2000+i] = gbc.Peek(base + uint16(i))
+		}
+	})
+	return sram
+}
+
+func (gbc *GBC) eachRAMBank(f func(bank int, base uint16, size int)) {
+	if gbc.cart.ramSize == 0 {
+		return
+	}
+	gbc.MMU.Write(0x0000, 0x0A)
+	if gbc.cart.mbc == MBC1 {
+		gbc.MMU.Write(0x6000, 0x01)
+	}
+	for bank := 0; bank*0x2000 < gbc.cart.ramSize; bank++ {
+		gbc.MMU.Write(0x4000, byte(bank))
+		size := gbc.cart.ramSize - bank*0x2000
+		if size > 0x2000 {
+			size = 0x2000
+		}
+		f(bank, 0xA000, size)
+	}
+	gbc.restoreBanking()
+}
+
+// restoreBanking programs the MBC with the tracked ROM bank, RAM bank, mode
+// and RAM enable, undoing whatever eachRAMBank or a state load wrote to it.
+func (gbc *GBC) restoreBanking() {
+	switch gbc.cart.mbc {
+	case MBC1:
+		gbc.MMU.Write(0x6000, gbc.mbcMode)
+	case MBC3, MBC5:
+		gbc.MMU.Write(0x4000, byte(gbc.ramBank))
+	}
+	gbc.selectROMBank(gbc.romBank)
+	enable := byte(0x00)
+	if gbc.ramEnabled {
+		enable = 0x0A
+	}
+	gbc.MMU.Write(0x0000, enable)
+}
+
+// selectROMBank programs the MBC to map bank at 0x4000-0x7FFF.
+func (gbc *GBC) selectROMBank(bank int) {
+	switch gbc.cart.mbc {
+	case MBC1:
+		// The RAM bank register carries the upper ROM bank bits.
+		gbc.MMU.Write(0x2000, mbc1LowBank(bank, gbc.cart.romMask))
+		gbc.MMU.Write(0x4000, byte(gbc.ramBank))
+	case MBC2:
+		gbc.MMU.Write(0x2100, byte(bank))
+	case MBC3:
+		gbc.MMU.Write(0x2000, byte(bank))
+	case MBC5:
+		gbc.MMU.Write(0x2000, byte(bank))
+		gbc.MMU.Write(0x3000, byte(bank>>8))
+	}
+	gbc.romBank = bank
+}
+
+// mbc1LowBank returns the value to write to MBC1's 0x2000 register to map
+// bank under mask. Bank 0 can only be reached on a cart small enough to
+// drop a bit of the register, so that bit is set to stop the MBC reading 0
+// as 1.
+func mbc1LowBank(bank, mask int) byte {
+	low := bank & 0x1F
+	if low == 0 {
+		low = (mask + 1) & 0x1F
+	}
+	return byte(low)
 }
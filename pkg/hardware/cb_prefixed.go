@@ -1,108 +1,105 @@
 package hardware
 
-import "log"
-
-func swap(gbc *GBC, r REGISTER8) {
-	old := gbc.REG[r]
-	gbc.REG[r] = (old << 4) | (old >> 4)
-	gbc.setFlags(gbc.REG[r] == 0, false, false, false)
+func swap(cpu *CPU, r REGISTER8) {
+	old := cpu.REG[r]
+	cpu.REG[r] = (old << 4) | (old >> 4)
+	cpu.setFlags(cpu.REG[r] == 0, false, false, false)
 }
 
-func rlc(gbc *GBC, r REGISTER8) {
-	_, bit7 := _rl(gbc, gbc.REG[r])
-	gbc.REG[r] = (gbc.REG[r] << 1) | bit7
-	gbc.setFlags(gbc.REG[r] == 0, false, false, bit7 != 0)
+func rlc(cpu *CPU, r REGISTER8) {
+	_, bit7 := _rl(cpu, cpu.REG[r])
+	cpu.REG[r] = (cpu.REG[r] << 1) | bit7
+	cpu.setFlags(cpu.REG[r] == 0, false, false, bit7 != 0)
 }
 
-func rl(gbc *GBC, r REGISTER8) {
-	carry, bit7 := _rl(gbc, gbc.REG[r])
-	gbc.REG[r] = (gbc.REG[r] << 1) | carry
-	gbc.setFlags(gbc.REG[r] == 0, false, false, bit7 != 0)
+func rl(cpu *CPU, r REGISTER8) {
+	carry, bit7 := _rl(cpu, cpu.REG[r])
+	cpu.REG[r] = (cpu.REG[r] << 1) | carry
+	cpu.setFlags(cpu.REG[r] == 0, false, false, bit7 != 0)
 }
 
-func rrc(gbc *GBC, r REGISTER8) {
-	_, bit0 := _rr(gbc, gbc.REG[r])
-	gbc.REG[r] = (gbc.REG[r] >> 1) | (bit0 << 7)
-	gbc.setFlags(gbc.REG[r] == 0, false, false, bit0 != 0)
+func rrc(cpu *CPU, r REGISTER8) {
+	_, bit0 := _rr(cpu, cpu.REG[r])
+	cpu.REG[r] = (cpu.REG[r] >> 1) | (bit0 << 7)
+	cpu.setFlags(cpu.REG[r] == 0, false, false, bit0 != 0)
 }
 
-func rr(gbc *GBC, r REGISTER8) {
-	carry, bit0 := _rl(gbc, gbc.REG[r])
-	log.Printf("current bit0: %0b", bit0)
-	gbc.REG[r] = (gbc.REG[r] >> 1) | (carry << 7)
-	gbc.setFlags(gbc.REG[r] == 0, false, false, bit0 != 0)
+func rr(cpu *CPU, r REGISTER8) {
+	carry, bit0 := _rr(cpu, cpu.REG[r])
+	cpu.REG[r] = (cpu.REG[r] >> 1) | (carry << 7)
+	cpu.setFlags(cpu.REG[r] == 0, false, false, bit0 != 0)
 }
 
-func sla(gbc *GBC, r REGISTER8) {
-	_, bit7 := _rl(gbc, gbc.REG[r])
-	gbc.REG[r] = (gbc.REG[r] << 1)
-	gbc.setFlags(gbc.REG[r] == 0, false, false, bit7 != 0)
+func sla(cpu *CPU, r REGISTER8) {
+	_, bit7 := _rl(cpu, cpu.REG[r])
+	cpu.REG[r] = (cpu.REG[r] << 1)
+	cpu.setFlags(cpu.REG[r] == 0, false, false, bit7 != 0)
 }
 
-func sra(gbc *GBC, r REGISTER8) {
-	_, bit0 := _rr(gbc, gbc.REG[r])
-	_, bit7 := _rl(gbc, gbc.REG[r])
-	gbc.REG[r] = (gbc.REG[r] >> 1) | (bit7 << 7)
-	gbc.setFlags(gbc.REG[r] == 0, false, false, bit0 != 0)
+func sra(cpu *CPU, r REGISTER8) {
+	_, bit0 := _rr(cpu, cpu.REG[r])
+	_, bit7 := _rl(cpu, cpu.REG[r])
+	cpu.REG[r] = (cpu.REG[r] >> 1) | (bit7 << 7)
+	cpu.setFlags(cpu.REG[r] == 0, false, false, bit0 != 0)
 }
 
-func srl(gbc *GBC, r REGISTER8) {
-	bit0 := gbc.REG[r] & 1
-	gbc.REG[r] = (gbc.REG[r] >> 1)
-	gbc.setFlags(gbc.REG[r] == 0, false, false, bit0 != 0)
+func srl(cpu *CPU, r REGISTER8) {
+	bit0 := cpu.REG[r] & 1
+	cpu.REG[r] = (cpu.REG[r] >> 1)
+	cpu.setFlags(cpu.REG[r] == 0, false, false, bit0 != 0)
 }
 
-func bit(gbc *GBC, bit int, r REGISTER8) {
-	_bit(gbc, bit, gbc.REG[r])
+func bit(cpu *CPU, bit int, r REGISTER8) {
+	_bit(cpu, bit, cpu.REG[r])
 }
 
-func set(gbc *GBC, bit int, r REGISTER8) {
-	gbc.REG[r] |= (1 << bit)
+func set(cpu *CPU, bit int, r REGISTER8) {
+	cpu.REG[r] |= (1 << bit)
 }
 
-func res(gbc *GBC, bit int, r REGISTER8) {
-	gbc.REG[r] &^= (1 << bit)
+func res(cpu *CPU, bit int, r REGISTER8) {
+	cpu.REG[r] &^= (1 << bit)
 }
 
-func _swap(gbc *GBC, r REGISTER16) {
-	old := gbc.Read(gbc.Reg16(r))
+func _swap(cpu *CPU, r REGISTER16) {
+	old := cpu.Read(cpu.Reg16(r))
 	new := (old << 4) | (old >> 4)
-	gbc.Write(gbc.Reg16(HL), new)
-	gbc.setFlags(new == 0, false, false, false)
+	cpu.Write(cpu.Reg16(HL), new)
+	cpu.setFlags(new == 0, false, false, false)
 }
 
-func _rl(gbc *GBC, mm byte) (uint8, byte) {
+func _rl(cpu *CPU, mm byte) (uint8, byte) {
 	var c uint8
-	if gbc.getFlag(CARRY) {
+	if cpu.getFlag(CARRY) {
 		c = 1
 	} else {
 		c = 0
 	}
-	bit7 := (mm & 1)
+	bit7 := (mm >> 7) & 1
 	return c, bit7
 }
 
-func _rr(gbc *GBC, mm byte) (uint8, byte) {
+func _rr(cpu *CPU, mm byte) (uint8, byte) {
 	var c uint8
-	if gbc.getFlag(CARRY) {
+	if cpu.getFlag(CARRY) {
 		c = 1
 	} else {
 		c = 0
 	}
-	bit0 := (mm >> 7) & 1
+	bit0 := mm & 1
 	return c, bit0
 }
 
-func _bit(gbc *GBC, bit int, mm byte) {
-	gbc.setZNH(mm>>bit == 0, false, true)
+func _bit(cpu *CPU, bit int, mm byte) {
+	cpu.setZNH(mm>>bit&1 == 0, false, true)
 }
 
-func _set(gbc *GBC, bit int, r REGISTER16) {
-	old := gbc.Read(gbc.Reg16(HL))
-	gbc.Write(gbc.Reg16(HL), old|(1<<bit))
+func _set(cpu *CPU, bit int, r REGISTER16) {
+	old := cpu.Read(cpu.Reg16(HL))
+	cpu.Write(cpu.Reg16(HL), old|(1<<bit))
 }
 
-func _res(gbc *GBC, bit int, r REGISTER16) {
-	old := gbc.Read(gbc.Reg16(HL))
-	gbc.Write(gbc.Reg16(HL), old&^(1<<bit))
+func _res(cpu *CPU, bit int, r REGISTER16) {
+	old := cpu.Read(cpu.Reg16(HL))
+	cpu.Write(cpu.Reg16(HL), old&^(1<<bit))
 }
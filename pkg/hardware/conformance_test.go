@@ -0,0 +1,216 @@
+package hardware_test
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"text/tabwriter"
+
+	"github.com/ifamakes/emu/pkg/hardware"
+	"github.com/ifamakes/emu/pkg/headless"
+)
+
+// TestConformance runs every ROM under $GB_TEST_ROMS and judges it by the
+// convention of the suite it belongs to, picked from its path:
+//
+//	blargg     serial output says "Passed" or "Failed"
+//	mooneye    LD B,B with 3/5/8/13/21/34 in B/C/D/E/H/L
+//	samesuite  as mooneye
+//	acid2      LD B,B, then the screen must match the .png next to the ROM
+//
+// Each ROM gets $GB_TEST_FRAMES frames, 7200 by default. A results table is
+// logged at the end and written to $GB_TEST_RESULTS if that is set.
+func TestConformance(t *testing.T) {
+	dir := os.Getenv("GB_TEST_ROMS")
+	if dir == "" {
+		t.Skip("GB_TEST_ROMS not set")
+	}
+	frames := uint64(7200)
+	if s := os.Getenv("GB_TEST_FRAMES"); s != "" {
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			t.Fatalf("GB_TEST_FRAMES: %v", err)
+		}
+		frames = n
+	}
+
+	var roms []string
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			switch strings.ToLower(filepath.Ext(path)) {
+			case ".gb", ".gbc":
+				roms = append(roms, path)
+			}
+		}
+		return nil
+	})
+	sort.Strings(roms)
+
+	var mu sync.Mutex
+	var results []conformanceResult
+	t.Run("rom", func(t *testing.T) {
+		for _, path := range roms {
+			path := path
+			name, _ := filepath.Rel(dir, path)
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+				r := runConformance(path, frames)
+				r.name = name
+				mu.Lock()
+				results = append(results, r)
+				mu.Unlock()
+				if r.suite == "" {
+					t.Skip(r.detail)
+				}
+				if !r.passed {
+					t.Error(r.detail)
+				}
+			})
+		}
+	})
+
+	sort.Slice(results, func(i, j int) bool { return results[i].name < results[j].name })
+	var table strings.Builder
+	tw := tabwriter.NewWriter(&table, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ROM\tSUITE\tRESULT\tFRAMES\tDETAIL")
+	passed := 0
+	for _, r := range results {
+		status := "FAIL"
+		switch {
+		case r.suite == "":
+			status = "SKIP"
+		case r.passed:
+			status = "PASS"
+			passed++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", r.name, r.suite, status, r.frames, r.detail)
+	}
+	tw.Flush()
+	fmt.Fprintf(&table, "%d/%d passed\n", passed, len(results))
+	t.Log("\n" + table.String())
+	if out := os.Getenv("GB_TEST_RESULTS"); out != "" {
+		if err := ioutil.WriteFile(out, []byte(table.String()), 0644); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+type conformanceResult struct {
+	name   string
+	suite  string
+	passed bool
+	frames uint64
+	detail string
+}
+
+func suiteOf(path string) string {
+	p := strings.ToLower(filepath.ToSlash(path))
+	for _, suite := range []string{"blargg", "mooneye", "samesuite", "acid2"} {
+		if strings.Contains(p, suite) {
+			return suite
+		}
+	}
+	if strings.Contains(p, "same-suite") || strings.Contains(p, "same_suite") {
+		return "samesuite"
+	}
+	return ""
+}
+
+func runConformance(path string, frames uint64) conformanceResult {
+	r := conformanceResult{suite: suiteOf(path)}
+	if r.suite == "" {
+		r.detail = "unknown suite"
+		return r
+	}
+	rom, err := ioutil.ReadFile(path)
+	if err != nil {
+		r.detail = err.Error()
+		return r
+	}
+	gbc, err := hardware.New(rom)
+	if err != nil {
+		r.detail = err.Error()
+		return r
+	}
+
+	c := headless.Config{Frames: frames}
+	if r.suite == "blargg" {
+		c.SerialPass, c.SerialFail = []byte("Passed"), []byte("Failed")
+	} else {
+		c.LDBB = true
+	}
+	res, err := headless.Run(gbc, c)
+	r.frames = res.Frames
+	if err != nil {
+		r.detail = err.Error()
+		return r
+	}
+
+	switch res.Reason {
+	case headless.REASON_SERIAL_PASS:
+		r.passed = true
+	case headless.REASON_SERIAL_FAIL:
+		r.detail = lastLine(res.Serial)
+	case headless.REASON_LDBB:
+		if r.suite == "acid2" {
+			r.passed, r.detail = matchesReference(gbc, strings.TrimSuffix(path, filepath.Ext(path))+".png")
+		} else if r.passed = headless.MooneyePassed(gbc); !r.passed {
+			r.detail = fmt.Sprintf("registers % X", gbc.REG[:6])
+		}
+	default:
+		r.detail = "timed out"
+		if len(res.Serial) > 0 {
+			r.detail += ": " + lastLine(res.Serial)
+		}
+	}
+	return r
+}
+
+func lastLine(b []byte) string {
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// matchesReference compares the screen against a reference PNG by hashing
+// both after reducing every pixel to one of the four DMG shades.
+func matchesReference(gbc *hardware.GBC, name string) (bool, string) {
+	f, err := os.Open(name)
+	if err != nil {
+		return false, err.Error()
+	}
+	defer f.Close()
+	ref, err := png.Decode(f)
+	if err != nil {
+		return false, err.Error()
+	}
+	if ref.Bounds().Dx() != hardware.SCREEN_WIDTH || ref.Bounds().Dy() != hardware.SCREEN_HEIGHT {
+		return false, "reference is not 160x144"
+	}
+	want, got := shadeHash(ref), shadeHash(gbc.Screen())
+	if want != got {
+		return false, fmt.Sprintf("screen %x, want %x", got[:4], want[:4])
+	}
+	return true, ""
+}
+
+func shadeHash(img image.Image) [sha256.Size]byte {
+	b := img.Bounds()
+	shades := make([]byte, 0, b.Dx()*b.Dy())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			grey := color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y
+			shades = append(shades, byte(3-(int(grey)+0x2A)/0x55))
+		}
+	}
+	return sha256.Sum256(shades)
+}
@@ -0,0 +1,91 @@
+package hardware
+
+// Bus is the address space an SM83 core runs against. The GBC is one, with
+// the cartridge, video memory and IO registers behind it, but a flat 64K of
+// RAM works as well for testing the core on its own.
+type Bus interface {
+	Read(addr uint16) byte
+	Write(addr uint16, value byte)
+}
+
+// CPU is the SM83 core: the registers and the state of the instruction
+// being executed. All memory access goes through Bus.
+type CPU struct {
+	Register
+	Bus Bus
+
+	cycles        uint64
+	instructions  uint64
+	currOP        byte
+	currPC        uint16
+	setPendingIME bool
+	halted        bool
+	stoped        bool
+	// imm is the bytes after the opcode when the block cache decoded them,
+	// which predecoded says. Otherwise they are read from the bus.
+	imm        [2]byte
+	predecoded bool
+}
+
+// NewCPU returns a core with zeroed registers running against bus.
+func NewCPU(bus Bus) *CPU {
+	return &CPU{Bus: bus}
+}
+
+func (cpu *CPU) Read(addr uint16) byte {
+	return cpu.Bus.Read(addr)
+}
+
+func (cpu *CPU) Write(addr uint16, value byte) {
+	cpu.Bus.Write(addr, value)
+}
+
+// operand returns the byte after the opcode.
+func (cpu *CPU) operand() byte {
+	if cpu.predecoded {
+		return cpu.imm[0]
+	}
+	return cpu.Read(cpu.currPC + 1)
+}
+
+// operands returns the two bytes after the opcode, low then high, ready to
+// be put together as a little-endian word.
+func (cpu *CPU) operands() (l, u uint16) {
+	if cpu.predecoded {
+		return uint16(cpu.imm[0]), uint16(cpu.imm[1])
+	}
+	return uint16(cpu.Read(cpu.currPC + 1)), uint16(cpu.Read(cpu.currPC + 2))
+}
+
+// Cycles returns the number of T-cycles executed since power on.
+func (cpu *CPU) Cycles() uint64 { return cpu.cycles }
+
+// Instructions returns the number of instructions executed since power on.
+func (cpu *CPU) Instructions() uint64 { return cpu.instructions }
+
+// LastOpcode returns the opcode of the most recently executed instruction,
+// 0xCB for prefixed ones.
+func (cpu *CPU) LastOpcode() byte { return cpu.currOP }
+
+// Step executes one instruction, or idles for a machine cycle while halted
+// or stopped, and returns the number of T-cycles it took.
+func (cpu *CPU) Step() uint64 {
+	if cpu.halted || cpu.stoped {
+		cpu.cycles += 4
+		return 4
+	}
+	cpu.currPC = cpu.PC
+	cpu.predecoded = false
+	cpu.currOP = cpu.Read(cpu.currPC)
+	cpu.PC++
+	n := instructions[cpu.currOP].f(cpu)
+	cpu.cycles += n
+	cpu.instructions++
+	return n
+}
+
+// FlatRAM is a Bus with 64K of plain memory and nothing mapped on it.
+type FlatRAM [0x10000]byte
+
+func (m *FlatRAM) Read(addr uint16) byte         { return m[addr] }
+func (m *FlatRAM) Write(addr uint16, value byte) { m[addr] = value }
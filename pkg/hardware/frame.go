@@ -0,0 +1,118 @@
+package hardware
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DMGShades is the default DMG palette: four greys from lightest to darkest.
+var DMGShades = color.Palette{
+	color.Gray{0xFF}, color.Gray{0xAA}, color.Gray{0x55}, color.Gray{0x00},
+}
+
+// PocketGreen is the yellowish green of the original DMG screen.
+var PocketGreen = color.Palette{
+	color.RGBA{0xE0, 0xF8, 0xD0, 0xFF}, color.RGBA{0x88, 0xC0, 0x70, 0xFF},
+	color.RGBA{0x34, 0x68, 0x56, 0xFF}, color.RGBA{0x08, 0x18, 0x20, 0xFF},
+}
+
+// Palettes are the DMG palettes known by name to ParsePalette.
+var Palettes = map[string]color.Palette{
+	"grey":  DMGShades,
+	"green": PocketGreen,
+}
+
+var ErrPalette = errors.New("a DMG palette needs 4 colours")
+
+// ParsePalette returns the palette called name in Palettes, or one given as
+// four hex colours from lightest to darkest, like "e0f8d0,88c070,346856,081820".
+func ParsePalette(name string) (color.Palette, error) {
+	if p, ok := Palettes[name]; ok {
+		return p, nil
+	}
+	fields := strings.Split(name, ",")
+	if len(fields) != 4 {
+		return nil, ErrPalette
+	}
+	p := make(color.Palette, 4)
+	for i, f := range fields {
+		v, err := strconv.ParseUint(strings.TrimPrefix(strings.TrimSpace(f), "#"), 16, 32)
+		if err != nil || v > 0xFFFFFF {
+			return nil, fmt.Errorf("bad colour %q", f)
+		}
+		p[i] = color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xFF}
+	}
+	return p, nil
+}
+
+// SetPalette sets the four shades a DMG screen is drawn in, lightest first.
+// It has no effect on a CGB, whose colours come from its palette RAM.
+func (gbc *GBC) SetPalette(p color.Palette) error {
+	if len(p) != 4 {
+		return ErrPalette
+	}
+	gbc.palette = p
+	return nil
+}
+
+// Palette returns the DMG palette set with SetPalette, DMGShades by default.
+func (gbc *GBC) Palette() color.Palette {
+	if gbc.palette == nil {
+		return DMGShades
+	}
+	return gbc.palette
+}
+
+// WithPalette draws the DMG screen in p, see SetPalette.
+func WithPalette(p color.Palette) Option {
+	return func(gbc *GBC) error {
+		return gbc.SetPalette(p)
+	}
+}
+
+// Frame returns the screen as it is now, see Screen.
+func (gbc *GBC) Frame() image.Image {
+	return gbc.Screen()
+}
+
+// FrameRGBA appends the screen to dst as 8 bit RGBA, row by row.
+func (gbc *GBC) FrameRGBA(dst []byte) []byte {
+	img := gbc.Screen()
+	for _, i := range img.Pix {
+		r, g, b, a := img.Palette[i].RGBA()
+		dst = append(dst, byte(r>>8), byte(g>>8), byte(b>>8), byte(a>>8))
+	}
+	return dst
+}
+
+// WritePNG writes the screen to w as a PNG.
+func (gbc *GBC) WritePNG(w io.Writer) error {
+	return png.Encode(w, gbc.Screen())
+}
+
+// WriteGIF runs the given number of frames and writes them to w as an
+// animated GIF playing at the speed of the hardware.
+func (gbc *GBC) WriteGIF(w io.Writer, frames int) error {
+	anim := &gif.GIF{}
+	// GIF delays are in hundredths of a second, so they alternate between
+	// 1 and 2 to average out at the hardware's 59.73 frames per second.
+	elapsed, shown := 0.0, 0
+	for i := 0; i < frames; i++ {
+		if err := gbc.RunFrame(); err != nil {
+			return err
+		}
+		elapsed += 100 * CYCLES_PER_FRAME / 4194304.0
+		delay := int(elapsed+0.5) - shown
+		shown += delay
+		anim.Image = append(anim.Image, gbc.Screen())
+		anim.Delay = append(anim.Delay, delay)
+	}
+	return gif.EncodeAll(w, anim)
+}
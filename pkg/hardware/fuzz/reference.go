@@ -0,0 +1,199 @@
+//go:build gofuzz
+// +build gofuzz
+
+package fuzz
+
+import "github.com/ifamakes/emu/pkg/hardware"
+
+// machine is the state the reference model predicts: the registers and the
+// byte at the address HL held before the instruction.
+type machine struct {
+	regs [8]byte
+	sp   uint16
+	pc   uint16
+	hl   byte
+}
+
+const (
+	flagZ = 0x80
+	flagN = 0x40
+	flagH = 0x20
+	flagC = 0x10
+)
+
+func flags(z, n, h, c bool) byte {
+	var f byte
+	for i, set := range []bool{z, n, h, c} {
+		if set {
+			f |= 0x80 >> uint(i)
+		}
+	}
+	return f
+}
+
+// reference runs the instruction at origin of ram from regs, written
+// straight from the opcode tables rather than from the emulator, and
+// reports false for instructions it doesn't model.
+func reference(regs [8]byte, sp uint16, ram *hardware.FlatRAM) (machine, bool) {
+	hl := uint16(regs[hardware.REG_H])<<8 | uint16(regs[hardware.REG_L])
+	m := machine{regs: regs, sp: sp, pc: origin + 1, hl: ram[hl]}
+	op := ram[origin]
+	imm := ram[origin+1]
+	f := regs[hardware.REG_F]
+	carry := f&flagC != 0
+
+	// Operand index 0-7 is B, C, D, E, H, L, (HL), A, which is also the
+	// register array order except for (HL).
+	get := func(i byte) byte {
+		if i == 6 {
+			return m.hl
+		}
+		return m.regs[i]
+	}
+	set := func(i, v byte) {
+		if i == 6 {
+			m.hl = v
+			return
+		}
+		m.regs[i] = v
+	}
+	a := &m.regs[hardware.REG_A]
+	setF := func(v byte) { m.regs[hardware.REG_F] = v }
+
+	switch {
+	case op >= 0x80 && op < 0xC0:
+		alu(op>>3&7, a, get(op&7), carry, setF)
+	case op&0xC7 == 0xC6:
+		alu(op>>3&7, a, imm, carry, setF)
+		m.pc++
+	case op < 0x40 && op&0x07 == 0x04:
+		i := op >> 3
+		v := get(i) + 1
+		set(i, v)
+		setF(flags(v == 0, false, v&0xF == 0, carry))
+	case op < 0x40 && op&0x07 == 0x05:
+		i := op >> 3
+		v := get(i) - 1
+		set(i, v)
+		setF(flags(v == 0, true, v&0xF == 0xF, carry))
+	case op < 0x40 && op&0x0F == 0x09:
+		pairs := map[byte]uint16{
+			0x09: uint16(regs[hardware.REG_B])<<8 | uint16(regs[hardware.REG_C]),
+			0x19: uint16(regs[hardware.REG_D])<<8 | uint16(regs[hardware.REG_E]),
+			0x29: hl,
+			0x39: sp,
+		}
+		rr := pairs[op]
+		sum := uint32(hl) + uint32(rr)
+		m.regs[hardware.REG_H], m.regs[hardware.REG_L] = byte(sum>>8), byte(sum)
+		setF(f&flagZ | flags(false, false, hl&0xFFF+rr&0xFFF > 0xFFF, sum > 0xFFFF))
+	case op == 0x07, op == 0x0F, op == 0x17, op == 0x1F:
+		v, c := rotate(op>>3, *a, carry)
+		*a = v
+		setF(flags(false, false, false, c))
+	case op == 0x27:
+		v := *a
+		n, h := f&flagN != 0, f&flagH != 0
+		if !n {
+			if carry || v > 0x99 {
+				v += 0x60
+				carry = true
+			}
+			if h || v&0x0F > 9 {
+				v += 0x06
+			}
+		} else {
+			if carry {
+				v -= 0x60
+			}
+			if h {
+				v -= 0x06
+			}
+		}
+		*a = v
+		setF(flags(v == 0, n, false, carry))
+	case op == 0x2F:
+		*a = ^*a
+		setF(f | flagN | flagH)
+	case op == 0x37:
+		setF(f&flagZ | flagC)
+	case op == 0x3F:
+		setF(f&flagZ | flags(false, false, false, !carry))
+	case op == 0xCB:
+		m.pc++
+		i, bit := imm&7, imm>>3&7
+		v := get(i)
+		switch imm >> 6 {
+		case 0:
+			r, c := rotate(bit, v, carry)
+			set(i, r)
+			setF(flags(r == 0, false, false, c))
+		case 1:
+			setF(f&flagC | flags(v>>bit&1 == 0, false, true, false))
+		case 2:
+			set(i, v&^(1<<bit))
+		case 3:
+			set(i, v|1<<bit)
+		}
+	default:
+		return m, false
+	}
+	return m, true
+}
+
+// alu performs ADD, ADC, SUB, SBC, AND, XOR, OR or CP, numbered as in bits
+// 3-5 of their opcodes.
+func alu(kind byte, a *byte, v byte, carry bool, setF func(byte)) {
+	var c int
+	if carry && (kind == 1 || kind == 3) {
+		c = 1
+	}
+	x, y := int(*a), int(v)
+	switch kind {
+	case 0, 1:
+		r := x + y + c
+		setF(flags(byte(r) == 0, false, x&0xF+y&0xF+c > 0xF, r > 0xFF))
+		*a = byte(r)
+	case 2, 3, 7:
+		r := x - y - c
+		setF(flags(byte(r) == 0, true, x&0xF-y&0xF-c < 0, r < 0))
+		if kind != 7 {
+			*a = byte(r)
+		}
+	case 4:
+		*a &= v
+		setF(flags(*a == 0, false, true, false))
+	case 5:
+		*a ^= v
+		setF(flags(*a == 0, false, false, false))
+	case 6:
+		*a |= v
+		setF(flags(*a == 0, false, false, false))
+	}
+}
+
+// rotate performs RLC, RRC, RL, RR, SLA, SRA, SWAP or SRL, numbered as in
+// bits 3-5 of their CB opcodes, returning the result and the new carry.
+func rotate(kind, v byte, carry bool) (byte, bool) {
+	var in byte
+	if carry {
+		in = 1
+	}
+	switch kind {
+	case 0:
+		return v<<1 | v>>7, v&0x80 != 0
+	case 1:
+		return v>>1 | v<<7, v&1 != 0
+	case 2:
+		return v<<1 | in, v&0x80 != 0
+	case 3:
+		return v>>1 | in<<7, v&1 != 0
+	case 4:
+		return v << 1, v&0x80 != 0
+	case 5:
+		return v>>1 | v&0x80, v&1 != 0
+	case 6:
+		return v<<4 | v>>4, false
+	}
+	return v >> 1, v&1 != 0
+}
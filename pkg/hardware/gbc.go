@@ -3,6 +3,7 @@ package hardware
 import (
 	"bufio"
 	"fmt"
+	"image/color"
 	"io"
 	"log"
 )
@@ -16,92 +17,255 @@ func (p *Processor) Cycle() uint64           { return p.cycles }
 func (p *Processor) AddCycles(amount uint64) { p.cycles += amount }
 
 type GBC struct {
-	cycles uint64
-	currOP byte
-	currPC uint16
-	Register
+	CPU
 	MMU
-	debug_compare bufio.Scanner
-	setPendingIME bool
-	halted        bool
-	stoped        bool
+	cart          Cartridge
+	romBank       int
+	ramBank       int
+	ramEnabled    bool
+	mbcMode       byte
+	model         MODEL
+	bootROM       []byte
+	sram          []byte
+	audio         AudioSink
+	serial        SerialPeer
+	log           *log.Logger
+	tracer        Tracer
+	debug_compare *bufio.Scanner
+	debug_line    int
+	components    []StateComponent
+	buttons       BUTTON
+	blocks        *blockCache
+	palette       color.Palette
+	video         videoMemory
 }
 
-func NewGBC(file []byte, compare_file io.Reader) *GBC {
-	return &GBC{
-		Register: Register{
+// New creates a GBC running rom. Without options the model comes from the
+// cartridge header and the registers are set to their post-boot values.
+func New(rom []byte, opts ...Option) (*GBC, error) {
+	cart, err := parseCartridge(rom)
+	if err != nil {
+		return nil, err
+	}
+	gbc := &GBC{
+		MMU:     NewMMU(rom),
+		cart:    cart,
+		romBank: 1,
+		model:   cart.mode,
+		log:     log.New(io.Discard, "", 0),
+	}
+	gbc.Bus = gbc
+	for _, opt := range opts {
+		if err := opt(gbc); err != nil {
+			return nil, err
+		}
+	}
+	gbc.reset()
+	gbc.video.seed(gbc)
+	if gbc.sram != nil {
+		if err := gbc.loadSaveRAM(gbc.sram); err != nil {
+			return nil, err
+		}
+		gbc.sram = nil
+	}
+	return gbc, nil
+}
+
+func (gbc *GBC) reset() {
+	switch {
+	case gbc.bootROM != nil:
+		gbc.Register = Register{}
+	case gbc.model == CGB:
+		gbc.Register = Register{
+			REG: [8]byte{0x00, 0x00, 0xFF, 0x56, 0x00, 0x0D, 0x80, 0x11},
+			SP:  0xFFFE,
+			PC:  0x0100,
+		}
+	default:
+		gbc.Register = Register{
 			REG: [8]byte{0x00, 0x13, 0x00, 0xD8, 0x01, 0x4D, 0xB0, 0x01},
 			SP:  0xFFFE,
 			PC:  0x0100,
-			IME: false,
-		},
-		MMU:           NewMMU(file),
-		debug_compare: *bufio.NewScanner(compare_file),
+		}
 	}
 }
 
-func (gbc *GBC) DebugStep() {
-	currentMEM := fmt.Sprintf("A: %02X F: %02X B: %02X C: %02X D: %02X E: %02X H: %02X L: %02X SP: %04X PC: 00:%04X (%02X %02X %02X %02X)", gbc.REG[A], gbc.REG[F], gbc.REG[B], gbc.REG[C], gbc.REG[D], gbc.REG[E], gbc.REG[H], gbc.REG[L], gbc.SP, gbc.PC, gbc.Read(gbc.PC), gbc.Read(gbc.PC+1), gbc.Read(gbc.PC+2), gbc.Read(gbc.PC+3))
-	log.Println(currentMEM)
-	test := gbc.debug_compare.Scan()
-	if !test && gbc.debug_compare.Err() == nil {
-		return
-	} else {
-		fmt.Println(gbc.debug_compare.Text())
-		logAtStep := gbc.debug_compare.Text()
-		if logAtStep != currentMEM && test {
-			panic("Current Memory Does not Match!")
+// CYCLES_PER_FRAME is the length of one LCD frame in T-cycles.
+const CYCLES_PER_FRAME = 70224
+
+// FrameCount returns the number of whole frames executed since power on.
+func (gbc *GBC) FrameCount() uint64 { return gbc.cycles / CYCLES_PER_FRAME }
+
+// RunFrame steps until the start of the next frame.
+func (gbc *GBC) RunFrame() error {
+	end := (gbc.FrameCount() + 1) * CYCLES_PER_FRAME
+	for gbc.cycles < end {
+		if gbc.blocks != nil && gbc.tracer == nil && gbc.debug_compare == nil && gbc.bootROM == nil {
+			gbc.runBlock(end)
+			continue
+		}
+		if err := gbc.Step(); err != nil {
+			return err
 		}
 	}
+	return nil
 }
 
-func (gbc *GBC) Step() {
-	// timer stuff
+// Model returns the hardware model being emulated.
+func (gbc *GBC) Model() MODEL { return gbc.model }
 
-	gbc.DebugStep()
+// BootROM returns the boot ROM while it is still mapped, or nil.
+func (gbc *GBC) BootROM() []byte { return gbc.bootROM }
 
-	if gbc.halted {
-	} else {
-		if gbc.stoped {
-			gbc.cycles += 4
-		} else {
-			//gbc.HandleInterrupts()
-			gbc.currPC = gbc.PC
-			gbc.currOP = gbc.Read(gbc.currPC)
-			gbc.PC++
-
-			inst := instructions[gbc.currOP]
-			log.Println(inst.label)
-			inst.f(gbc)
+// Cartridge returns the parsed header of the loaded ROM.
+func (gbc *GBC) Cartridge() *Cartridge { return &gbc.cart }
+
+// Peek reads from the bus as the CPU would see it, with the boot ROM mapped
+// over the cartridge until the game unmaps it, without notifying the tracer.
+func (gbc *GBC) Peek(addr uint16) byte {
+	if gbc.bootROM != nil && (addr < 0x100 || (addr >= 0x200 && int(addr) < len(gbc.bootROM))) {
+		return gbc.bootROM[addr]
+	}
+	if addr == P1 {
+		return gbc.joypad()
+	}
+	return gbc.MMU.Read(addr)
+}
+
+// Poke writes to the bus without notifying the tracer. Writes to the MBC
+// registers still move the tracked banks, so debuggers can switch them.
+func (gbc *GBC) Poke(addr uint16, value byte) {
+	if gbc.blocks != nil {
+		gbc.blocks.invalidate(addr)
+	}
+	if addr < 0x8000 {
+		gbc.trackBank(addr, value)
+	}
+	if addr >= 0x8000 && addr < 0xA000 || addr >= 0xFF55 && addr <= OCPS+1 {
+		gbc.pokeVideo(addr, value)
+		return
+	}
+	gbc.MMU.Write(addr, value)
+}
+
+func (gbc *GBC) Read(addr uint16) byte {
+	value := gbc.Peek(addr)
+	if gbc.tracer != nil {
+		gbc.tracer.MemoryAccess(addr, value, false)
+	}
+	return value
+}
+
+func (gbc *GBC) Write(addr uint16, value byte) {
+	if gbc.tracer != nil {
+		gbc.tracer.MemoryAccess(addr, value, true)
+	}
+	if addr == 0xFF50 && value != 0 {
+		gbc.bootROM = nil
+	}
+	gbc.Poke(addr, value)
+}
+
+// TraceMismatchError reports the first line where the CPU state diverged
+// from the log passed to WithTraceCompare.
+type TraceMismatchError struct {
+	Line int
+	Want string
+	Got  string
+}
+
+func (e *TraceMismatchError) Error() string {
+	return fmt.Sprintf("trace mismatch at line %d:\n want: %s\n  got: %s", e.Line, e.Want, e.Got)
+}
+
+func (gbc *GBC) DebugStep() error {
+	if !gbc.debug_compare.Scan() {
+		return gbc.debug_compare.Err()
+	}
+	gbc.debug_line++
+	currentMEM := string(AppendDoctorLine(nil, gbc))
+	if logAtStep := gbc.debug_compare.Text(); logAtStep != currentMEM {
+		return &TraceMismatchError{Line: gbc.debug_line, Want: logAtStep, Got: currentMEM}
+	}
+	return nil
+}
+
+func (gbc *GBC) Step() error {
+	// timer stuff
+
+	gbc.HandleInterrupts()
+	if gbc.debug_compare != nil {
+		if err := gbc.DebugStep(); err != nil {
+			return err
 		}
 	}
-	outputCheck := gbc.Read(0xFF02)
-	output := gbc.Read(0xFF01)
-	if outputCheck == 0x81 {
-		fmt.Printf("blargg output: %q\n", output)
-		gbc.Write(0xFF02, 0x00)
+
+	if gbc.tracer != nil && !gbc.halted && !gbc.stoped {
+		gbc.tracer.Instruction(gbc, gbc.PC, gbc.Peek(gbc.PC))
+	}
+	if d := gbc.cachedInstruction(); d != nil {
+		gbc.execute(d)
+	} else {
+		gbc.CPU.Step()
 	}
+	gbc.serialTransfer()
+	return nil
 }
 
+// HandleInterrupts runs between instructions. It applies a pending EI and,
+// with IME set, dispatches the highest priority interrupt that is both
+// enabled and requested: the request is acknowledged, IME cleared, PC
+// pushed and the CPU sent to the vector, taking 20 T-cycles. A requested
+// interrupt wakes a halted CPU even with IME clear.
 func (gbc *GBC) HandleInterrupts() {
+	// EI takes effect after the instruction following it, so this
+	// boundary still goes by the old IME.
 	IME := gbc.IME
 	if gbc.setPendingIME {
-		gbc.Register.IME = !gbc.Register.IME
+		gbc.IME = true
 		gbc.setPendingIME = false
 	}
 
-	IE := gbc.Read(IE)
-	IF := gbc.Read(0xFF0F)
+	pending := gbc.Peek(IE) & gbc.Peek(0xFF0F) & 0x1F
+	if pending == 0 {
+		return
+	}
+	gbc.halted = false
+	if !IME {
+		return
+	}
+	vector := interruptVector(pending)
+	if gbc.tracer != nil {
+		gbc.tracer.Interrupt(vector)
+	}
+	gbc.IME = false
+	gbc.Poke(0xFF0F, gbc.Peek(0xFF0F)&^(1<<((vector-0x40)/8)))
+	gbc.Write(gbc.SP-1, byte(gbc.PC>>8))
+	gbc.Write(gbc.SP-2, byte(gbc.PC&0x00FF))
+	gbc.SP -= 2
+	gbc.PC = vector
+	gbc.cycles += 20
+}
 
-	if IME && IE&IF&0x1F != 0 {
-		// do the interrupt stuff
-		gbc.halted = false
-		gbc.Write(gbc.SP-1, byte(gbc.PC>>8))
-		gbc.Write(gbc.SP-2, byte(gbc.PC&0x00FF))
-		gbc.SP -= 2
-		gbc.cycles += 12
+// InterruptDue reports whether the next Step starts by dispatching an
+// interrupt, and the vector it dispatches to.
+func (gbc *GBC) InterruptDue() (uint16, bool) {
+	pending := gbc.Peek(IE) & gbc.Peek(0xFF0F) & 0x1F
+	if !gbc.IME || pending == 0 {
+		return 0, false
+	}
+	return interruptVector(pending), true
+}
 
+// interruptVector returns the handler address of the highest priority
+// interrupt in pending.
+func interruptVector(pending byte) uint16 {
+	for i := uint16(0); i < 5; i++ {
+		if pending&(1<<i) != 0 {
+			return 0x40 + i*8
+		}
 	}
+	return 0
 }
 
 /*
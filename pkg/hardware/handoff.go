@@ -0,0 +1,208 @@
+package hardware
+
+import (
+	"context"
+	"image"
+	"sync/atomic"
+	"time"
+)
+
+// A GBC is not safe for concurrent use. A frontend that presents on another
+// goroutine runs it with Run, which owns it until it returns, and talks to
+// it only through the types here: frames come out of a TripleBuffer, audio
+// out of an AudioRing, and buttons go in through an InputLatch. None of them
+// take locks, so neither side can stall the other.
+
+// Host is what Run shares with the frontend. Any field may be nil.
+type Host struct {
+	Frames *TripleBuffer
+	Input  *InputLatch
+	// Pacer is waited on before each frame. Without one Run goes as fast as
+	// it can.
+	Pacer Pacer
+}
+
+// Run emulates frame after frame until ctx is done or the GBC fails. After
+// each frame it publishes the screen to h.Frames, and before each it takes
+// the buttons from h.Input.
+func (gbc *GBC) Run(ctx context.Context, h Host) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if h.Pacer != nil {
+			if err := h.Pacer.Wait(ctx); err != nil {
+				return err
+			}
+		}
+		if h.Input != nil {
+			gbc.SetButtons(h.Input.Get())
+		}
+		if err := gbc.RunFrame(); err != nil {
+			return err
+		}
+		if h.Frames != nil {
+			gbc.DrawScreen(h.Frames.Back())
+			h.Frames.Publish()
+		}
+	}
+}
+
+// TripleBuffer hands whole frames from one producer to one consumer. Each
+// side owns a buffer and they swap through a third, so the consumer always
+// gets the most recent complete frame and never one being drawn.
+type TripleBuffer struct {
+	// state is the index of the middle buffer, with tripleFresh set while
+	// it holds a frame the consumer hasn't taken.
+	state       uint32
+	bufs        [3]*image.Paletted
+	back, front uint32
+}
+
+const tripleFresh = 4
+
+func NewTripleBuffer() *TripleBuffer {
+	t := &TripleBuffer{state: 1, back: 0, front: 2}
+	for i := range t.bufs {
+		t.bufs[i] = image.NewPaletted(image.Rect(0, 0, SCREEN_WIDTH, SCREEN_HEIGHT), DMGShades)
+	}
+	return t
+}
+
+// Back returns the buffer the producer draws into.
+func (t *TripleBuffer) Back() *image.Paletted { return t.bufs[t.back] }
+
+// Publish makes the back buffer the latest frame and gives the producer a
+// new one to draw into.
+func (t *TripleBuffer) Publish() {
+	t.back = atomic.SwapUint32(&t.state, t.back|tripleFresh) &^ tripleFresh
+}
+
+// Front returns the latest frame and whether it is new since the last call.
+// It stays valid until the next call.
+func (t *TripleBuffer) Front() (*image.Paletted, bool) {
+	if atomic.LoadUint32(&t.state)&tripleFresh == 0 {
+		return t.bufs[t.front], false
+	}
+	t.front = atomic.SwapUint32(&t.state, t.front) &^ tripleFresh
+	return t.bufs[t.front], true
+}
+
+// AudioRing is an AudioSink that queues samples for one consumer on another
+// goroutine. When it is full new samples are dropped rather than waited on.
+type AudioRing struct {
+	head    uint64 // samples pushed, written by the producer
+	tail    uint64 // samples read, written by the consumer
+	dropped uint64
+	buf     []int16 // interleaved left and right
+	mask    uint64
+}
+
+// NewAudioRing returns a ring holding at least size stereo samples.
+func NewAudioRing(size int) *AudioRing {
+	n := 1
+	for n < size {
+		n <<= 1
+	}
+	return &AudioRing{buf: make([]int16, 2*n), mask: uint64(n - 1)}
+}
+
+func (r *AudioRing) PushSample(left, right int16) {
+	head := atomic.LoadUint64(&r.head)
+	if head-atomic.LoadUint64(&r.tail) > r.mask {
+		atomic.AddUint64(&r.dropped, 1)
+		return
+	}
+	i := head & r.mask * 2
+	r.buf[i], r.buf[i+1] = left, right
+	atomic.StoreUint64(&r.head, head+1)
+}
+
+// Read fills out with interleaved samples and returns how many stereo
+// samples it read.
+func (r *AudioRing) Read(out []int16) int {
+	tail := atomic.LoadUint64(&r.tail)
+	n := int(atomic.LoadUint64(&r.head) - tail)
+	if n > len(out)/2 {
+		n = len(out) / 2
+	}
+	for k := 0; k < n; k++ {
+		i := (tail + uint64(k)) & r.mask * 2
+		out[2*k], out[2*k+1] = r.buf[i], r.buf[i+1]
+	}
+	atomic.StoreUint64(&r.tail, tail+uint64(n))
+	return n
+}
+
+// Len returns the number of stereo samples waiting to be read.
+func (r *AudioRing) Len() int {
+	return int(atomic.LoadUint64(&r.head) - atomic.LoadUint64(&r.tail))
+}
+
+// Cap returns the number of stereo samples the ring holds.
+func (r *AudioRing) Cap() int { return int(r.mask + 1) }
+
+// Dropped returns the number of samples dropped because the ring was full.
+func (r *AudioRing) Dropped() uint64 { return atomic.LoadUint64(&r.dropped) }
+
+// InputLatch carries the buttons held from the frontend to Run.
+type InputLatch struct {
+	buttons uint32
+}
+
+func (l *InputLatch) Set(b BUTTON) { atomic.StoreUint32(&l.buttons, uint32(b)) }
+func (l *InputLatch) Get() BUTTON  { return BUTTON(atomic.LoadUint32(&l.buttons)) }
+
+// Pacer holds Run back so emulation keeps time with the host.
+type Pacer interface {
+	// Wait returns when the next frame may start, or with ctx's error.
+	Wait(ctx context.Context) error
+}
+
+// VsyncPacer runs one frame per call to Vsync, for frontends whose display
+// refreshes at close to 59.7 Hz.
+type VsyncPacer struct {
+	tick chan struct{}
+}
+
+func NewVsyncPacer() *VsyncPacer {
+	return &VsyncPacer{tick: make(chan struct{}, 1)}
+}
+
+// Vsync lets the next frame start. Ticks while one is already pending are
+// dropped, so a slow emulator doesn't build up a backlog.
+func (p *VsyncPacer) Vsync() {
+	select {
+	case p.tick <- struct{}{}:
+	default:
+	}
+}
+
+func (p *VsyncPacer) Wait(ctx context.Context) error {
+	select {
+	case <-p.tick:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// AudioPacer runs frames while Ring holds no more than Target samples, so
+// emulation follows the audio device's clock and the latency stays around
+// Target. There is no APU yet, so nothing pushes samples into a ring passed
+// to WithAudioSink and AudioPacer never waits; until there is one, use
+// VsyncPacer or no pacer.
+type AudioPacer struct {
+	Ring   *AudioRing
+	Target int
+}
+
+func (p AudioPacer) Wait(ctx context.Context) error {
+	for p.Ring.Len() > p.Target {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package hardware
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestTripleBufferNeverTears(t *testing.T) {
+	frames := NewTripleBuffer()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for n := 1; n <= 20000; n++ {
+			back := frames.Back()
+			for i := range back.Pix {
+				back.Pix[i] = byte(n)
+			}
+			frames.Publish()
+		}
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		img, fresh := frames.Front()
+		if !fresh {
+			runtime.Gosched()
+			continue
+		}
+		for _, p := range img.Pix {
+			if p != img.Pix[0] {
+				t.Fatalf("torn frame: %d and %d", img.Pix[0], p)
+			}
+		}
+	}
+	if img, _ := frames.Front(); img.Pix[0] != 20000%256 {
+		t.Errorf("last frame ends in %d, want %d", img.Pix[0], 20000%256)
+	}
+}
+
+func TestAudioRing(t *testing.T) {
+	ring := NewAudioRing(3)
+	if ring.Cap() != 4 {
+		t.Fatalf("Cap() = %d, want 4", ring.Cap())
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10000; {
+			if ring.Len() < ring.Cap() {
+				ring.PushSample(int16(i), int16(-i))
+				i++
+			} else {
+				runtime.Gosched()
+			}
+		}
+	}()
+	buf := make([]int16, 6)
+	for want := 0; want < 10000; {
+		n := ring.Read(buf)
+		if n == 0 {
+			runtime.Gosched()
+		}
+		for k := 0; k < n; k++ {
+			if buf[2*k] != int16(want) || buf[2*k+1] != int16(-want) {
+				t.Fatalf("sample %d is %d,%d", want, buf[2*k], buf[2*k+1])
+			}
+			want++
+		}
+	}
+	<-done
+	if ring.Dropped() != 0 {
+		t.Errorf("dropped %d samples", ring.Dropped())
+	}
+}
+
+func TestRunVsyncPacer(t *testing.T) {
+	gbc, err := New(testROM(0x18, 0xFE))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pacer := NewVsyncPacer()
+	frames := NewTripleBuffer()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- gbc.Run(ctx, Host{Frames: frames, Input: &InputLatch{}, Pacer: pacer}) }()
+
+	// fresh reports whether a frame is published within d.
+	fresh := func(d time.Duration) bool {
+		for end := time.Now().Add(d); time.Now().Before(end); {
+			if _, ok := frames.Front(); ok {
+				return true
+			}
+			time.Sleep(time.Millisecond)
+		}
+		return false
+	}
+	for i := 0; i < 4; i++ {
+		pacer.Vsync()
+		if !fresh(time.Second) {
+			t.Fatalf("no frame after vsync %d", i+1)
+		}
+	}
+	if fresh(20 * time.Millisecond) {
+		t.Error("frame published without a vsync")
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Run returned %v, want context.Canceled", err)
+	}
+	if n := gbc.FrameCount(); n != 4 {
+		t.Errorf("ran %d frames for 4 vsyncs", n)
+	}
+}
@@ -1,5 +1,490 @@
 package hardware
 
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
 func Timer_Test() {
 
 }
+
+func testROM(code ...byte) []byte {
+	rom := make([]byte, 0x8000)
+	copy(rom[0x100:], code)
+	return rom
+}
+
+func TestSaveStateRoundTrip(t *testing.T) {
+	// LD A, 0x42; LD (0xC000), A; INC B; JR -3
+	gbc, err := New(testROM(0x3E, 0x42, 0xEA, 0x00, 0xC0, 0x04, 0x18, 0xFD))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		gbc.Step()
+	}
+
+	var state bytes.Buffer
+	if err := gbc.SaveState(&state); err != nil {
+		t.Fatal(err)
+	}
+	want := gbc.Register
+	wantCycles := gbc.Cycles()
+
+	for i := 0; i < 10; i++ {
+		gbc.Step()
+	}
+	gbc.Poke(0xC000, 0)
+	if err := gbc.LoadState(&state); err != nil {
+		t.Fatal(err)
+	}
+	if gbc.Register != want || gbc.Cycles() != wantCycles {
+		t.Errorf("registers %+v cycles %d, want %+v cycles %d", gbc.Register, gbc.Cycles(), want, wantCycles)
+	}
+	if v := gbc.Peek(0xC000); v != 0x42 {
+		t.Errorf("0xC000 = %02X, want 42", v)
+	}
+}
+
+func TestSaveStateKeepsSRAMMapped(t *testing.T) {
+	rom := testROM(
+		0x3E, 0x0A, 0xEA, 0x00, 0x00, // LD A, 0x0A; LD (0x0000), A: enable RAM
+		0x3E, 0x01, 0xEA, 0x00, 0x60, // LD A, 1; LD (0x6000), A: RAM banking mode
+		0x3E, 0x02, 0xEA, 0x00, 0x40, // LD A, 2; LD (0x4000), A: RAM bank 2
+		0x3E, 0x5A, 0xEA, 0x00, 0xA0, // LD A, 0x5A; LD (0xA000), A
+		0xFA, 0x00, 0xA0, // LD A, (0xA000)
+		0x3C,             // INC A
+		0xEA, 0x01, 0xA0, // LD (0xA001), A
+		0x18, 0xFE, // JR -2
+	)
+	rom[0x147], rom[0x149] = 0x03, 0x03 // MBC1+RAM+BATTERY, 4 banks of RAM
+	gbc, err := New(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 8; i++ {
+		gbc.Step()
+	}
+
+	var state bytes.Buffer
+	if err := gbc.SaveState(&state); err != nil {
+		t.Fatal(err)
+	}
+	if bank := gbc.ROMBank(); bank != 1 {
+		t.Errorf("ROM bank %d with RAM bank 2 on a 32KB ROM, want 1", bank)
+	}
+	if !gbc.ramEnabled || gbc.ramBank != 2 || gbc.mbcMode != 1 {
+		t.Fatalf("after SaveState RAM enabled %t, bank %d, mode %d, want true, 2, 1", gbc.ramEnabled, gbc.ramBank, gbc.mbcMode)
+	}
+	for i := 0; i < 3; i++ {
+		gbc.Step()
+	}
+	if a := gbc.REG[A]; a != 0x5B {
+		t.Errorf("A = %02X after reading SRAM, want 5B", a)
+	}
+	if v := gbc.SaveRAM()[2*0x2000+1]; v != 0x5B {
+		t.Errorf("SRAM bank 2 offset 1 = %02X, want 5B", v)
+	}
+
+	fresh, err := New(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fresh.LoadState(&state); err != nil {
+		t.Fatal(err)
+	}
+	if !fresh.ramEnabled || fresh.ramBank != 2 || fresh.mbcMode != 1 {
+		t.Errorf("after LoadState RAM enabled %t, bank %d, mode %d, want true, 2, 1", fresh.ramEnabled, fresh.ramBank, fresh.mbcMode)
+	}
+	if v := fresh.Peek(0xA000); v != 0x5A {
+		t.Errorf("0xA000 = %02X after LoadState, want 5A", v)
+	}
+}
+
+func TestMBC1ROMBank(t *testing.T) {
+	for _, c := range []struct {
+		size   int
+		writes [][2]uint16
+		want   int
+	}{
+		{0x8000, [][2]uint16{{0x2000, 0}}, 1},
+		{0x8000, [][2]uint16{{0x2000, 2}}, 0},
+		{0x8000, [][2]uint16{{0x2000, 3}, {0x4000, 2}}, 1},
+		{0x80000, [][2]uint16{{0x2000, 0x1F}, {0x4000, 3}}, 0x1F},
+		{0x100000, [][2]uint16{{0x4000, 3}, {0x2000, 0}}, 0x21},
+		{0x200000, [][2]uint16{{0x4000, 2}, {0x2000, 0x05}}, 0x45},
+	} {
+		rom := make([]byte, c.size)
+		rom[0x147] = 0x01
+		gbc, err := New(rom)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, w := range c.writes {
+			gbc.Write(w[0], byte(w[1]))
+		}
+		if bank := gbc.ROMBank(); bank != c.want {
+			t.Errorf("%dKB ROM after %v: bank %02X, want %02X", c.size/1024, c.writes, bank, c.want)
+		}
+	}
+}
+
+func TestPokeTracksBank(t *testing.T) {
+	rom := make([]byte, 0x10000)
+	rom[0x147] = 0x01
+	gbc, err := New(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gbc.Poke(0x2000, 3)
+	if bank := gbc.ROMBank(); bank != 3 {
+		t.Errorf("ROM bank %d after poking 0x2000, want 3", bank)
+	}
+}
+
+func TestSaveStateBankedMemory(t *testing.T) {
+	rom := testROM(0x18, 0xFE)
+	rom[0x143] = 0x80
+	gbc, err := New(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gbc.Poke(VBK, 0)
+	gbc.Poke(0x9000, 0x11)
+	gbc.Poke(VBK, 1)
+	gbc.Poke(0x9000, 0x22)
+	gbc.Poke(0xFF70, 5)
+	gbc.Poke(0xD123, 0x55)
+	gbc.Poke(0xFF70, 2)
+	gbc.Poke(0xD123, 0x22)
+	gbc.Poke(OCPS, 0x80|0x10)
+	gbc.Poke(OCPS+1, 0x34)
+	gbc.Poke(OCPS+1, 0x12)
+
+	var state bytes.Buffer
+	if err := gbc.SaveState(&state); err != nil {
+		t.Fatal(err)
+	}
+	fresh, err := New(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fresh.LoadState(&state); err != nil {
+		t.Fatal(err)
+	}
+	if b0, b1 := fresh.PeekVRAM(0, 0x9000), fresh.PeekVRAM(1, 0x9000); b0 != 0x11 || b1 != 0x22 {
+		t.Errorf("9000 is %02X in bank 0 and %02X in bank 1, want 11 and 22", b0, b1)
+	}
+	if v := fresh.Peek(0x9000); v != 0x22 {
+		t.Errorf("9000 reads %02X with bank 1 selected, want 22", v)
+	}
+	if pal := fresh.PaletteRAM(true); pal[0x10] != 0x34 || pal[0x11] != 0x12 {
+		t.Errorf("sprite palette RAM at 10 is % X, want 34 12", pal[0x10:0x12])
+	}
+	if vbk, svbk, ocps := fresh.Peek(VBK)&1, fresh.Peek(0xFF70)&7, fresh.Peek(OCPS); vbk != 1 || svbk != 2 || ocps != 0x92 {
+		t.Errorf("VBK %d SVBK %d OCPS %02X, want 1 2 92", vbk, svbk, ocps)
+	}
+	for bank, want := range map[byte]byte{2: 0x22, 5: 0x55} {
+		fresh.Poke(0xFF70, bank)
+		if v := fresh.Peek(0xD123); v != want {
+			t.Errorf("D123 in WRAM bank %d is %02X, want %02X", bank, v, want)
+		}
+	}
+}
+
+// failingComponent refuses to load anything but what it saved itself.
+type failingComponent struct{ value byte }
+
+func (c *failingComponent) StateID() [4]byte { return [4]byte{'F', 'A', 'I', 'L'} }
+func (c *failingComponent) SaveState(w io.Writer) error {
+	_, err := w.Write([]byte{c.value})
+	return err
+}
+func (c *failingComponent) LoadState(r io.Reader) error {
+	var b [1]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil || b[0] == 0xFF {
+		return ErrNotState
+	}
+	c.value = b[0]
+	return nil
+}
+
+func TestLoadStateFailureLeavesMachine(t *testing.T) {
+	gbc, err := New(testROM(0x18, 0xFE))
+	if err != nil {
+		t.Fatal(err)
+	}
+	good, bad := &failingComponent{1}, &failingComponent{0xFF}
+	gbc.AddStateComponent(good)
+	gbc.AddStateComponent(bad)
+	var state bytes.Buffer
+	if err := gbc.SaveState(&state); err != nil {
+		t.Fatal(err)
+	}
+	good.value = 2
+	gbc.Poke(0xC000, 0x42)
+	if err := gbc.LoadState(&state); err == nil {
+		t.Fatal("LoadState succeeded with a component that can't load")
+	}
+	if good.value != 2 || gbc.Peek(0xC000) != 0x42 {
+		t.Errorf("after a failed load the component holds %d and C000 %02X, want 2 and 42", good.value, gbc.Peek(0xC000))
+	}
+}
+
+type interruptRecorder struct{ vectors []uint16 }
+
+func (r *interruptRecorder) Instruction(gbc *GBC, pc uint16, op byte)         {}
+func (r *interruptRecorder) MemoryAccess(addr uint16, value byte, write bool) {}
+func (r *interruptRecorder) Interrupt(vector uint16)                          { r.vectors = append(r.vectors, vector) }
+
+func TestInterruptDispatch(t *testing.T) {
+	// EI; NOP; NOP; JR -2
+	rec := &interruptRecorder{}
+	gbc, err := New(testROM(0xFB, 0x00, 0x00, 0x18, 0xFE), WithTracer(rec))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gbc.Poke(IE, 0x05)
+	gbc.Poke(0xFF0F, 0x04)
+	gbc.Step() // EI
+	gbc.Step() // the NOP after EI still runs with interrupts off
+	if len(rec.vectors) != 0 || gbc.PC != 0x102 {
+		t.Fatalf("interrupt taken %v before the instruction after EI, PC %04X", rec.vectors, gbc.PC)
+	}
+	cycles := gbc.Cycles()
+	gbc.Step()
+	if len(rec.vectors) != 1 || rec.vectors[0] != 0x50 {
+		t.Fatalf("interrupts %v, want [0050]", rec.vectors)
+	}
+	if gbc.PC != 0x51 || gbc.IME || gbc.Peek(0xFF0F)&0x04 != 0 {
+		t.Errorf("after dispatch PC %04X IME %t IF %02X, want 0051 false with bit 2 clear", gbc.PC, gbc.IME, gbc.Peek(0xFF0F))
+	}
+	if ret := uint16(gbc.Peek(gbc.SP)) | uint16(gbc.Peek(gbc.SP+1))<<8; gbc.SP != 0xFFFC || ret != 0x102 {
+		t.Errorf("SP %04X holding %04X, want FFFC holding 0102", gbc.SP, ret)
+	}
+	if n := gbc.Cycles() - cycles; n != 24 {
+		t.Errorf("dispatch and NOP took %d cycles, want 24", n)
+	}
+}
+
+func TestBESSRoundTrip(t *testing.T) {
+	gbc, err := New(testROM(0x3E, 0x42, 0xEA, 0x00, 0xC0, 0x04, 0x18, 0xFD))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		gbc.Step()
+	}
+
+	var state bytes.Buffer
+	if err := gbc.SaveStateBESS(&state); err != nil {
+		t.Fatal(err)
+	}
+	want := gbc.Register
+
+	for i := 0; i < 10; i++ {
+		gbc.Step()
+	}
+	gbc.Poke(0xC000, 0)
+	if err := gbc.LoadBESS(state.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+	if gbc.Register != want {
+		t.Errorf("registers %+v, want %+v", gbc.Register, want)
+	}
+	if v := gbc.Peek(0xC000); v != 0x42 {
+		t.Errorf("0xC000 = %02X, want 42", v)
+	}
+	if err := gbc.LoadState(bytes.NewReader(state.Bytes())); err != nil {
+		t.Errorf("native load of BESS state: %v", err)
+	}
+}
+
+func TestLoadBESSCorrupt(t *testing.T) {
+	gbc, err := New(testROM(0x18, 0xFE))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state bytes.Buffer
+	if err := gbc.SaveStateBESS(&state); err != nil {
+		t.Fatal(err)
+	}
+	good := state.Bytes()
+	footer := len(good) - 8
+	first := int(binary.LittleEndian.Uint32(good[footer:]))
+
+	badOffset := append([]byte(nil), good...)
+	binary.LittleEndian.PutUint32(badOffset[footer:], uint32(len(good)))
+	badLength := append([]byte(nil), good...)
+	binary.LittleEndian.PutUint32(badLength[first+4:], 0xFFFFFF00)
+	truncated := append(append([]byte(nil), good[:first+6]...), good[footer:]...)
+
+	for name, file := range map[string][]byte{
+		"offset past the end": badOffset,
+		"block too long":      badLength,
+		"truncated":           truncated,
+		"footer only":         good[footer:],
+	} {
+		if err := gbc.LoadBESS(file); err != ErrNotBESS {
+			t.Errorf("%s: got %v, want ErrNotBESS", name, err)
+		}
+	}
+}
+
+func TestRewind(t *testing.T) {
+	gbc, err := New(testROM(0x3E, 0x42, 0xEA, 0x00, 0xC0, 0x04, 0x18, 0xFD))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRewindBuffer(gbc, 1, 1<<20)
+	var regs []Register
+	for i := 0; i < 5; i++ {
+		regs = append(regs, gbc.Register)
+		if err := r.Frame(); err != nil {
+			t.Fatal(err)
+		}
+		gbc.Step()
+	}
+	if r.Len() != 5 {
+		t.Fatalf("Len() = %d, want 5", r.Len())
+	}
+	if _, err := r.Rewind(3); err != nil {
+		t.Fatal(err)
+	}
+	if gbc.Register != regs[1] {
+		t.Errorf("registers %+v, want %+v", gbc.Register, regs[1])
+	}
+}
+
+func TestRewindCGB(t *testing.T) {
+	rom := testROM(0x18, 0xFE)
+	rom[0x143] = 0x80
+	gbc, err := New(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRewindBuffer(gbc, 1, 1<<20)
+	gbc.Poke(VBK, 1)
+	gbc.Poke(0x8800, 0xAA)
+	gbc.Poke(VBK, 0)
+	gbc.Poke(BCPS, 0x06)
+	gbc.Poke(BCPS+1, 0x1F)
+	if err := r.Frame(); err != nil {
+		t.Fatal(err)
+	}
+	gbc.RunFrame()
+
+	gbc.Poke(VBK, 1)
+	gbc.Poke(0x8800, 0xBB)
+	gbc.Poke(VBK, 0)
+	gbc.Poke(BCPS, 0x06)
+	gbc.Poke(BCPS+1, 0xE0)
+	if err := r.Frame(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Rewind(1); err != nil {
+		t.Fatal(err)
+	}
+	if v := gbc.PeekVRAM(1, 0x8800); v != 0xAA {
+		t.Errorf("VRAM bank 1 at 8800 is %02X after rewinding, want AA", v)
+	}
+	gbc.Poke(VBK, 1)
+	if v := gbc.Peek(0x8800); v != 0xAA {
+		t.Errorf("8800 reads %02X in bank 1 after rewinding, want AA", v)
+	}
+	if pal := gbc.PaletteRAM(false); pal[6] != 0x1F {
+		t.Errorf("background palette RAM at 6 is %02X after rewinding, want 1F", pal[6])
+	}
+	if got, want := gbc.colours()[3], cgbColour(0x1F, 0x00); got != want {
+		t.Errorf("palette 0 colour 3 is %v after rewinding, want %v", got, want)
+	}
+}
+
+func TestFramePalette(t *testing.T) {
+	pal, err := ParsePalette("e0f8d0,88c070,346856,#081820")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gbc, err := New(testROM(), WithPalette(pal))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Row 0 of tile 0 is colour 3, and the background map is all tile 0.
+	gbc.Poke(0x8000, 0xFF)
+	gbc.Poke(0x8001, 0xFF)
+	gbc.Poke(BGP, 0xE4)
+	gbc.Poke(LCDC, 0x91)
+
+	img := gbc.Frame()
+	if got, want := img.At(0, 0), PocketGreen[3]; got != want {
+		t.Errorf("row 0 is %v, want %v", got, want)
+	}
+	if got, want := img.At(0, 1), PocketGreen[0]; got != want {
+		t.Errorf("row 1 is %v, want %v", got, want)
+	}
+	rgba := gbc.FrameRGBA(nil)
+	if len(rgba) != SCREEN_WIDTH*SCREEN_HEIGHT*4 || rgba[0] != 0x08 || rgba[2] != 0x20 {
+		t.Errorf("RGBA starts % X", rgba[:4])
+	}
+}
+
+func TestScreenLeavesBanksAlone(t *testing.T) {
+	rom := testROM(0x18, 0xFE)
+	rom[0x143] = 0x80
+	gbc, err := New(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gbc.Poke(VBK, 1)
+	gbc.Poke(0x8000, 0xAA)
+	gbc.Poke(VBK, 0)
+	gbc.Poke(0x8000, 0x55)
+	gbc.Poke(BCPS, 0x82) // palette 0 colour 1, incrementing
+	gbc.Poke(BCPS+1, 0x1F)
+	gbc.Poke(BCPS+1, 0x7C)
+	gbc.Poke(OCPS, 0x3E)
+	gbc.Poke(LCDC, 0x91)
+
+	gbc.Screen()
+	gbc.Tiles()
+	gbc.TileMap(0)
+	gbc.PaletteSwatches()
+	// BCPS has moved on past the two bytes written through it.
+	if vbk, bcps, ocps := gbc.Peek(VBK), gbc.Peek(BCPS), gbc.Peek(OCPS); vbk != 0 || bcps != 0x84 || ocps != 0x3E {
+		t.Errorf("VBK %02X BCPS %02X OCPS %02X after drawing, want 00 84 3E", vbk, bcps, ocps)
+	}
+	if b0, b1 := gbc.PeekVRAM(0, 0x8000), gbc.PeekVRAM(1, 0x8000); b0 != 0x55 || b1 != 0xAA {
+		t.Errorf("8000 is %02X in bank 0 and %02X in bank 1, want 55 and AA", b0, b1)
+	}
+	if pal := gbc.PaletteRAM(false); pal[2] != 0x1F || pal[3] != 0x7C {
+		t.Errorf("background palette RAM starts % X", pal[:4])
+	}
+	if got, want := gbc.colours()[1], cgbColour(0x1F, 0x7C); got != want {
+		t.Errorf("palette 0 colour 1 is %v, want %v", got, want)
+	}
+}
+
+func TestVRAMMirrorFollowsMMU(t *testing.T) {
+	rom := testROM(0x18, 0xFE)
+	rom[0x143] = 0x80
+	rom[0x200] = 0x99
+	gbc, err := New(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A VRAM DMA of 16 bytes from 0200 to 8000 in bank 1. Whatever the MMU
+	// makes of it, the mirror must agree with what Peek reads back.
+	for i, v := range []byte{0x02, 0x00, 0x00, 0x00} {
+		gbc.Poke(0xFF51+uint16(i), v)
+	}
+	gbc.Poke(VBK, 1)
+	gbc.Poke(0xFF55, 0x00)
+	for addr := uint16(0x8000); addr < 0x8010; addr++ {
+		if got, want := gbc.PeekVRAM(1, addr), gbc.Peek(addr); got != want {
+			t.Errorf("mirror has %02X at 1:%04X, the MMU %02X", got, addr, want)
+		}
+	}
+}
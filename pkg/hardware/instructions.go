@@ -1,1814 +1,1819 @@
 package hardware
 
-import "log"
-
 type Instruction struct {
 	label string
-	f     func(g *GBC) uint64
+	f     func(cpu *CPU) uint64
+}
+
+// Label returns the table label for op, e.g. "0x01; LD BC, u16", looking in
+// the CB table when prefixed is set.
+func Label(op byte, prefixed bool) string {
+	if prefixed {
+		return cb_instructions[op].label
+	}
+	return instructions[op].label
 }
 
 var (
 	instructions = [256]Instruction{
 		{
 			"0x00; NOP",
-			func(g *GBC) uint64 {
+			func(cpu *CPU) uint64 {
 				return 4
 			},
 		},
 		{
 			"0x01; LD BC, u16",
-			func(gbc *GBC) uint64 {
-				ldR16u16(gbc, BC)
+			func(cpu *CPU) uint64 {
+				ldR16u16(cpu, BC)
 				return 12
 			},
 		},
 		{
 			"0x02; LD (BC), A",
-			func(gbc *GBC) uint64 {
-				ldnnR8(gbc, gbc.Reg16(BC), A)
+			func(cpu *CPU) uint64 {
+				ldnnR8(cpu, cpu.Reg16(BC), A)
 				return 8
 			},
 		},
 		{
 			"0x03; INC BC",
-			func(gbc *GBC) uint64 {
-				incR16(gbc, BC)
+			func(cpu *CPU) uint64 {
+				incR16(cpu, BC)
 				return 8
 			},
 		},
 		{
 			"0x04; INC B",
-			func(gbc *GBC) uint64 {
-				incR8(gbc, B)
+			func(cpu *CPU) uint64 {
+				incR8(cpu, B)
 				return 4
 			},
 		},
 		{
 			"0x05; DEC B",
-			func(gbc *GBC) uint64 {
-				decR8(gbc, B)
+			func(cpu *CPU) uint64 {
+				decR8(cpu, B)
 				return 4
 			},
 		},
 		{
 			"0x06; LD B, u8",
-			func(gbc *GBC) uint64 {
-				ldR8u8(gbc, B)
+			func(cpu *CPU) uint64 {
+				ldR8u8(cpu, B)
 				return 8
 			},
 		},
 		{
 			"0x07; RLCA",
-			func(gbc *GBC) uint64 {
-				rlca(gbc)
+			func(cpu *CPU) uint64 {
+				rlca(cpu)
 				return 4
 			},
 		},
 		{
 			"0x08; LD (u16), SP",
-			func(gbc *GBC) uint64 {
-				l, u := uint16(gbc.Read(gbc.currPC+1)), uint16(gbc.Read(gbc.currPC+2))
-				gbc.PC += 2
+			func(cpu *CPU) uint64 {
+				l, u := cpu.operands()
+				cpu.PC += 2
 				addr := (u << 8) | l
-				uu, ll := byte(gbc.SP>>8), byte(gbc.SP)
-				gbc.Write(addr, ll)
-				gbc.Write(addr+1, uu)
+				uu, ll := byte(cpu.SP>>8), byte(cpu.SP)
+				cpu.Write(addr, ll)
+				cpu.Write(addr+1, uu)
 				return 20
 			},
 		},
 		{
 			"0x09; ADD HL, BC",
-			func(gbc *GBC) uint64 {
-				addR16(gbc, HL, BC)
+			func(cpu *CPU) uint64 {
+				addR16(cpu, HL, BC)
 				return 8
 			},
 		},
 		{
 			"0x0A; LD A, (BC)",
-			func(gbc *GBC) uint64 {
-				ldR8nn(gbc, A, gbc.Read(gbc.Reg16(BC)))
+			func(cpu *CPU) uint64 {
+				ldR8nn(cpu, A, cpu.Read(cpu.Reg16(BC)))
 				return 8
 			},
 		},
 		{
 			"0x0B; DEC BC",
-			func(gbc *GBC) uint64 {
-				decR16(gbc, BC)
+			func(cpu *CPU) uint64 {
+				decR16(cpu, BC)
 				return 8
 			},
 		},
 		{
 			"0x0C; INC C",
-			func(gbc *GBC) uint64 {
-				incR8(gbc, C)
+			func(cpu *CPU) uint64 {
+				incR8(cpu, C)
 				return 4
 			},
 		},
 		{
 			"0x0D; DEC C",
-			func(gbc *GBC) uint64 {
-				decR8(gbc, C)
+			func(cpu *CPU) uint64 {
+				decR8(cpu, C)
 				return 4
 			},
 		},
 		{
 			"0x0E; LD C, u8",
-			func(gbc *GBC) uint64 {
-				ldR8u8(gbc, C)
+			func(cpu *CPU) uint64 {
+				ldR8u8(cpu, C)
 				return 8
 			},
 		},
 		{
 			"0x0F; RRCA",
-			func(gbc *GBC) uint64 {
-				rrca(gbc)
+			func(cpu *CPU) uint64 {
+				rrca(cpu)
 				return 4
 			},
 		},
 		{
 			"0x10; STOP",
-			func(gbc *GBC) uint64 {
-				stop(gbc)
+			func(cpu *CPU) uint64 {
+				stop(cpu)
 				return 4
 			},
 		},
 		{
 			"0x11; LD DE, u16",
-			func(gbc *GBC) uint64 {
-				ldR16u16(gbc, DE)
+			func(cpu *CPU) uint64 {
+				ldR16u16(cpu, DE)
 				return 12
 			},
 		},
 		{
 			"0x12; LD (DE), A",
-			func(gbc *GBC) uint64 {
-				ldnnR8(gbc, gbc.Reg16(DE), A)
+			func(cpu *CPU) uint64 {
+				ldnnR8(cpu, cpu.Reg16(DE), A)
 				return 8
 			},
 		},
 		{
 			"0x13; INC DE",
-			func(gbc *GBC) uint64 {
-				incR16(gbc, DE)
+			func(cpu *CPU) uint64 {
+				incR16(cpu, DE)
 				return 8
 			},
 		},
 		{
 			"0x14; INC D",
-			func(gbc *GBC) uint64 {
-				incR8(gbc, D)
+			func(cpu *CPU) uint64 {
+				incR8(cpu, D)
 				return 4
 			},
 		},
 		{
 			"0x15; DEC D",
-			func(gbc *GBC) uint64 {
-				decR8(gbc, D)
+			func(cpu *CPU) uint64 {
+				decR8(cpu, D)
 				return 4
 			},
 		},
 		{
 			"0x16; LD D, u8",
-			func(gbc *GBC) uint64 {
-				ldR8u8(gbc, D)
+			func(cpu *CPU) uint64 {
+				ldR8u8(cpu, D)
 				return 8
 			},
 		},
 		{
 			"0x17; RLA",
-			func(gbc *GBC) uint64 {
-				rla(gbc)
+			func(cpu *CPU) uint64 {
+				rla(cpu)
 				return 4
 			},
 		},
 		{
 			"0x18; JR i8",
-			func(gbc *GBC) uint64 {
-				jri8(gbc)
+			func(cpu *CPU) uint64 {
+				jri8(cpu)
 				return 12
 			},
 		},
 		{
 			"0x19; ADD HL, DE",
-			func(gbc *GBC) uint64 {
-				addR16(gbc, HL, DE)
+			func(cpu *CPU) uint64 {
+				addR16(cpu, HL, DE)
 				return 8
 			},
 		},
 		{
 			"0x1A; LD A, (DE)",
-			func(gbc *GBC) uint64 {
-				ldR8nn(gbc, A, gbc.Read(gbc.Reg16(DE)))
+			func(cpu *CPU) uint64 {
+				ldR8nn(cpu, A, cpu.Read(cpu.Reg16(DE)))
 				return 8
 			},
 		},
 		{
 			"0x1B; DEC DE",
-			func(gbc *GBC) uint64 {
-				decR16(gbc, DE)
+			func(cpu *CPU) uint64 {
+				decR16(cpu, DE)
 				return 8
 			},
 		},
 		{
 			"0x1C; INC E",
-			func(gbc *GBC) uint64 {
-				incR8(gbc, E)
+			func(cpu *CPU) uint64 {
+				incR8(cpu, E)
 				return 4
 			},
 		},
 		{
 			"0x1D; DEC E",
-			func(gbc *GBC) uint64 {
-				decR8(gbc, E)
+			func(cpu *CPU) uint64 {
+				decR8(cpu, E)
 				return 4
 			},
 		},
 		{
 			"0x1E; LD E, u8",
-			func(gbc *GBC) uint64 {
-				ldR8u8(gbc, E)
+			func(cpu *CPU) uint64 {
+				ldR8u8(cpu, E)
 				return 8
 			},
 		},
 		{
 			"0x1F; RRA",
-			func(gbc *GBC) uint64 {
-				rra(gbc)
+			func(cpu *CPU) uint64 {
+				rra(cpu)
 				return 4
 			},
 		},
 		{
 			"0x20; JR NZ, i8",
-			func(gbc *GBC) uint64 {
-				return jrncc(gbc, ZERO)
+			func(cpu *CPU) uint64 {
+				return jrncc(cpu, ZERO)
 			},
 		},
 		{
 			"0x21; LD HL, u16",
-			func(gbc *GBC) uint64 {
-				ldR16u16(gbc, HL)
+			func(cpu *CPU) uint64 {
+				ldR16u16(cpu, HL)
 				return 12
 			},
 		},
 		{
 			"0x22; LD (HL+), A",
-			func(gbc *GBC) uint64 {
-				ldHLINCA(gbc)
+			func(cpu *CPU) uint64 {
+				ldHLINCA(cpu)
 				return 8
 			},
 		},
 		{
 			"0x23; INC HL",
-			func(gbc *GBC) uint64 {
-				incR16(gbc, HL)
+			func(cpu *CPU) uint64 {
+				incR16(cpu, HL)
 				return 8
 			},
 		},
 		{
 			"0x24; INC H",
-			func(gbc *GBC) uint64 {
-				incR8(gbc, H)
+			func(cpu *CPU) uint64 {
+				incR8(cpu, H)
 				return 4
 			},
 		},
 		{
 			"0x25; DEC H",
-			func(gbc *GBC) uint64 {
-				decR8(gbc, H)
+			func(cpu *CPU) uint64 {
+				decR8(cpu, H)
 				return 4
 			},
 		},
 		{
 			"0x26; LD H, u8",
-			func(gbc *GBC) uint64 {
-				ldR8u8(gbc, H)
+			func(cpu *CPU) uint64 {
+				ldR8u8(cpu, H)
 				return 8
 			},
 		},
 		{
 			"0x27; DAA",
-			func(gbc *GBC) uint64 {
-				daa(gbc)
+			func(cpu *CPU) uint64 {
+				daa(cpu)
 				return 4
 			},
 		},
 		{
 			"0x28; JR Z, i8",
-			func(gbc *GBC) uint64 {
-				return jrcc(gbc, ZERO)
+			func(cpu *CPU) uint64 {
+				return jrcc(cpu, ZERO)
 			},
 		},
 		{
 			"0x29; ADD HL, HL",
-			func(gbc *GBC) uint64 {
-				addR16(gbc, HL, HL)
+			func(cpu *CPU) uint64 {
+				addR16(cpu, HL, HL)
 				return 8
 			},
 		},
 		{
 			"0x2A; LD A, (HL+)",
-			func(gbc *GBC) uint64 {
-				ldAHLINC(gbc)
+			func(cpu *CPU) uint64 {
+				ldAHLINC(cpu)
 				return 8
 			},
 		},
 		{
 			"0x2B; DEC HL",
-			func(gbc *GBC) uint64 {
-				decR16(gbc, HL)
+			func(cpu *CPU) uint64 {
+				decR16(cpu, HL)
 				return 8
 			},
 		},
 		{
 			"0x2C; INC L",
-			func(gbc *GBC) uint64 {
-				incR8(gbc, L)
+			func(cpu *CPU) uint64 {
+				incR8(cpu, L)
 				return 4
 			},
 		},
 		{
 			"0x2D; DEC L",
-			func(gbc *GBC) uint64 {
-				decR8(gbc, L)
+			func(cpu *CPU) uint64 {
+				decR8(cpu, L)
 				return 4
 			},
 		},
 		{
 			"0x2E; LD L, u8",
-			func(gbc *GBC) uint64 {
-				ldR8u8(gbc, L)
+			func(cpu *CPU) uint64 {
+				ldR8u8(cpu, L)
 				return 8
 			},
 		},
 		{
 			"0x2F; CPL",
-			func(gbc *GBC) uint64 {
-				cpl(gbc)
+			func(cpu *CPU) uint64 {
+				cpl(cpu)
 				return 4
 			},
 		},
 		{
 			"0x30; JR NC, i8",
-			func(gbc *GBC) uint64 {
-				return jrncc(gbc, CARRY)
+			func(cpu *CPU) uint64 {
+				return jrncc(cpu, CARRY)
 			},
 		},
 		{
 			"0x31; LD SP, u16",
-			func(gbc *GBC) uint64 {
-				ldR16u16(gbc, SP)
+			func(cpu *CPU) uint64 {
+				ldR16u16(cpu, SP)
 				return 12
 			},
 		},
 		{
 			"0x32; LD (HL-), A",
 
-			func(gbc *GBC) uint64 {
-				ldHLDECA(gbc)
+			func(cpu *CPU) uint64 {
+				ldHLDECA(cpu)
 				return 8
 			},
 		},
 		{
 			"0x33; INC SP",
-			func(gbc *GBC) uint64 {
-				gbc.SP++
+			func(cpu *CPU) uint64 {
+				cpu.SP++
 				return 8
 			},
 		},
 		{
 			"0x34; INC (HL)",
-			func(gbc *GBC) uint64 {
-				indirectIncHL(gbc)
+			func(cpu *CPU) uint64 {
+				indirectIncHL(cpu)
 				return 12
 			},
 		},
 		{
 			"0x35; DEC (HL)",
-			func(gbc *GBC) uint64 {
-				indirectDecHL(gbc)
+			func(cpu *CPU) uint64 {
+				indirectDecHL(cpu)
 				return 12
 			},
 		},
 		{
 			"0x36; LD (HL), u8",
-			func(gbc *GBC) uint64 {
-				indirectLDR16u8(gbc, HL)
+			func(cpu *CPU) uint64 {
+				indirectLDR16u8(cpu, HL)
 				return 12
 			},
 		},
 		{
 			"0x37; SCF",
-			func(gbc *GBC) uint64 {
-				scf(gbc)
+			func(cpu *CPU) uint64 {
+				scf(cpu)
 				return 4
 			},
 		},
 		{
 			"0x38; JR C, i8",
-			func(gbc *GBC) uint64 {
-				return jrcc(gbc, CARRY)
+			func(cpu *CPU) uint64 {
+				return jrcc(cpu, CARRY)
 			},
 		},
 		{
 			"0x39; ADD HL, SP",
-			func(gbc *GBC) uint64 {
-				addR16(gbc, HL, SP)
+			func(cpu *CPU) uint64 {
+				addR16(cpu, HL, SP)
 				return 8
 			},
 		},
 		{
 			"0x3A; LD A, (HL-)",
-			func(gbc *GBC) uint64 {
-				ldAHLDEC(gbc)
+			func(cpu *CPU) uint64 {
+				ldAHLDEC(cpu)
 				return 8
 			},
 		},
 		{
 			"0x3B; DEC SP",
-			func(gbc *GBC) uint64 {
-				gbc.SP--
+			func(cpu *CPU) uint64 {
+				cpu.SP--
 				return 8
 			},
 		},
 		{
 			"0x3C; INC A",
-			func(gbc *GBC) uint64 {
-				incR8(gbc, A)
+			func(cpu *CPU) uint64 {
+				incR8(cpu, A)
 				return 4
 			},
 		},
 		{
 			"0x3D; DEC A",
-			func(gbc *GBC) uint64 {
-				decR8(gbc, A)
+			func(cpu *CPU) uint64 {
+				decR8(cpu, A)
 				return 4
 			},
 		},
 		{
 			"0x3E; LD A, u8",
-			func(gbc *GBC) uint64 {
-				ldR8u8(gbc, A)
+			func(cpu *CPU) uint64 {
+				ldR8u8(cpu, A)
 				return 8
 			},
 		},
 		{
 			"0x3F; CCF",
-			func(gbc *GBC) uint64 {
-				ccf(gbc)
+			func(cpu *CPU) uint64 {
+				ccf(cpu)
 				return 4
 			},
 		},
 		{
 			"0x40; LD B, B",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, B, B)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, B, B)
 				return 4
 			},
 		},
 		{
 			"0x41; LD B, C",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, B, C)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, B, C)
 				return 4
 			},
 		},
 		{
 			"0x42; LD B, D",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, B, D)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, B, D)
 				return 4
 			},
 		},
 		{
 			"0x43; LD B, E",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, B, E)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, B, E)
 				return 4
 			},
 		},
 		{
 			"0x44; LD B, H",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, B, H)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, B, H)
 				return 4
 			},
 		},
 		{
 			"0x45; LD B, L",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, B, L)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, B, L)
 				return 4
 			},
 		},
 		{
 			"0x46; LD B, (HL)",
-			func(gbc *GBC) uint64 {
-				ldR8nn(gbc, B, gbc.Read(gbc.Reg16(HL)))
+			func(cpu *CPU) uint64 {
+				ldR8nn(cpu, B, cpu.Read(cpu.Reg16(HL)))
 				return 8
 			},
 		},
 		{
 			"0x47; LD B, A",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, B, A)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, B, A)
 				return 4
 			},
 		},
 		{
 			"0x48; LD C, B",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, C, B)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, C, B)
 				return 4
 			},
 		},
 		{
 			"0x49; LD C, C",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, C, C)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, C, C)
 				return 4
 			},
 		},
 		{
 			"0x4A; LD C, D",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, C, D)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, C, D)
 				return 4
 			},
 		},
 		{
 			"0x4B; LD C, E",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, C, E)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, C, E)
 				return 4
 			},
 		},
 		{
 			"0x4C; LD C, H",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, C, H)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, C, H)
 				return 4
 			},
 		},
 		{
 			"0x4D; LD C, L",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, C, L)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, C, L)
 				return 4
 			},
 		},
 		{
 			"0x4E; LD C, (HL)",
-			func(gbc *GBC) uint64 {
-				ldR8nn(gbc, C, gbc.Read(gbc.Reg16(HL)))
+			func(cpu *CPU) uint64 {
+				ldR8nn(cpu, C, cpu.Read(cpu.Reg16(HL)))
 				return 8
 			},
 		},
 		{
 			"0x4F; LD C, A",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, C, A)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, C, A)
 				return 4
 			},
 		},
 		{
 			"0x50; LD D, B",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, D, B)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, D, B)
 				return 4
 			},
 		},
 		{
 			"0x51; LD D, C",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, D, C)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, D, C)
 				return 4
 			},
 		},
 		{
 			"0x52; LD D, D",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, D, D)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, D, D)
 				return 4
 			},
 		},
 		{
 			"0x53; LD D, E",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, D, E)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, D, E)
 				return 4
 			},
 		},
 		{
 			"0x54; LD D, H",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, D, H)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, D, H)
 				return 4
 			},
 		},
 		{
 			"0x55; LD D, L",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, D, L)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, D, L)
 				return 4
 			},
 		},
 		{
 			"0x56; LD D, (HL)",
-			func(gbc *GBC) uint64 {
-				ldR8nn(gbc, D, gbc.Read(gbc.Reg16(HL)))
+			func(cpu *CPU) uint64 {
+				ldR8nn(cpu, D, cpu.Read(cpu.Reg16(HL)))
 				return 8
 			},
 		},
 		{
 			"0x57; LD D, A",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, D, A)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, D, A)
 				return 4
 			},
 		},
 		{
 			"0x58; LD E, B",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, E, B)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, E, B)
 				return 4
 			},
 		},
 		{
 			"0x59; LD E, C",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, E, C)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, E, C)
 				return 4
 			},
 		},
 		{
 			"0x5A; LD E, D",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, E, D)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, E, D)
 				return 4
 			},
 		},
 		{
 			"0x5B; LD E, E",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, E, F)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, E, F)
 				return 4
 			},
 		},
 		{
 			"0x5C; LD E, H",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, E, H)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, E, H)
 				return 4
 			},
 		},
 		{
 			"0x5D; LD E, L",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, E, L)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, E, L)
 				return 4
 			},
 		},
 		{
 			"0x5E; LD E, (HL)",
-			func(gbc *GBC) uint64 {
-				ldR8nn(gbc, E, gbc.Read(gbc.Reg16(HL)))
+			func(cpu *CPU) uint64 {
+				ldR8nn(cpu, E, cpu.Read(cpu.Reg16(HL)))
 				return 8
 			},
 		},
 		{
 			"0x5F; LD E, A",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, E, A)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, E, A)
 				return 4
 			},
 		},
 		{
 			"0x60; LD H, B",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, H, B)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, H, B)
 				return 4
 			},
 		},
 		{
 			"0x61; LD H, C",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, H, C)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, H, C)
 				return 4
 			},
 		},
 		{
 			"0x62; LD H, D",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, H, D)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, H, D)
 				return 4
 			},
 		},
 		{
 			"0x63; LD H, E",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, H, E)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, H, E)
 				return 4
 			},
 		},
 		{
 			"0x64; LD H, H",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, H, H)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, H, H)
 				return 4
 			},
 		},
 		{
 			"0x65; LD H, L",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, H, L)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, H, L)
 				return 4
 			},
 		},
 		{
 			"0x66; LD H, (HL)",
-			func(gbc *GBC) uint64 {
-				ldR8nn(gbc, H, gbc.Read(gbc.Reg16(HL)))
+			func(cpu *CPU) uint64 {
+				ldR8nn(cpu, H, cpu.Read(cpu.Reg16(HL)))
 				return 8
 			},
 		},
 		{
 			"0x67; LD H, A",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, H, A)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, H, A)
 				return 4
 			},
 		},
 		{
 			"0x68; LD L, B",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, L, B)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, L, B)
 				return 4
 			},
 		},
 		{
 			"0x69; LD L, C",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, L, C)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, L, C)
 				return 4
 			},
 		},
 		{
 			"0x6A; LD L, D",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, L, D)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, L, D)
 				return 4
 			},
 		},
 		{
 			"0x6B; LD L, E",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, L, E)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, L, E)
 				return 4
 			},
 		},
 		{
 			"0x6C; LD L, H",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, L, H)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, L, H)
 				return 4
 			},
 		},
 		{
 			"0x6D; LD L, L",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, L, L)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, L, L)
 				return 4
 			},
 		},
 		{
 			"0x6E; LD L, (HL)",
-			func(gbc *GBC) uint64 {
-				ldR8nn(gbc, L, gbc.Read(gbc.Reg16(HL)))
+			func(cpu *CPU) uint64 {
+				ldR8nn(cpu, L, cpu.Read(cpu.Reg16(HL)))
 				return 8
 			},
 		},
 		{
 			"0x6F; LD L, A",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, L, A)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, L, A)
 				return 4
 			},
 		},
 		{
 			"0x70; LD (HL), B",
-			func(gbc *GBC) uint64 {
-				ldnnR8(gbc, gbc.Reg16(HL), B)
+			func(cpu *CPU) uint64 {
+				ldnnR8(cpu, cpu.Reg16(HL), B)
 				return 8
 			},
 		},
 		{
 			"0x71; LD (HL), C",
-			func(gbc *GBC) uint64 {
-				ldnnR8(gbc, gbc.Reg16(HL), C)
+			func(cpu *CPU) uint64 {
+				ldnnR8(cpu, cpu.Reg16(HL), C)
 				return 8
 			},
 		},
 		{
 			"0x72; LD (HL), D",
-			func(gbc *GBC) uint64 {
-				ldnnR8(gbc, gbc.Reg16(HL), D)
+			func(cpu *CPU) uint64 {
+				ldnnR8(cpu, cpu.Reg16(HL), D)
 				return 8
 			},
 		},
 		{
 			"0x73; LD (HL), E",
-			func(gbc *GBC) uint64 {
-				ldnnR8(gbc, gbc.Reg16(HL), E)
+			func(cpu *CPU) uint64 {
+				ldnnR8(cpu, cpu.Reg16(HL), E)
 				return 8
 			},
 		},
 		{
 			"0x74; LD (HL), H",
-			func(gbc *GBC) uint64 {
-				ldnnR8(gbc, gbc.Reg16(HL), H)
+			func(cpu *CPU) uint64 {
+				ldnnR8(cpu, cpu.Reg16(HL), H)
 				return 8
 			},
 		},
 		{
 			"0x75; LD (HL), L",
-			func(gbc *GBC) uint64 {
-				ldnnR8(gbc, gbc.Reg16(HL), L)
+			func(cpu *CPU) uint64 {
+				ldnnR8(cpu, cpu.Reg16(HL), L)
 				return 8
 			},
 		},
 		{
 			"0x76; HALT",
-			func(gbc *GBC) uint64 {
-				halt(gbc)
+			func(cpu *CPU) uint64 {
+				halt(cpu)
 				return 4
 			},
 		},
 		{
 			"0x77; LD (HL), A",
-			func(gbc *GBC) uint64 {
-				ldnnR8(gbc, gbc.Reg16(HL), A)
+			func(cpu *CPU) uint64 {
+				ldnnR8(cpu, cpu.Reg16(HL), A)
 				return 8
 			},
 		},
 		{
 			"0x78; LD A, B",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, A, B)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, A, B)
 				return 4
 			},
 		},
 		{
 			"0x79; LD A, C",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, A, C)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, A, C)
 				return 4
 			},
 		},
 		{
 			"0x7A; LD A, D",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, A, D)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, A, D)
 				return 4
 			},
 		},
 		{
 			"0x7B; LD A, E",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, A, E)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, A, E)
 				return 4
 			},
 		},
 		{
 			"0x7C; LD A, H",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, A, H)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, A, H)
 				return 4
 			},
 		},
 		{
 			"0x7D; LD A, L",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, A, L)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, A, L)
 				return 4
 			},
 		},
 		{
 			"0x7E; LD A, (HL)",
-			func(gbc *GBC) uint64 {
-				ldR8nn(gbc, A, gbc.Read(gbc.Reg16(HL)))
+			func(cpu *CPU) uint64 {
+				ldR8nn(cpu, A, cpu.Read(cpu.Reg16(HL)))
 				return 8
 			},
 		},
 		{
 			"0x7F; LD A, A",
-			func(gbc *GBC) uint64 {
-				ldR8(gbc, A, A)
+			func(cpu *CPU) uint64 {
+				ldR8(cpu, A, A)
 				return 4
 			},
 		},
 		{
 			"0x80; ADD A, B",
-			func(gbc *GBC) uint64 {
-				add(gbc, gbc.REG[B])
+			func(cpu *CPU) uint64 {
+				add(cpu, cpu.REG[B])
 				return 4
 			},
 		},
 		{
 			"0x81; ADD A, C",
-			func(gbc *GBC) uint64 {
-				add(gbc, gbc.REG[C])
+			func(cpu *CPU) uint64 {
+				add(cpu, cpu.REG[C])
 				return 4
 			},
 		},
 		{
 			"0x82; ADD A, D",
-			func(gbc *GBC) uint64 {
-				add(gbc, gbc.REG[D])
+			func(cpu *CPU) uint64 {
+				add(cpu, cpu.REG[D])
 				return 4
 			},
 		},
 		{
 			"0x83; ADD A, E",
-			func(gbc *GBC) uint64 {
-				add(gbc, gbc.REG[E])
+			func(cpu *CPU) uint64 {
+				add(cpu, cpu.REG[E])
 				return 4
 			},
 		},
 		{
 			"0x84; ADD A, H",
-			func(gbc *GBC) uint64 {
-				add(gbc, gbc.REG[H])
+			func(cpu *CPU) uint64 {
+				add(cpu, cpu.REG[H])
 				return 4
 			},
 		},
 		{
 			"0x85; ADD A, L",
-			func(gbc *GBC) uint64 {
-				add(gbc, gbc.REG[L])
+			func(cpu *CPU) uint64 {
+				add(cpu, cpu.REG[L])
 				return 4
 			},
 		},
 		{
 			"0x86; ADD A, (HL)",
-			func(gbc *GBC) uint64 {
-				add(gbc, gbc.Read(gbc.Reg16(HL)))
+			func(cpu *CPU) uint64 {
+				add(cpu, cpu.Read(cpu.Reg16(HL)))
 				return 8
 			},
 		},
 		{
 			"0x87; ADD A, A",
-			func(gbc *GBC) uint64 {
-				add(gbc, gbc.REG[A])
+			func(cpu *CPU) uint64 {
+				add(cpu, cpu.REG[A])
 				return 4
 			},
 		},
 		{
 			"0x88; ADC A, B",
-			func(gbc *GBC) uint64 {
-				adc(gbc, gbc.REG[B])
+			func(cpu *CPU) uint64 {
+				adc(cpu, cpu.REG[B])
 				return 4
 			},
 		},
 		{
 			"0x89; ADC A, C",
-			func(gbc *GBC) uint64 {
-				adc(gbc, gbc.REG[C])
+			func(cpu *CPU) uint64 {
+				adc(cpu, cpu.REG[C])
 				return 4
 			},
 		},
 		{
 			"0x8A; ADC A, D",
-			func(gbc *GBC) uint64 {
-				adc(gbc, gbc.REG[D])
+			func(cpu *CPU) uint64 {
+				adc(cpu, cpu.REG[D])
 				return 4
 			},
 		},
 		{
 			"0x8B; ADC A, E",
-			func(gbc *GBC) uint64 {
-				adc(gbc, gbc.REG[E])
+			func(cpu *CPU) uint64 {
+				adc(cpu, cpu.REG[E])
 				return 4
 			},
 		},
 		{
 			"0x8C; ADC A, H",
-			func(gbc *GBC) uint64 {
-				adc(gbc, gbc.REG[H])
+			func(cpu *CPU) uint64 {
+				adc(cpu, cpu.REG[H])
 				return 4
 			},
 		},
 		{
 			"0x8D; ADC A, L",
-			func(gbc *GBC) uint64 {
-				adc(gbc, gbc.REG[L])
+			func(cpu *CPU) uint64 {
+				adc(cpu, cpu.REG[L])
 				return 4
 			},
 		},
 		{
 			"0x8E; ADC A, (HL)",
-			func(gbc *GBC) uint64 {
-				adc(gbc, gbc.Read(gbc.Reg16(HL)))
+			func(cpu *CPU) uint64 {
+				adc(cpu, cpu.Read(cpu.Reg16(HL)))
 				return 8
 			},
 		},
 		{
 			"0x8F; ADC A, A",
-			func(gbc *GBC) uint64 {
-				adc(gbc, gbc.REG[A])
+			func(cpu *CPU) uint64 {
+				adc(cpu, cpu.REG[A])
 				return 4
 			},
 		},
 		{
 			"0x90; SUB A, B",
-			func(gbc *GBC) uint64 {
-				sub(gbc, gbc.REG[B])
+			func(cpu *CPU) uint64 {
+				sub(cpu, cpu.REG[B])
 				return 4
 			},
 		},
 		{
 			"0x91; SUB A, C",
-			func(gbc *GBC) uint64 {
-				sub(gbc, gbc.REG[C])
+			func(cpu *CPU) uint64 {
+				sub(cpu, cpu.REG[C])
 				return 4
 			},
 		},
 		{
 			"0x92; SUB A, D",
-			func(gbc *GBC) uint64 {
-				sub(gbc, gbc.REG[D])
+			func(cpu *CPU) uint64 {
+				sub(cpu, cpu.REG[D])
 				return 4
 			},
 		},
 		{
 			"0x93; SUB A, E",
-			func(gbc *GBC) uint64 {
-				sub(gbc, gbc.REG[E])
+			func(cpu *CPU) uint64 {
+				sub(cpu, cpu.REG[E])
 				return 4
 			},
 		},
 		{
 			"0x94; SUB A, H",
-			func(gbc *GBC) uint64 {
-				sub(gbc, gbc.REG[H])
+			func(cpu *CPU) uint64 {
+				sub(cpu, cpu.REG[H])
 				return 4
 			},
 		},
 		{
 			"0x95; SUB A, L",
-			func(gbc *GBC) uint64 {
-				sub(gbc, gbc.REG[L])
+			func(cpu *CPU) uint64 {
+				sub(cpu, cpu.REG[L])
 				return 4
 			},
 		},
 		{
 			"0x96; SUB A, (HL)",
-			func(gbc *GBC) uint64 {
-				sub(gbc, gbc.Read(gbc.Reg16(HL)))
+			func(cpu *CPU) uint64 {
+				sub(cpu, cpu.Read(cpu.Reg16(HL)))
 				return 8
 			},
 		},
 		{
 			"0x97; SUB A, A",
-			func(gbc *GBC) uint64 {
-				sub(gbc, gbc.REG[A])
+			func(cpu *CPU) uint64 {
+				sub(cpu, cpu.REG[A])
 				return 4
 			},
 		},
 		{
 			"0x98; SBC A, B",
-			func(gbc *GBC) uint64 {
-				sbc(gbc, gbc.REG[B])
+			func(cpu *CPU) uint64 {
+				sbc(cpu, cpu.REG[B])
 				return 4
 			},
 		},
 		{
 			"0x99; SBC A, C",
-			func(gbc *GBC) uint64 {
-				sbc(gbc, gbc.REG[C])
+			func(cpu *CPU) uint64 {
+				sbc(cpu, cpu.REG[C])
 				return 4
 			},
 		},
 		{
 			"0x9A; SBC A, D",
-			func(gbc *GBC) uint64 {
-				sbc(gbc, gbc.REG[D])
+			func(cpu *CPU) uint64 {
+				sbc(cpu, cpu.REG[D])
 				return 4
 			},
 		},
 		{
 			"0x9B; SBC A, E",
-			func(gbc *GBC) uint64 {
-				sbc(gbc, gbc.REG[E])
+			func(cpu *CPU) uint64 {
+				sbc(cpu, cpu.REG[E])
 				return 4
 			},
 		},
 		{
 			"0x9C; SBC A, H",
-			func(gbc *GBC) uint64 {
-				sbc(gbc, gbc.REG[H])
+			func(cpu *CPU) uint64 {
+				sbc(cpu, cpu.REG[H])
 				return 4
 			},
 		},
 		{
 			"0x9D; SBC A, L",
-			func(gbc *GBC) uint64 {
-				sbc(gbc, gbc.REG[L])
+			func(cpu *CPU) uint64 {
+				sbc(cpu, cpu.REG[L])
 				return 4
 			},
 		},
 		{
 			"0x9E; SBC A, (HL)",
-			func(gbc *GBC) uint64 {
-				sbc(gbc, gbc.Read(gbc.Reg16(HL)))
+			func(cpu *CPU) uint64 {
+				sbc(cpu, cpu.Read(cpu.Reg16(HL)))
 				return 8
 			},
 		},
 		{
 			"0x9F; SBC A, A",
-			func(gbc *GBC) uint64 {
-				sbc(gbc, gbc.REG[A])
+			func(cpu *CPU) uint64 {
+				sbc(cpu, cpu.REG[A])
 				return 4
 			},
 		},
 		{
 			"0xA0; AND A, B",
-			func(gbc *GBC) uint64 {
-				and(gbc, gbc.REG[B])
+			func(cpu *CPU) uint64 {
+				and(cpu, cpu.REG[B])
 				return 4
 			},
 		},
 		{
 			"0xA1; AND A, C",
-			func(gbc *GBC) uint64 {
-				and(gbc, gbc.REG[C])
+			func(cpu *CPU) uint64 {
+				and(cpu, cpu.REG[C])
 				return 4
 			},
 		},
 		{
 			"0xA2; AND A, D",
-			func(gbc *GBC) uint64 {
-				and(gbc, gbc.REG[D])
+			func(cpu *CPU) uint64 {
+				and(cpu, cpu.REG[D])
 				return 4
 			},
 		},
 		{
 			"0xA3; AND A, E",
-			func(gbc *GBC) uint64 {
-				and(gbc, gbc.REG[E])
+			func(cpu *CPU) uint64 {
+				and(cpu, cpu.REG[E])
 				return 4
 			},
 		},
 		{
 			"0xA4; AND A, H",
-			func(gbc *GBC) uint64 {
-				and(gbc, gbc.REG[H])
+			func(cpu *CPU) uint64 {
+				and(cpu, cpu.REG[H])
 				return 4
 			},
 		},
 		{
 			"0xA5; AND A, L",
-			func(gbc *GBC) uint64 {
-				and(gbc, gbc.REG[L])
+			func(cpu *CPU) uint64 {
+				and(cpu, cpu.REG[L])
 				return 4
 			},
 		},
 		{
 			"0xA6; AND A, (HL)",
-			func(gbc *GBC) uint64 {
-				and(gbc, gbc.Read(gbc.Reg16(HL)))
+			func(cpu *CPU) uint64 {
+				and(cpu, cpu.Read(cpu.Reg16(HL)))
 				return 8
 			},
 		},
 		{
 			"0xA7; AND A, A",
-			func(gbc *GBC) uint64 {
-				and(gbc, gbc.REG[A])
+			func(cpu *CPU) uint64 {
+				and(cpu, cpu.REG[A])
 				return 4
 			},
 		},
 		{
 			"0xA8; XOR A, B",
-			func(gbc *GBC) uint64 {
-				xor(gbc, gbc.REG[B])
+			func(cpu *CPU) uint64 {
+				xor(cpu, cpu.REG[B])
 				return 4
 			},
 		},
 		{
 			"0xA9; XOR A, C",
-			func(gbc *GBC) uint64 {
-				xor(gbc, gbc.REG[C])
+			func(cpu *CPU) uint64 {
+				xor(cpu, cpu.REG[C])
 				return 4
 			},
 		},
 		{
 			"0xAA; XOR A, D",
-			func(gbc *GBC) uint64 {
-				xor(gbc, gbc.REG[D])
+			func(cpu *CPU) uint64 {
+				xor(cpu, cpu.REG[D])
 				return 4
 			},
 		},
 		{
 			"0xAB; XOR A, E",
-			func(gbc *GBC) uint64 {
-				xor(gbc, gbc.REG[E])
+			func(cpu *CPU) uint64 {
+				xor(cpu, cpu.REG[E])
 				return 4
 			},
 		},
 		{
 			"0xAC; XOR A, H",
-			func(gbc *GBC) uint64 {
-				xor(gbc, gbc.REG[H])
+			func(cpu *CPU) uint64 {
+				xor(cpu, cpu.REG[H])
 				return 4
 			},
 		},
 		{
 			"0xAD; XOR A, L",
-			func(gbc *GBC) uint64 {
-				xor(gbc, gbc.REG[L])
+			func(cpu *CPU) uint64 {
+				xor(cpu, cpu.REG[L])
 				return 4
 			},
 		},
 		{
 			"0xAE; XOR A, (HL)",
-			func(gbc *GBC) uint64 {
-				xor(gbc, gbc.Read(gbc.Reg16(HL)))
+			func(cpu *CPU) uint64 {
+				xor(cpu, cpu.Read(cpu.Reg16(HL)))
 				return 8
 			},
 		},
 		{
 			"0xAF; XOR A, A",
-			func(gbc *GBC) uint64 {
-				xor(gbc, gbc.REG[A])
+			func(cpu *CPU) uint64 {
+				xor(cpu, cpu.REG[A])
 				return 4
 			},
 		},
 		{
 			"0xB0; OR A, B",
-			func(gbc *GBC) uint64 {
-				or(gbc, gbc.REG[B])
+			func(cpu *CPU) uint64 {
+				or(cpu, cpu.REG[B])
 				return 4
 			},
 		},
 		{
 			"0xB1; OR A, C",
-			func(gbc *GBC) uint64 {
-				or(gbc, gbc.REG[C])
+			func(cpu *CPU) uint64 {
+				or(cpu, cpu.REG[C])
 				return 4
 			},
 		},
 		{
 			"0xB2; OR A, D",
-			func(gbc *GBC) uint64 {
-				or(gbc, gbc.REG[D])
+			func(cpu *CPU) uint64 {
+				or(cpu, cpu.REG[D])
 				return 4
 			},
 		},
 		{
 			"0xB3; OR A, E",
-			func(gbc *GBC) uint64 {
-				or(gbc, gbc.REG[E])
+			func(cpu *CPU) uint64 {
+				or(cpu, cpu.REG[E])
 				return 4
 			},
 		},
 		{
 			"0xB4; OR A, H",
-			func(gbc *GBC) uint64 {
-				or(gbc, gbc.REG[H])
+			func(cpu *CPU) uint64 {
+				or(cpu, cpu.REG[H])
 				return 4
 			},
 		},
 		{
 			"0xB5; OR A, L",
-			func(gbc *GBC) uint64 {
-				or(gbc, gbc.REG[L])
+			func(cpu *CPU) uint64 {
+				or(cpu, cpu.REG[L])
 				return 4
 			},
 		},
 		{
 			"0xB6; OR A, (HL)",
-			func(gbc *GBC) uint64 {
-				or(gbc, gbc.Read(gbc.Reg16(HL)))
+			func(cpu *CPU) uint64 {
+				or(cpu, cpu.Read(cpu.Reg16(HL)))
 				return 8
 			},
 		},
 		{
 			"0xB7; OR A, A",
-			func(gbc *GBC) uint64 {
-				or(gbc, gbc.REG[A])
+			func(cpu *CPU) uint64 {
+				or(cpu, cpu.REG[A])
 				return 4
 			},
 		},
 		{
 			"0xB8; CP A, B",
-			func(gbc *GBC) uint64 {
-				cp(gbc, gbc.REG[B])
+			func(cpu *CPU) uint64 {
+				cp(cpu, cpu.REG[B])
 				return 4
 			},
 		},
 		{
 			"0xB9; CP A, C",
-			func(gbc *GBC) uint64 {
-				cp(gbc, gbc.REG[C])
+			func(cpu *CPU) uint64 {
+				cp(cpu, cpu.REG[C])
 				return 4
 			},
 		},
 		{
 			"0xBA; CP A, D",
-			func(gbc *GBC) uint64 {
-				cp(gbc, gbc.REG[D])
+			func(cpu *CPU) uint64 {
+				cp(cpu, cpu.REG[D])
 				return 4
 			},
 		},
 		{
 			"0xBB; CP A, E",
-			func(gbc *GBC) uint64 {
-				cp(gbc, gbc.REG[E])
+			func(cpu *CPU) uint64 {
+				cp(cpu, cpu.REG[E])
 				return 4
 			},
 		},
 		{
 			"0xBC; CP A,H",
-			func(gbc *GBC) uint64 {
-				cp(gbc, gbc.REG[H])
+			func(cpu *CPU) uint64 {
+				cp(cpu, cpu.REG[H])
 				return 4
 			},
 		},
 		{
 			"0xBD; CP A, L",
-			func(gbc *GBC) uint64 {
-				cp(gbc, gbc.REG[L])
+			func(cpu *CPU) uint64 {
+				cp(cpu, cpu.REG[L])
 				return 4
 			},
 		},
 		{
 			"0xBE; CP A, (HL)",
-			func(gbc *GBC) uint64 {
-				cp(gbc, gbc.Read(gbc.Reg16(HL)))
+			func(cpu *CPU) uint64 {
+				cp(cpu, cpu.Read(cpu.Reg16(HL)))
 				return 8
 			},
 		},
 		{
 			"0xBF; CP A, A",
-			func(gbc *GBC) uint64 {
-				cp(gbc, gbc.REG[A])
+			func(cpu *CPU) uint64 {
+				cp(cpu, cpu.REG[A])
 				return 4
 			},
 		},
 		{
 			"0xC0; RET NZ",
-			func(gbc *GBC) uint64 {
-				return retncc(gbc, ZERO)
+			func(cpu *CPU) uint64 {
+				return retncc(cpu, ZERO)
 			},
 		},
 		{
 			"0xC1; POP BC",
-			func(gbc *GBC) uint64 {
-				popR16(gbc, C, B)
+			func(cpu *CPU) uint64 {
+				popR16(cpu, C, B)
 				return 12
 			},
 		},
 		{
 			"0xC2; JP NZ, u16",
-			func(gbc *GBC) uint64 {
-				return jpncc(gbc, ZERO)
+			func(cpu *CPU) uint64 {
+				return jpncc(cpu, ZERO)
 			},
 		},
 		{
 			"0xC3; JP u16",
-			func(gbc *GBC) uint64 {
-				return jp(gbc)
+			func(cpu *CPU) uint64 {
+				return jp(cpu)
 			},
 		},
 		{
 			"0xC4; CALL NZ, u16",
-			func(gbc *GBC) uint64 {
-				return callncc(gbc, ZERO)
+			func(cpu *CPU) uint64 {
+				return callncc(cpu, ZERO)
 			},
 		},
 		{
 			"0xC5; PUSH BC",
-			func(gbc *GBC) uint64 {
-				pushR16(gbc, B, C)
+			func(cpu *CPU) uint64 {
+				pushR16(cpu, B, C)
 				return 16
 			},
 		},
 		{
 			"0xC6; ADD A, u8",
-			func(gbc *GBC) uint64 {
-				add(gbc, byte(gbc.Read(gbc.currPC+1)))
-				gbc.PC++
+			func(cpu *CPU) uint64 {
+				add(cpu, cpu.operand())
+				cpu.PC++
 				return 8
 			},
 		},
 		{
 			"0xC7; RST 00h",
-			func(gbc *GBC) uint64 {
-				rst(gbc, 0x0000)
+			func(cpu *CPU) uint64 {
+				rst(cpu, 0x0000)
 				return 16
 			},
 		},
 		{
 			"0xC8; RET Z",
-			func(gbc *GBC) uint64 {
-				return retcc(gbc, ZERO)
+			func(cpu *CPU) uint64 {
+				return retcc(cpu, ZERO)
 			},
 		},
 		{
 			"0xC9; RET",
-			func(gbc *GBC) uint64 {
-				return ret(gbc)
+			func(cpu *CPU) uint64 {
+				return ret(cpu)
 			},
 		},
 		{
 			"0xCA; JP Z, u16",
-			func(gbc *GBC) uint64 {
-				return jpcc(gbc, ZERO)
+			func(cpu *CPU) uint64 {
+				return jpcc(cpu, ZERO)
 			},
 		},
 		{
 			"0xCB; PREFIX CB",
-			func(gbc *GBC) uint64 {
-				cbop := gbc.Read(gbc.currPC + 1)
-				gbc.PC++
+			func(cpu *CPU) uint64 {
+				cbop := cpu.operand()
+				cpu.PC++
 				inst := cb_instructions[cbop]
-				log.Println(inst.label)
-				return 4 + inst.f(gbc)
+				return inst.f(cpu)
 			},
 		},
 		{
 			"0xCC; CALL Z, u16",
-			func(gbc *GBC) uint64 {
-				return callcc(gbc, ZERO)
+			func(cpu *CPU) uint64 {
+				return callcc(cpu, ZERO)
 			},
 		},
 		{
 			"0xCD; CALL, u16",
-			func(gbc *GBC) uint64 {
-				call(gbc)
+			func(cpu *CPU) uint64 {
+				call(cpu)
 				return 24
 			},
 		},
 		{
 			"0xCE; ADC a, u8",
-			func(gbc *GBC) uint64 {
-				adc(gbc, gbc.Read(gbc.currPC+1))
-				gbc.PC++
+			func(cpu *CPU) uint64 {
+				adc(cpu, cpu.operand())
+				cpu.PC++
 				return 8
 			},
 		},
 		{
 			"0xCF; RST 08h",
-			func(gbc *GBC) uint64 {
-				rst(gbc, 0x0008)
+			func(cpu *CPU) uint64 {
+				rst(cpu, 0x0008)
 				return 16
 			},
 		},
 		{
 			"0xD0; RET NC",
-			func(gbc *GBC) uint64 {
-				return retncc(gbc, CARRY)
+			func(cpu *CPU) uint64 {
+				return retncc(cpu, CARRY)
 			},
 		},
 		{
 			"0xD1; POP DE",
-			func(gbc *GBC) uint64 {
-				popR16(gbc, E, D)
+			func(cpu *CPU) uint64 {
+				popR16(cpu, E, D)
 				return 12
 			},
 		},
 		{
 			"0xD2; JP NC, u16",
-			func(gbc *GBC) uint64 {
-				return jpncc(gbc, CARRY)
+			func(cpu *CPU) uint64 {
+				return jpncc(cpu, CARRY)
 			},
 		},
 		{
 			"0xD3; INVALID",
-			func(gbc *GBC) uint64 {
+			func(cpu *CPU) uint64 {
 				return 0
 			},
 		},
 		{
 			"0xD4; CALL NC, u16",
-			func(gbc *GBC) uint64 {
-				return callncc(gbc, CARRY)
+			func(cpu *CPU) uint64 {
+				return callncc(cpu, CARRY)
 			},
 		},
 		{
 			"0xD5; PUSH DE",
-			func(gbc *GBC) uint64 {
-				pushR16(gbc, D, E)
+			func(cpu *CPU) uint64 {
+				pushR16(cpu, D, E)
 				return 16
 			},
 		},
 		{
 			"0xD6; SUB A, u8",
-			func(gbc *GBC) uint64 {
-				u8 := gbc.Read(gbc.currPC + 1)
-				gbc.PC++
-				sub(gbc, u8)
+			func(cpu *CPU) uint64 {
+				u8 := cpu.operand()
+				cpu.PC++
+				sub(cpu, u8)
 				return 8
 			},
 		},
 		{
 			"0xD7; RST 10h",
-			func(gbc *GBC) uint64 {
-				rst(gbc, 0x0010)
+			func(cpu *CPU) uint64 {
+				rst(cpu, 0x0010)
 				return 16
 			},
 		},
 		{
 			"0xD8; RET C",
-			func(gbc *GBC) uint64 {
-				return retcc(gbc, CARRY)
+			func(cpu *CPU) uint64 {
+				return retcc(cpu, CARRY)
 			},
 		},
 		{
 			"0xD9; RETI",
-			func(gbc *GBC) uint64 {
-				return reti(gbc)
+			func(cpu *CPU) uint64 {
+				return reti(cpu)
 			},
 		},
 		{
 			"0xDA; JP C, u16",
-			func(gbc *GBC) uint64 {
-				return jpcc(gbc, CARRY)
+			func(cpu *CPU) uint64 {
+				return jpcc(cpu, CARRY)
 			},
 		},
 		{
 			"0xDB; INVALID",
-			func(gbc *GBC) uint64 {
+			func(cpu *CPU) uint64 {
 				return 0
 			},
 		},
 		{
 			"0xDC; CALL C, u16",
-			func(gbc *GBC) uint64 {
-				return callcc(gbc, CARRY)
+			func(cpu *CPU) uint64 {
+				return callcc(cpu, CARRY)
 			},
 		},
 		{
 			"0xDD; INVALID",
-			func(gbc *GBC) uint64 {
+			func(cpu *CPU) uint64 {
 				return 0
 			},
 		},
 		{
 			"0xDE; SBC A, u8",
-			func(gbc *GBC) uint64 {
-				u8 := gbc.Read(gbc.currPC + 1)
-				gbc.PC++
-				sbc(gbc, u8)
+			func(cpu *CPU) uint64 {
+				u8 := cpu.operand()
+				cpu.PC++
+				sbc(cpu, u8)
 				return 8
 			},
 		},
 		{
 			"0xDF; RST 18h",
-			func(gbc *GBC) uint64 {
-				rst(gbc, 0x0018)
+			func(cpu *CPU) uint64 {
+				rst(cpu, 0x0018)
 				return 16
 			},
 		},
 		{
 			"0xE0; LD (FF00+u8), A",
-			func(gbc *GBC) uint64 {
-				l := gbc.Read(gbc.currPC + 1)
-				gbc.PC++
-				ldnnR8(gbc, 0xFF00+uint16(l), A)
+			func(cpu *CPU) uint64 {
+				l := cpu.operand()
+				cpu.PC++
+				ldnnR8(cpu, 0xFF00+uint16(l), A)
 				return 12
 			},
 		},
 		{
 			"0xE1; POP HL",
-			func(gbc *GBC) uint64 {
-				popR16(gbc, L, H)
+			func(cpu *CPU) uint64 {
+				popR16(cpu, L, H)
 				return 12
 			},
 		},
 		{
 			"0xE2; LD (FF00+C), A",
-			func(gbc *GBC) uint64 {
-				ldnnR8(gbc, 0xFF00+uint16(gbc.REG[C]), A)
+			func(cpu *CPU) uint64 {
+				ldnnR8(cpu, 0xFF00+uint16(cpu.REG[C]), A)
 				return 8
 			},
 		},
 		{
 			"0xE3; INVALID",
-			func(gbc *GBC) uint64 {
+			func(cpu *CPU) uint64 {
 				return 0
 			},
 		},
 		{
 			"0xE4; INVALID",
-			func(gbc *GBC) uint64 {
+			func(cpu *CPU) uint64 {
 				return 0
 			},
 		},
 		{
 			"0xE5; PUSH HL",
-			func(gbc *GBC) uint64 {
-				pushR16(gbc, H, L)
+			func(cpu *CPU) uint64 {
+				pushR16(cpu, H, L)
 				return 16
 			},
 		},
 		{
 			"0xE6; AND A, u8",
-			func(gbc *GBC) uint64 {
-				u8 := gbc.Read(gbc.currPC + 1)
-				gbc.PC++
-				and(gbc, u8)
+			func(cpu *CPU) uint64 {
+				u8 := cpu.operand()
+				cpu.PC++
+				and(cpu, u8)
 				return 8
 			},
 		},
 		{
 			"0xE7; RST 20h",
-			func(gbc *GBC) uint64 {
-				rst(gbc, 0x0020)
+			func(cpu *CPU) uint64 {
+				rst(cpu, 0x0020)
 				return 16
 			},
 		},
 		{
 			"0xE8; ADD SP, i8",
-			func(gbc *GBC) uint64 {
-				i8 := int8(gbc.Read(gbc.currPC + 1))
-				gbc.PC++
-				result := int32(gbc.SP) + int32(i8)
-				carry := uint32(gbc.SP) ^ uint32(i8) ^ uint32(result)
-				gbc.SP = uint16(result)
-				gbc.setFlags(false, false, carry&(1<<4) != 0, carry&(1<<8) != 0)
+			func(cpu *CPU) uint64 {
+				i8 := int8(cpu.operand())
+				cpu.PC++
+				result := int32(cpu.SP) + int32(i8)
+				carry := uint32(cpu.SP) ^ uint32(i8) ^ uint32(result)
+				cpu.SP = uint16(result)
+				cpu.setFlags(false, false, carry&(1<<4) != 0, carry&(1<<8) != 0)
 				return 16
 			},
 		},
 		{
 			"0xE9; JP HL",
-			func(gbc *GBC) uint64 {
-				gbc.PC = gbc.Reg16(HL)
+			func(cpu *CPU) uint64 {
+				cpu.PC = cpu.Reg16(HL)
 				return 4
 			},
 		},
 		{
 			"0xEA; LD (u16), A",
-			func(gbc *GBC) uint64 {
-				l, u := uint16(gbc.Read(gbc.currPC+1)), uint16(gbc.Read(gbc.currPC+2))
-				gbc.PC += 2
-				ldnnR8(gbc, (u<<8)|l, A)
+			func(cpu *CPU) uint64 {
+				l, u := cpu.operands()
+				cpu.PC += 2
+				ldnnR8(cpu, (u<<8)|l, A)
 				return 0
 			},
 		},
 		{
 			"0xEB; INVALID",
-			func(gbc *GBC) uint64 {
+			func(cpu *CPU) uint64 {
 				return 0
 			},
 		},
 		{
 			"0xEC; INVALID",
-			func(gbc *GBC) uint64 {
+			func(cpu *CPU) uint64 {
 				return 0
 			},
 		},
 		{
 			"0xED; INVALID",
-			func(gbc *GBC) uint64 {
+			func(cpu *CPU) uint64 {
 				return 0
 			},
 		},
 		{
 			"0xEE; XOR A, u8",
-			func(gbc *GBC) uint64 {
-				u8 := gbc.Read(gbc.currPC + 1)
-				gbc.PC++
-				xor(gbc, u8)
+			func(cpu *CPU) uint64 {
+				u8 := cpu.operand()
+				cpu.PC++
+				xor(cpu, u8)
 				return 8
 			},
 		},
 		{
 			"0xEF; RST 28h",
-			func(gbc *GBC) uint64 {
-				rst(gbc, 0x0028)
+			func(cpu *CPU) uint64 {
+				rst(cpu, 0x0028)
 				return 16
 			},
 		},
 		{
 			"0xF0; LD A, (FF00+u8)",
-			func(gbc *GBC) uint64 {
-				l := gbc.Read(gbc.currPC + 1)
-				gbc.PC++
-				log.Printf("%04X: %02X", 0xFF00+uint16(l), gbc.Read(0xFF00+uint16(l)))
-				ldR8nn(gbc, A, gbc.Read(0xFF00+uint16(l)))
+			func(cpu *CPU) uint64 {
+				l := cpu.operand()
+				cpu.PC++
+				ldR8nn(cpu, A, cpu.Read(0xFF00+uint16(l)))
 				return 12
 			},
 		},
 		{
 			"0xF1; POP AF",
-			func(gbc *GBC) uint64 {
-				popAF(gbc)
+			func(cpu *CPU) uint64 {
+				popAF(cpu)
 				return 12
 			},
 		},
 		{
 			"0xF2; LD A, (FF00+C)",
-			func(gbc *GBC) uint64 {
-				ldR8nn(gbc, A, gbc.Read(0xFF00+uint16(gbc.REG[C])))
+			func(cpu *CPU) uint64 {
+				ldR8nn(cpu, A, cpu.Read(0xFF00+uint16(cpu.REG[C])))
 				return 8
 			},
 		},
 		{
 			"0xF3; DI",
-			func(gbc *GBC) uint64 {
-				di(gbc)
+			func(cpu *CPU) uint64 {
+				di(cpu)
 				return 4
 			},
 		},
 		{
 			"0xF4; INVALID",
-			func(gbc *GBC) uint64 {
+			func(cpu *CPU) uint64 {
 				return 0
 			},
 		},
 		{
 			"0xF5; PUSH AF",
-			func(gbc *GBC) uint64 {
-				pushAF(gbc)
+			func(cpu *CPU) uint64 {
+				pushAF(cpu)
 				return 16
 			},
 		},
 		{
 			"0xF6; OR A, u8",
-			func(gbc *GBC) uint64 {
-				u8 := gbc.Read(gbc.currPC + 1)
-				gbc.PC++
-				or(gbc, u8)
+			func(cpu *CPU) uint64 {
+				u8 := cpu.operand()
+				cpu.PC++
+				or(cpu, u8)
 				return 8
 			},
 		},
 		{
 			"0xF7; RST 30h",
-			func(gbc *GBC) uint64 {
-				rst(gbc, 0x0030)
+			func(cpu *CPU) uint64 {
+				rst(cpu, 0x0030)
 				return 16
 			},
 		},
 		{
 			"0xF8; LD HL, SP+i8",
-			func(gbc *GBC) uint64 {
-				l, r := gbc.SP, int8(gbc.Read(gbc.currPC+1))
-				gbc.PC++
+			func(cpu *CPU) uint64 {
+				l, r := cpu.SP, int8(cpu.operand())
+				cpu.PC++
 				result := int32(l) + int32(r)
 				carry := uint32(l) ^ uint32(r) ^ uint32(result)
-				gbc.SetReg16(HL, uint16(result))
-				gbc.setFlags(false, false, carry&(1<<4) != 0, carry&(1<<8) != 0)
+				cpu.SetReg16(HL, uint16(result))
+				cpu.setFlags(false, false, carry&(1<<4) != 0, carry&(1<<8) != 0)
 				return 12
 			},
 		},
 		{
 			"0xF9; LD SP, HL",
-			func(gbc *GBC) uint64 {
-				gbc.SP = gbc.Reg16(HL)
+			func(cpu *CPU) uint64 {
+				cpu.SP = cpu.Reg16(HL)
 				return 8
 			},
 		},
 		{
-			"0xFA; LD A, u16",
-			func(gbc *GBC) uint64 {
-				l, u := uint16(gbc.Read(gbc.currPC+1)), uint16(gbc.Read(gbc.currPC+2))
-				gbc.PC += 2
-				ldR8nn(gbc, A, gbc.Read((u<<8)|l))
+			"0xFA; LD A, (u16)",
+			func(cpu *CPU) uint64 {
+				l, u := cpu.operands()
+				cpu.PC += 2
+				ldR8nn(cpu, A, cpu.Read((u<<8)|l))
 				return 16
 			},
 		},
 		{
 			"0xFB; EI",
-			func(gbc *GBC) uint64 {
-				ei(gbc)
+			func(cpu *CPU) uint64 {
+				ei(cpu)
 				return 4
 			},
 		},
 		{
 			"0xFC; INVALID",
-			func(gbc *GBC) uint64 {
+			func(cpu *CPU) uint64 {
 				return 0
 			},
 		},
 		{
 			"0xFD; INVALID",
-			func(gbc *GBC) uint64 {
+			func(cpu *CPU) uint64 {
 				return 0
 			},
 		},
 		{
 			"0xFE; CP A, u8",
-			func(gbc *GBC) uint64 {
-				u8 := gbc.Read(gbc.currPC + 1)
-				gbc.PC++
-				cp(gbc, u8)
+			func(cpu *CPU) uint64 {
+				u8 := cpu.operand()
+				cpu.PC++
+				cp(cpu, u8)
 				return 8
 			},
 		},
 		{
 			"0xFF; RST 38h",
-			func(gbc *GBC) uint64 {
-				rst(gbc, 0x0038)
+			func(cpu *CPU) uint64 {
+				rst(cpu, 0x0038)
 				return 16
 			},
 		},
@@ -1816,1821 +1821,1821 @@ var (
 
 	cb_instructions = [256]Instruction{{
 		"CBx00; RLC B",
-		func(gbc *GBC) uint64 {
-			rlc(gbc, B)
+		func(cpu *CPU) uint64 {
+			rlc(cpu, B)
 			return 8
 		},
 	},
 		{
 			"CBx01; RLC C",
-			func(gbc *GBC) uint64 {
-				rlc(gbc, C)
+			func(cpu *CPU) uint64 {
+				rlc(cpu, C)
 				return 8
 			},
 		},
 		{
 			"CBx02; RLC D",
-			func(gbc *GBC) uint64 {
-				rlc(gbc, D)
+			func(cpu *CPU) uint64 {
+				rlc(cpu, D)
 				return 8
 			},
 		},
 		{
 			"CBx03; RLC E",
-			func(gbc *GBC) uint64 {
-				rlc(gbc, E)
+			func(cpu *CPU) uint64 {
+				rlc(cpu, E)
 				return 8
 			},
 		},
 		{
 			"CBx04; RLC H",
-			func(gbc *GBC) uint64 {
-				rlc(gbc, H)
+			func(cpu *CPU) uint64 {
+				rlc(cpu, H)
 				return 8
 			},
 		},
 		{
 			"CBx05; RLC L",
-			func(gbc *GBC) uint64 {
-				rlc(gbc, L)
+			func(cpu *CPU) uint64 {
+				rlc(cpu, L)
 				return 8
 			},
 		},
 		{
 			"CBx06; RLC (HL)",
-			func(gbc *GBC) uint64 {
-				value := gbc.Read(gbc.Reg16(HL))
-				_, bit7 := _rl(gbc, value)
+			func(cpu *CPU) uint64 {
+				value := cpu.Read(cpu.Reg16(HL))
+				_, bit7 := _rl(cpu, value)
 				new := (value << 1) | (bit7)
-				gbc.Write(gbc.Reg16(HL), new)
-				gbc.setFlags(new == 0, false, false, bit7 != 0)
+				cpu.Write(cpu.Reg16(HL), new)
+				cpu.setFlags(new == 0, false, false, bit7 != 0)
 				return 16
 			},
 		},
 		{
 			"CBx07; RLC A",
-			func(gbc *GBC) uint64 {
-				rlc(gbc, A)
+			func(cpu *CPU) uint64 {
+				rlc(cpu, A)
 				return 8
 			},
 		},
 		{
 			"CBx08; RRC B",
-			func(gbc *GBC) uint64 {
-				rrc(gbc, B)
+			func(cpu *CPU) uint64 {
+				rrc(cpu, B)
 				return 8
 			},
 		},
 		{
 			"CBx09; RRC C",
-			func(gbc *GBC) uint64 {
-				rrc(gbc, C)
+			func(cpu *CPU) uint64 {
+				rrc(cpu, C)
 				return 8
 			},
 		},
 		{
 			"CBx0A; RRC D",
-			func(gbc *GBC) uint64 {
-				rrc(gbc, D)
+			func(cpu *CPU) uint64 {
+				rrc(cpu, D)
 				return 8
 			},
 		},
 		{
 			"CBx0B; RRC E",
-			func(gbc *GBC) uint64 {
-				rrc(gbc, E)
+			func(cpu *CPU) uint64 {
+				rrc(cpu, E)
 				return 8
 			},
 		},
 		{
 			"CBx0C; RRC H",
-			func(gbc *GBC) uint64 {
-				rrc(gbc, H)
+			func(cpu *CPU) uint64 {
+				rrc(cpu, H)
 				return 8
 			},
 		},
 		{
 			"CBx0D; RRC L",
-			func(gbc *GBC) uint64 {
-				rrc(gbc, L)
+			func(cpu *CPU) uint64 {
+				rrc(cpu, L)
 				return 8
 			},
 		},
 		{
 			"CBx0E; RRC (HL)",
-			func(gbc *GBC) uint64 {
-				value := gbc.Read(gbc.Reg16(HL))
-				_, bit0 := _rr(gbc, value)
+			func(cpu *CPU) uint64 {
+				value := cpu.Read(cpu.Reg16(HL))
+				_, bit0 := _rr(cpu, value)
 				new := (value >> 1) | (bit0 << 7)
-				gbc.Write(gbc.Reg16(HL), new)
-				gbc.setFlags(new == 0, false, false, bit0 != 0)
+				cpu.Write(cpu.Reg16(HL), new)
+				cpu.setFlags(new == 0, false, false, bit0 != 0)
 				return 16
 			},
 		},
 		{
 			"CBx0F; RRC A",
-			func(gbc *GBC) uint64 {
-				rrc(gbc, A)
+			func(cpu *CPU) uint64 {
+				rrc(cpu, A)
 				return 8
 			},
 		},
 		{
 			"CBx10; RL B",
-			func(gbc *GBC) uint64 {
-				rl(gbc, B)
+			func(cpu *CPU) uint64 {
+				rl(cpu, B)
 				return 8
 			},
 		},
 		{
 			"CBx11; RL C",
-			func(gbc *GBC) uint64 {
-				rl(gbc, C)
+			func(cpu *CPU) uint64 {
+				rl(cpu, C)
 				return 8
 			},
 		},
 		{
 			"CBx12; RL D",
-			func(gbc *GBC) uint64 {
-				rl(gbc, D)
+			func(cpu *CPU) uint64 {
+				rl(cpu, D)
 				return 8
 			},
 		},
 		{
 			"CBx13; RL E",
-			func(gbc *GBC) uint64 {
-				rl(gbc, E)
+			func(cpu *CPU) uint64 {
+				rl(cpu, E)
 				return 8
 			},
 		},
 		{
 			"CBx14; RL H",
-			func(gbc *GBC) uint64 {
-				rl(gbc, H)
+			func(cpu *CPU) uint64 {
+				rl(cpu, H)
 				return 8
 			},
 		},
 		{
 			"CBx15; RL L",
-			func(gbc *GBC) uint64 {
-				rl(gbc, L)
+			func(cpu *CPU) uint64 {
+				rl(cpu, L)
 				return 8
 			},
 		},
 		{
 			"CBx16; RL (HL)",
-			func(gbc *GBC) uint64 {
-				value := gbc.Read(gbc.Reg16(HL))
-				carry, bit7 := _rl(gbc, value)
+			func(cpu *CPU) uint64 {
+				value := cpu.Read(cpu.Reg16(HL))
+				carry, bit7 := _rl(cpu, value)
 				new := (value << 1) | (carry)
-				gbc.Write(gbc.Reg16(HL), new)
-				gbc.setFlags(new == 0, false, false, bit7 != 0)
+				cpu.Write(cpu.Reg16(HL), new)
+				cpu.setFlags(new == 0, false, false, bit7 != 0)
 				return 16
 			},
 		},
 		{
 			"CBx17; RL A",
-			func(gbc *GBC) uint64 {
-				rl(gbc, A)
+			func(cpu *CPU) uint64 {
+				rl(cpu, A)
 				return 8
 			},
 		},
 		{
 			"CBx18; RR B",
-			func(gbc *GBC) uint64 {
-				rr(gbc, B)
+			func(cpu *CPU) uint64 {
+				rr(cpu, B)
 				return 8
 			},
 		},
 		{
 			"CBx19; RR C",
-			func(gbc *GBC) uint64 {
-				rr(gbc, C)
+			func(cpu *CPU) uint64 {
+				rr(cpu, C)
 				return 8
 			},
 		},
 		{
 			"CBx1A; RR D",
-			func(gbc *GBC) uint64 {
-				rr(gbc, D)
+			func(cpu *CPU) uint64 {
+				rr(cpu, D)
 				return 8
 			},
 		},
 		{
 			"CBx1B; RR E",
-			func(gbc *GBC) uint64 {
-				rr(gbc, E)
+			func(cpu *CPU) uint64 {
+				rr(cpu, E)
 				return 8
 			},
 		},
 		{
 			"CBx1C; RR H",
-			func(gbc *GBC) uint64 {
-				rr(gbc, H)
+			func(cpu *CPU) uint64 {
+				rr(cpu, H)
 				return 8
 			},
 		},
 		{
 			"CBx1D; RR L",
-			func(gbc *GBC) uint64 {
-				rr(gbc, L)
+			func(cpu *CPU) uint64 {
+				rr(cpu, L)
 				return 8
 			},
 		},
 		{
 			"CBx1E; RR (HL)",
-			func(gbc *GBC) uint64 {
-				value := gbc.Read(gbc.Reg16(HL))
-				carry, bit0 := _rr(gbc, value)
-				new := (value << 1) | (carry << 7)
-				gbc.Write(gbc.Reg16(HL), new)
-				gbc.setFlags(new == 0, false, false, bit0 != 0)
+			func(cpu *CPU) uint64 {
+				value := cpu.Read(cpu.Reg16(HL))
+				carry, bit0 := _rr(cpu, value)
+				new := (value >> 1) | (carry << 7)
+				cpu.Write(cpu.Reg16(HL), new)
+				cpu.setFlags(new == 0, false, false, bit0 != 0)
 				return 16
 			},
 		},
 		{
 			"CBx1F; RR A",
-			func(gbc *GBC) uint64 {
-				rr(gbc, A)
+			func(cpu *CPU) uint64 {
+				rr(cpu, A)
 				return 8
 			},
 		},
 		{
 			"CBx20; SLA B",
-			func(gbc *GBC) uint64 {
-				sla(gbc, B)
+			func(cpu *CPU) uint64 {
+				sla(cpu, B)
 				return 8
 			},
 		},
 		{
 			"CBx21; SLA C",
-			func(gbc *GBC) uint64 {
-				sla(gbc, C)
+			func(cpu *CPU) uint64 {
+				sla(cpu, C)
 				return 8
 			},
 		},
 		{
 			"CBx22; SLA D",
-			func(gbc *GBC) uint64 {
-				sla(gbc, D)
+			func(cpu *CPU) uint64 {
+				sla(cpu, D)
 				return 8
 			},
 		},
 		{
 			"CBx23; SLA E",
-			func(gbc *GBC) uint64 {
-				sla(gbc, E)
+			func(cpu *CPU) uint64 {
+				sla(cpu, E)
 				return 8
 			},
 		},
 		{
 			"CBx24; SLA H",
-			func(gbc *GBC) uint64 {
-				sla(gbc, H)
+			func(cpu *CPU) uint64 {
+				sla(cpu, H)
 				return 8
 			},
 		},
 		{
 			"CBx25; SLA L",
-			func(gbc *GBC) uint64 {
-				sla(gbc, L)
+			func(cpu *CPU) uint64 {
+				sla(cpu, L)
 				return 8
 			},
 		},
 		{
 			"CBx26; SLA (HL)",
-			func(gbc *GBC) uint64 {
-				value := gbc.Read(gbc.Reg16(HL))
-				_, bit0 := _rl(gbc, value)
+			func(cpu *CPU) uint64 {
+				value := cpu.Read(cpu.Reg16(HL))
+				_, bit7 := _rl(cpu, value)
 				new := (value << 1)
-				gbc.Write(gbc.Reg16(HL), new)
-				gbc.setFlags(new == 0, false, false, bit0 != 0)
+				cpu.Write(cpu.Reg16(HL), new)
+				cpu.setFlags(new == 0, false, false, bit7 != 0)
 				return 16
 			},
 		},
 		{
 			"CBx27; SLA A",
-			func(gbc *GBC) uint64 {
-				sla(gbc, A)
+			func(cpu *CPU) uint64 {
+				sla(cpu, A)
 				return 8
 			},
 		},
 		{
 			"CBx28; SRA B",
-			func(gbc *GBC) uint64 {
-				sra(gbc, B)
+			func(cpu *CPU) uint64 {
+				sra(cpu, B)
 				return 8
 			},
 		},
 		{
 			"CBx29; SRA C",
-			func(gbc *GBC) uint64 {
-				sra(gbc, C)
+			func(cpu *CPU) uint64 {
+				sra(cpu, C)
 				return 8
 			},
 		},
 		{
 			"CBx2A; SRA D",
-			func(gbc *GBC) uint64 {
-				sra(gbc, D)
+			func(cpu *CPU) uint64 {
+				sra(cpu, D)
 				return 8
 			},
 		},
 		{
 			"CBx2B; SRA E",
-			func(gbc *GBC) uint64 {
-				sra(gbc, E)
+			func(cpu *CPU) uint64 {
+				sra(cpu, E)
 				return 8
 			},
 		},
 		{
 			"CBx2C; SRA H",
-			func(gbc *GBC) uint64 {
-				sra(gbc, H)
+			func(cpu *CPU) uint64 {
+				sra(cpu, H)
 				return 8
 			},
 		},
 		{
 			"CBx2D; SRA L",
-			func(gbc *GBC) uint64 {
-				sra(gbc, L)
+			func(cpu *CPU) uint64 {
+				sra(cpu, L)
 				return 8
 			},
 		},
 		{
 			"CBx2E; SRA (HL)",
-			func(gbc *GBC) uint64 {
-				value := gbc.Read(gbc.Reg16(HL))
-				_, bit7 := _rr(gbc, value)
-				new := (value >> 1)
-				gbc.Write(gbc.Reg16(HL), new)
-				gbc.setFlags(new == 0, false, false, bit7 != 0)
+			func(cpu *CPU) uint64 {
+				value := cpu.Read(cpu.Reg16(HL))
+				_, bit0 := _rr(cpu, value)
+				new := (value >> 1) | (value & 0x80)
+				cpu.Write(cpu.Reg16(HL), new)
+				cpu.setFlags(new == 0, false, false, bit0 != 0)
 				return 16
 			},
 		},
 		{
 			"CBx2F; SRA A",
-			func(gbc *GBC) uint64 {
-				sra(gbc, A)
+			func(cpu *CPU) uint64 {
+				sra(cpu, A)
 				return 8
 			},
 		},
 		{
 			"CBx30; SWAP B",
-			func(gbc *GBC) uint64 {
-				swap(gbc, B)
+			func(cpu *CPU) uint64 {
+				swap(cpu, B)
 				return 8
 			},
 		},
 		{
 			"CBx31; SWAP C",
-			func(gbc *GBC) uint64 {
-				swap(gbc, C)
+			func(cpu *CPU) uint64 {
+				swap(cpu, C)
 				return 8
 			},
 		},
 		{
 			"CBx32; SWAP D",
-			func(gbc *GBC) uint64 {
-				swap(gbc, D)
+			func(cpu *CPU) uint64 {
+				swap(cpu, D)
 				return 8
 			},
 		},
 		{
 			"CBx33; SWAP E",
-			func(gbc *GBC) uint64 {
-				swap(gbc, E)
+			func(cpu *CPU) uint64 {
+				swap(cpu, E)
 				return 8
 			},
 		},
 		{
 			"CBx34; SWAP H",
-			func(gbc *GBC) uint64 {
-				swap(gbc, H)
+			func(cpu *CPU) uint64 {
+				swap(cpu, H)
 				return 8
 			},
 		},
 		{
 			"CBx35; SWAP L",
-			func(gbc *GBC) uint64 {
-				swap(gbc, L)
+			func(cpu *CPU) uint64 {
+				swap(cpu, L)
 				return 8
 			},
 		},
 		{
 			"CBx36; SWAP (HL)",
-			func(gbc *GBC) uint64 {
-				_swap(gbc, HL)
+			func(cpu *CPU) uint64 {
+				_swap(cpu, HL)
 				return 16
 			},
 		},
 		{
 			"CBx37; SWAP A",
-			func(gbc *GBC) uint64 {
-				swap(gbc, A)
+			func(cpu *CPU) uint64 {
+				swap(cpu, A)
 				return 8
 			},
 		},
 		{
 			"CBx38; SRL B",
-			func(gbc *GBC) uint64 {
-				srl(gbc, B)
+			func(cpu *CPU) uint64 {
+				srl(cpu, B)
 				return 8
 			},
 		},
 		{
 			"CBx39; SRL C",
-			func(gbc *GBC) uint64 {
-				srl(gbc, C)
+			func(cpu *CPU) uint64 {
+				srl(cpu, C)
 				return 8
 			},
 		},
 		{
 			"CBx3A; SRL D",
-			func(gbc *GBC) uint64 {
-				srl(gbc, D)
+			func(cpu *CPU) uint64 {
+				srl(cpu, D)
 				return 8
 			},
 		},
 		{
 			"CBx3B; SRL E",
-			func(gbc *GBC) uint64 {
-				srl(gbc, E)
+			func(cpu *CPU) uint64 {
+				srl(cpu, E)
 				return 8
 			},
 		},
 		{
 			"CBx3C; SRL H",
-			func(gbc *GBC) uint64 {
-				srl(gbc, H)
+			func(cpu *CPU) uint64 {
+				srl(cpu, H)
 				return 8
 			},
 		},
 		{
 			"CBx3D; SRL L",
-			func(gbc *GBC) uint64 {
-				srl(gbc, L)
+			func(cpu *CPU) uint64 {
+				srl(cpu, L)
 				return 8
 			},
 		},
 		{
 			"CBx3E; SRL (HL)",
-			func(gbc *GBC) uint64 {
-				value := gbc.Read(gbc.Reg16(HL))
-				_, bit7 := _rr(gbc, value)
+			func(cpu *CPU) uint64 {
+				value := cpu.Read(cpu.Reg16(HL))
+				_, bit0 := _rr(cpu, value)
 				new := (value >> 1) &^ 0x80
-				gbc.Write(gbc.Reg16(HL), new)
-				gbc.setFlags(new == 0, false, false, bit7 != 0)
+				cpu.Write(cpu.Reg16(HL), new)
+				cpu.setFlags(new == 0, false, false, bit0 != 0)
 				return 16
 			},
 		},
 		{
 			"CBx3F; SRL A",
-			func(gbc *GBC) uint64 {
-				srl(gbc, A)
+			func(cpu *CPU) uint64 {
+				srl(cpu, A)
 				return 8
 			},
 		},
 		{
 			"CBx40; BIT 0, B",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 0, B)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 0, B)
 				return 8
 			},
 		},
 		{
 			"CBx41; BIT 0, C",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 0, C)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 0, C)
 				return 8
 			},
 		},
 		{
 			"CBx42; BIT 0, D",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 0, D)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 0, D)
 				return 8
 			},
 		},
 		{
 			"CBx43; BIT 0, E",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 0, E)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 0, E)
 				return 8
 			},
 		},
 		{
 			"CBx44; BIT 0, H",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 0, H)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 0, H)
 				return 8
 			},
 		},
 		{
 			"CBx45; BIT 0, L",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 0, L)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 0, L)
 				return 8
 			},
 		},
 		{
 			"CBx46; BIT 0, (HL)",
-			func(gbc *GBC) uint64 {
-				_bit(gbc, 0, gbc.Read(gbc.Reg16(HL)))
-				return 16
+			func(cpu *CPU) uint64 {
+				_bit(cpu, 0, cpu.Read(cpu.Reg16(HL)))
+				return 12
 			},
 		},
 		{
 			"CBx47; BIT 0, A",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 0, A)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 0, A)
 				return 8
 			},
 		},
 		{
 			"CBx48; BIT 1, B",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 1, B)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 1, B)
 				return 8
 			},
 		},
 		{
 			"CBx49; BIT 1, C",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 1, C)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 1, C)
 				return 8
 			},
 		},
 		{
 			"CBx4A; BIT 1, D",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 1, D)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 1, D)
 				return 8
 			},
 		},
 		{
 			"CBx4B; BIT 1, E",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 1, E)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 1, E)
 				return 8
 			},
 		},
 		{
 			"CBx4C; BIT 1, H",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 1, H)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 1, H)
 				return 8
 			},
 		},
 		{
 			"CBx4D; BIT 1, L",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 1, L)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 1, L)
 				return 8
 			},
 		},
 		{
 			"CBx4E; BIT 1, (HL)",
-			func(gbc *GBC) uint64 {
-				_bit(gbc, 1, gbc.Read(gbc.Reg16(HL)))
-				return 16
+			func(cpu *CPU) uint64 {
+				_bit(cpu, 1, cpu.Read(cpu.Reg16(HL)))
+				return 12
 			},
 		},
 		{
 			"CBx4F; BIT 1, A",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 1, A)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 1, A)
 				return 8
 			},
 		},
 		{
 			"CBx50; BIT 2, B",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 2, B)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 2, B)
 				return 8
 			},
 		},
 		{
 			"CBx51; BIT 2, C",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 2, C)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 2, C)
 				return 8
 			},
 		},
 		{
 			"CBx52; BIT 2, D",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 2, D)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 2, D)
 				return 8
 			},
 		},
 		{
 			"CBx53; BIT 2, E",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 2, E)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 2, E)
 				return 8
 			},
 		},
 		{
 			"CBx54; BIT 2, H",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 2, H)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 2, H)
 				return 8
 			},
 		},
 		{
 			"CBx55; BIT 2, L",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 2, L)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 2, L)
 				return 8
 			},
 		},
 		{
 			"CBx56; BIT 2, (HL)",
-			func(gbc *GBC) uint64 {
-				_bit(gbc, 2, gbc.Read(gbc.Reg16(HL)))
-				return 16
+			func(cpu *CPU) uint64 {
+				_bit(cpu, 2, cpu.Read(cpu.Reg16(HL)))
+				return 12
 			},
 		},
 		{
 			"CBx57; BIT 2, A",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 2, A)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 2, A)
 				return 8
 			},
 		},
 		{
 			"CBx58; BIT 3, B",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 3, B)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 3, B)
 				return 8
 			},
 		},
 		{
 			"CBx59; BIT 3, C",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 3, C)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 3, C)
 				return 8
 			},
 		},
 		{
 			"CBx5A; BIT 3, D",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 3, D)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 3, D)
 				return 8
 			},
 		},
 		{
 			"CBx5B; BIT 3, E",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 3, E)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 3, E)
 				return 8
 			},
 		},
 		{
 			"CBx5C; BIT 3, H",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 3, H)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 3, H)
 				return 8
 			},
 		},
 		{
 			"CBx5D; BIT 3, L",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 3, L)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 3, L)
 				return 8
 			},
 		},
 		{
 			"CBx5E; BIT 3, (HL)",
-			func(gbc *GBC) uint64 {
-				_bit(gbc, 3, gbc.Read(gbc.Reg16(HL)))
-				return 16
+			func(cpu *CPU) uint64 {
+				_bit(cpu, 3, cpu.Read(cpu.Reg16(HL)))
+				return 12
 			},
 		},
 		{
 			"CBx5F; BIT 3, A",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 3, A)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 3, A)
 				return 8
 			},
 		},
 		{
 			"CBx60; BIT 4, B",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 4, B)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 4, B)
 				return 8
 			},
 		},
 		{
 			"CBx61; BIT 4, C",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 4, C)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 4, C)
 				return 8
 			},
 		},
 		{
 			"CBx62; BIT 4, D",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 4, D)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 4, D)
 				return 8
 			},
 		},
 		{
 			"CBx63; BIT 4, E",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 4, E)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 4, E)
 				return 8
 			},
 		},
 		{
 			"CBx64; BIT 4, H",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 4, H)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 4, H)
 				return 8
 			},
 		},
 		{
 			"CBx65; BIT 4, L",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 4, L)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 4, L)
 				return 8
 			},
 		},
 		{
 			"CBx66; BIT 4, (HL)",
-			func(gbc *GBC) uint64 {
-				_bit(gbc, 4, gbc.Read(gbc.Reg16(HL)))
-				return 16
+			func(cpu *CPU) uint64 {
+				_bit(cpu, 4, cpu.Read(cpu.Reg16(HL)))
+				return 12
 			},
 		},
 		{
 			"CBx67; BIT 4, A",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 4, A)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 4, A)
 				return 8
 			},
 		},
 		{
 			"CBx68; BIT 5, B",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 5, B)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 5, B)
 				return 8
 			},
 		},
 		{
 			"CBx69; BIT 5, C",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 5, C)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 5, C)
 				return 8
 			},
 		},
 		{
 			"CBx6A; BIT 5, D",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 5, D)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 5, D)
 				return 8
 			},
 		},
 		{
 			"CBx6B; BIT 5, E",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 5, E)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 5, E)
 				return 8
 			},
 		},
 		{
 			"CBx6C; BIT 5, H",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 5, H)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 5, H)
 				return 8
 			},
 		},
 		{
 			"CBx6D; BIT 5, L",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 5, L)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 5, L)
 				return 8
 			},
 		},
 		{
 			"CBx6E; BIT 5, (HL)",
-			func(gbc *GBC) uint64 {
-				_bit(gbc, 5, gbc.Read(gbc.Reg16(HL)))
-				return 16
+			func(cpu *CPU) uint64 {
+				_bit(cpu, 5, cpu.Read(cpu.Reg16(HL)))
+				return 12
 			},
 		},
 		{
 			"CBx6F; BIT 5, A",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 5, A)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 5, A)
 				return 8
 			},
 		},
 		{
 			"CBx70; BIT 6, B",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 6, B)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 6, B)
 				return 8
 			},
 		},
 		{
 			"CBx71; BIT 6, C",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 6, C)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 6, C)
 				return 8
 			},
 		},
 		{
 			"CBx72; BIT 6, D",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 6, D)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 6, D)
 				return 8
 			},
 		},
 		{
 			"CBx73; BIT 6, E",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 6, E)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 6, E)
 				return 8
 			},
 		},
 		{
 			"CBx74; BIT 6, H",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 6, H)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 6, H)
 				return 8
 			},
 		},
 		{
 			"CBx75; BIT 6, L",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 6, L)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 6, L)
 				return 8
 			},
 		},
 		{
 			"CBx76; BIT 6, (HL)",
-			func(gbc *GBC) uint64 {
-				_bit(gbc, 6, gbc.Read(gbc.Reg16(HL)))
-				return 16
+			func(cpu *CPU) uint64 {
+				_bit(cpu, 6, cpu.Read(cpu.Reg16(HL)))
+				return 12
 			},
 		},
 		{
 			"CBx77; BIT 6, A",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 6, A)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 6, A)
 				return 8
 			},
 		},
 		{
 			"CBx78; BIT 7, B",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 7, B)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 7, B)
 				return 8
 			},
 		},
 		{
 			"CBx79; BIT 7, C",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 7, C)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 7, C)
 				return 8
 			},
 		},
 		{
 			"CBx7A; BIT 7, D",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 7, D)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 7, D)
 				return 8
 			},
 		},
 		{
 			"CBx7B; BIT 7, E",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 7, E)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 7, E)
 				return 8
 			},
 		},
 		{
 			"CBx7C; BIT 7, H",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 7, H)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 7, H)
 				return 8
 			},
 		},
 		{
 			"CBx7D; BIT 7, L",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 7, L)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 7, L)
 				return 8
 			},
 		},
 		{
 			"CBx7E; BIT 7, (HL)",
-			func(gbc *GBC) uint64 {
-				_bit(gbc, 7, gbc.Read(gbc.Reg16(HL)))
-				return 16
+			func(cpu *CPU) uint64 {
+				_bit(cpu, 7, cpu.Read(cpu.Reg16(HL)))
+				return 12
 			},
 		},
 		{
 			"CBx7F; BIT 7, A",
-			func(gbc *GBC) uint64 {
-				bit(gbc, 7, A)
+			func(cpu *CPU) uint64 {
+				bit(cpu, 7, A)
 				return 8
 			},
 		},
 		{
 			"CBx80; RES 0, B",
-			func(gbc *GBC) uint64 {
-				res(gbc, 0, B)
+			func(cpu *CPU) uint64 {
+				res(cpu, 0, B)
 				return 8
 			},
 		},
 		{
 			"CBx81; RES 0, C",
-			func(gbc *GBC) uint64 {
-				res(gbc, 0, C)
+			func(cpu *CPU) uint64 {
+				res(cpu, 0, C)
 				return 8
 			},
 		},
 		{
 			"CBx82; RES 0, D",
-			func(gbc *GBC) uint64 {
-				res(gbc, 0, D)
+			func(cpu *CPU) uint64 {
+				res(cpu, 0, D)
 				return 8
 			},
 		},
 		{
 			"CBx83; RES 0, E",
-			func(gbc *GBC) uint64 {
-				res(gbc, 0, E)
+			func(cpu *CPU) uint64 {
+				res(cpu, 0, E)
 				return 8
 			},
 		},
 		{
 			"CBx84; RES 0, H",
-			func(gbc *GBC) uint64 {
-				res(gbc, 0, H)
+			func(cpu *CPU) uint64 {
+				res(cpu, 0, H)
 				return 8
 			},
 		},
 		{
 			"CBx85; RES 0, L",
-			func(gbc *GBC) uint64 {
-				res(gbc, 0, L)
+			func(cpu *CPU) uint64 {
+				res(cpu, 0, L)
 				return 8
 			},
 		},
 		{
 			"CBx86; RES 0, (HL)",
-			func(gbc *GBC) uint64 {
-				_res(gbc, 0, HL)
+			func(cpu *CPU) uint64 {
+				_res(cpu, 0, HL)
 				return 16
 			},
 		},
 		{
 			"CBx87; RES 0, A",
-			func(gbc *GBC) uint64 {
-				res(gbc, 0, A)
+			func(cpu *CPU) uint64 {
+				res(cpu, 0, A)
 				return 8
 			},
 		},
 		{
 			"CBx88; RES 1, B",
-			func(gbc *GBC) uint64 {
-				res(gbc, 1, B)
+			func(cpu *CPU) uint64 {
+				res(cpu, 1, B)
 				return 8
 			},
 		},
 		{
 			"CBx89; RES 1, C",
-			func(gbc *GBC) uint64 {
-				res(gbc, 1, C)
+			func(cpu *CPU) uint64 {
+				res(cpu, 1, C)
 				return 8
 			},
 		},
 		{
 			"CBx8A; RES 1, D",
-			func(gbc *GBC) uint64 {
-				res(gbc, 1, D)
+			func(cpu *CPU) uint64 {
+				res(cpu, 1, D)
 				return 8
 			},
 		},
 		{
 			"CBx8B; RES 1, E",
-			func(gbc *GBC) uint64 {
-				res(gbc, 1, E)
+			func(cpu *CPU) uint64 {
+				res(cpu, 1, E)
 				return 8
 			},
 		},
 		{
 			"CBx8C; RES 1, H",
-			func(gbc *GBC) uint64 {
-				res(gbc, 1, H)
+			func(cpu *CPU) uint64 {
+				res(cpu, 1, H)
 				return 8
 			},
 		},
 		{
 			"CBx8D; RES 1, L",
-			func(gbc *GBC) uint64 {
-				res(gbc, 1, L)
+			func(cpu *CPU) uint64 {
+				res(cpu, 1, L)
 				return 8
 			},
 		},
 		{
 			"CBx8E; RES 1, (HL)",
-			func(gbc *GBC) uint64 {
-				_res(gbc, 1, HL)
+			func(cpu *CPU) uint64 {
+				_res(cpu, 1, HL)
 				return 16
 			},
 		},
 		{
 			"CBx8F; RES 1, A",
-			func(gbc *GBC) uint64 {
-				res(gbc, 1, A)
+			func(cpu *CPU) uint64 {
+				res(cpu, 1, A)
 				return 8
 			},
 		},
 		{
 			"CBx90; RES 2, B",
-			func(gbc *GBC) uint64 {
-				res(gbc, 2, B)
+			func(cpu *CPU) uint64 {
+				res(cpu, 2, B)
 				return 8
 			},
 		},
 		{
 			"CBx91; RES 2, C",
-			func(gbc *GBC) uint64 {
-				res(gbc, 2, C)
+			func(cpu *CPU) uint64 {
+				res(cpu, 2, C)
 				return 8
 			},
 		},
 		{
 			"CBx92; RES 2, D",
-			func(gbc *GBC) uint64 {
-				res(gbc, 2, D)
+			func(cpu *CPU) uint64 {
+				res(cpu, 2, D)
 				return 8
 			},
 		},
 		{
 			"CBx93; RES 2, E",
-			func(gbc *GBC) uint64 {
-				res(gbc, 2, E)
+			func(cpu *CPU) uint64 {
+				res(cpu, 2, E)
 				return 8
 			},
 		},
 		{
 			"CBx94; RES 2, H",
-			func(gbc *GBC) uint64 {
-				res(gbc, 2, H)
+			func(cpu *CPU) uint64 {
+				res(cpu, 2, H)
 				return 8
 			},
 		},
 		{
 			"CBx95; RES 2, L",
-			func(gbc *GBC) uint64 {
-				res(gbc, 2, L)
+			func(cpu *CPU) uint64 {
+				res(cpu, 2, L)
 				return 8
 			},
 		},
 		{
 			"CBx96; RES 2, (HL)",
-			func(gbc *GBC) uint64 {
-				_res(gbc, 2, HL)
+			func(cpu *CPU) uint64 {
+				_res(cpu, 2, HL)
 				return 16
 			},
 		},
 		{
 			"CBx97; RES 2, A",
-			func(gbc *GBC) uint64 {
-				res(gbc, 2, A)
+			func(cpu *CPU) uint64 {
+				res(cpu, 2, A)
 				return 8
 			},
 		},
 		{
 			"CBx98; RES 3, B",
-			func(gbc *GBC) uint64 {
-				res(gbc, 3, B)
+			func(cpu *CPU) uint64 {
+				res(cpu, 3, B)
 				return 8
 			},
 		},
 		{
 			"CBx99; RES 3, C",
-			func(gbc *GBC) uint64 {
-				res(gbc, 3, C)
+			func(cpu *CPU) uint64 {
+				res(cpu, 3, C)
 				return 8
 			},
 		},
 		{
 			"CBx9A; RES 3, D",
-			func(gbc *GBC) uint64 {
-				res(gbc, 3, D)
+			func(cpu *CPU) uint64 {
+				res(cpu, 3, D)
 				return 8
 			},
 		},
 		{
 			"CBx9B; RES 3, E",
-			func(gbc *GBC) uint64 {
-				res(gbc, 3, E)
+			func(cpu *CPU) uint64 {
+				res(cpu, 3, E)
 				return 8
 			},
 		},
 		{
 			"CBx9C; RES 3, H",
-			func(gbc *GBC) uint64 {
-				res(gbc, 3, H)
+			func(cpu *CPU) uint64 {
+				res(cpu, 3, H)
 				return 8
 			},
 		},
 		{
 			"CBx9D; RES 3, L",
-			func(gbc *GBC) uint64 {
-				res(gbc, 3, L)
+			func(cpu *CPU) uint64 {
+				res(cpu, 3, L)
 				return 8
 			},
 		},
 		{
 			"CBx9E; RES 3, (HL)",
-			func(gbc *GBC) uint64 {
-				_res(gbc, 3, HL)
+			func(cpu *CPU) uint64 {
+				_res(cpu, 3, HL)
 				return 16
 			},
 		},
 		{
 			"CBx9F; RES 3, A",
-			func(gbc *GBC) uint64 {
-				res(gbc, 3, A)
+			func(cpu *CPU) uint64 {
+				res(cpu, 3, A)
 				return 8
 			},
 		},
 		{
 			"CBxA0; RES 4, B",
-			func(gbc *GBC) uint64 {
-				res(gbc, 4, B)
+			func(cpu *CPU) uint64 {
+				res(cpu, 4, B)
 				return 8
 			},
 		},
 		{
 			"CBxA1; RES 4, C",
-			func(gbc *GBC) uint64 {
-				res(gbc, 4, C)
+			func(cpu *CPU) uint64 {
+				res(cpu, 4, C)
 				return 8
 			},
 		},
 		{
 			"CBxA2; RES 4, D",
-			func(gbc *GBC) uint64 {
-				res(gbc, 4, D)
+			func(cpu *CPU) uint64 {
+				res(cpu, 4, D)
 				return 8
 			},
 		},
 		{
 			"CBxA3; RES 4, E",
-			func(gbc *GBC) uint64 {
-				res(gbc, 4, E)
+			func(cpu *CPU) uint64 {
+				res(cpu, 4, E)
 				return 8
 			},
 		},
 		{
 			"CBxA4; RES 4, H",
-			func(gbc *GBC) uint64 {
-				res(gbc, 4, H)
+			func(cpu *CPU) uint64 {
+				res(cpu, 4, H)
 				return 8
 			},
 		},
 		{
 			"CBxA5; RES 4, L",
-			func(gbc *GBC) uint64 {
-				res(gbc, 4, L)
+			func(cpu *CPU) uint64 {
+				res(cpu, 4, L)
 				return 8
 			},
 		},
 		{
 			"CBxA6; RES 4, (HL)",
-			func(gbc *GBC) uint64 {
-				_res(gbc, 4, HL)
+			func(cpu *CPU) uint64 {
+				_res(cpu, 4, HL)
 				return 16
 			},
 		},
 		{
 			"CBxA7; RES 4, A",
-			func(gbc *GBC) uint64 {
-				res(gbc, 4, A)
+			func(cpu *CPU) uint64 {
+				res(cpu, 4, A)
 				return 8
 			},
 		},
 		{
 			"CBxA8; RES 5, B",
-			func(gbc *GBC) uint64 {
-				res(gbc, 5, B)
+			func(cpu *CPU) uint64 {
+				res(cpu, 5, B)
 				return 8
 			},
 		},
 		{
 			"CBxA9; RES 5, C",
-			func(gbc *GBC) uint64 {
-				res(gbc, 5, C)
+			func(cpu *CPU) uint64 {
+				res(cpu, 5, C)
 				return 8
 			},
 		},
 		{
 			"CBxAA; RES 5, D",
-			func(gbc *GBC) uint64 {
-				res(gbc, 5, D)
+			func(cpu *CPU) uint64 {
+				res(cpu, 5, D)
 				return 8
 			},
 		},
 		{
 			"CBxAB; RES 5, E",
-			func(gbc *GBC) uint64 {
-				res(gbc, 5, E)
+			func(cpu *CPU) uint64 {
+				res(cpu, 5, E)
 				return 8
 			},
 		},
 		{
 			"CBxAC; RES 5, H",
-			func(gbc *GBC) uint64 {
-				res(gbc, 5, H)
+			func(cpu *CPU) uint64 {
+				res(cpu, 5, H)
 				return 8
 			},
 		},
 		{
 			"CBxAD; RES 5, L",
-			func(gbc *GBC) uint64 {
-				res(gbc, 5, L)
+			func(cpu *CPU) uint64 {
+				res(cpu, 5, L)
 				return 8
 			},
 		},
 		{
 			"CBxAE; RES 5, (HL)",
-			func(gbc *GBC) uint64 {
-				_res(gbc, 5, HL)
+			func(cpu *CPU) uint64 {
+				_res(cpu, 5, HL)
 				return 16
 			},
 		},
 		{
 			"CBxAF; RES 5, A",
-			func(gbc *GBC) uint64 {
-				res(gbc, 5, A)
+			func(cpu *CPU) uint64 {
+				res(cpu, 5, A)
 				return 8
 			},
 		},
 		{
 			"CBxB0; RES 6, B",
-			func(gbc *GBC) uint64 {
-				res(gbc, 6, B)
+			func(cpu *CPU) uint64 {
+				res(cpu, 6, B)
 				return 8
 			},
 		},
 		{
 			"CBxB1; RES 6, C",
-			func(gbc *GBC) uint64 {
-				res(gbc, 6, C)
+			func(cpu *CPU) uint64 {
+				res(cpu, 6, C)
 				return 8
 			},
 		},
 		{
 			"CBxB2; RES 6, D",
-			func(gbc *GBC) uint64 {
-				res(gbc, 6, D)
+			func(cpu *CPU) uint64 {
+				res(cpu, 6, D)
 				return 8
 			},
 		},
 		{
 			"CBxB3; RES 6, E",
-			func(gbc *GBC) uint64 {
-				res(gbc, 6, E)
+			func(cpu *CPU) uint64 {
+				res(cpu, 6, E)
 				return 8
 			},
 		},
 		{
 			"CBxB4; RES 6, H",
-			func(gbc *GBC) uint64 {
-				res(gbc, 6, H)
+			func(cpu *CPU) uint64 {
+				res(cpu, 6, H)
 				return 8
 			},
 		},
 		{
 			"CBxB5; RES 6, L",
-			func(gbc *GBC) uint64 {
-				res(gbc, 6, L)
+			func(cpu *CPU) uint64 {
+				res(cpu, 6, L)
 				return 8
 			},
 		},
 		{
 			"CBxB6; RES 6, (HL)",
-			func(gbc *GBC) uint64 {
-				_res(gbc, 6, HL)
+			func(cpu *CPU) uint64 {
+				_res(cpu, 6, HL)
 				return 16
 			},
 		},
 		{
 			"CBxB7; RES 6, A",
-			func(gbc *GBC) uint64 {
-				res(gbc, 6, A)
+			func(cpu *CPU) uint64 {
+				res(cpu, 6, A)
 				return 8
 			},
 		},
 		{
 			"CBxB8; RES 7, B",
-			func(gbc *GBC) uint64 {
-				res(gbc, 7, B)
+			func(cpu *CPU) uint64 {
+				res(cpu, 7, B)
 				return 8
 			},
 		},
 		{
 			"CBxB9; RES 7, C",
-			func(gbc *GBC) uint64 {
-				res(gbc, 7, C)
+			func(cpu *CPU) uint64 {
+				res(cpu, 7, C)
 				return 8
 			},
 		},
 		{
 			"CBxBA; RES 7, D",
-			func(gbc *GBC) uint64 {
-				res(gbc, 7, D)
+			func(cpu *CPU) uint64 {
+				res(cpu, 7, D)
 				return 8
 			},
 		},
 		{
 			"CBxBB; RES 7, E",
-			func(gbc *GBC) uint64 {
-				res(gbc, 7, E)
+			func(cpu *CPU) uint64 {
+				res(cpu, 7, E)
 				return 8
 			},
 		},
 		{
 			"CBxBC; RES 7, H",
-			func(gbc *GBC) uint64 {
-				res(gbc, 7, H)
+			func(cpu *CPU) uint64 {
+				res(cpu, 7, H)
 				return 8
 			},
 		},
 		{
 			"CBxBD; RES 7, L",
-			func(gbc *GBC) uint64 {
-				res(gbc, 7, L)
+			func(cpu *CPU) uint64 {
+				res(cpu, 7, L)
 				return 8
 			},
 		},
 		{
 			"CBxBE; RES 7, (HL)",
-			func(gbc *GBC) uint64 {
-				_res(gbc, 7, HL)
+			func(cpu *CPU) uint64 {
+				_res(cpu, 7, HL)
 				return 16
 			},
 		},
 		{
 			"CBxBF; RES 7, A",
-			func(gbc *GBC) uint64 {
-				res(gbc, 7, A)
+			func(cpu *CPU) uint64 {
+				res(cpu, 7, A)
 				return 8
 			},
 		},
 		{
 			"CBxC0; SET 0, B",
-			func(gbc *GBC) uint64 {
-				set(gbc, 0, B)
+			func(cpu *CPU) uint64 {
+				set(cpu, 0, B)
 				return 8
 			},
 		},
 		{
 			"CBxC1; SET 0, C",
-			func(gbc *GBC) uint64 {
-				set(gbc, 0, C)
+			func(cpu *CPU) uint64 {
+				set(cpu, 0, C)
 				return 8
 			},
 		},
 		{
 			"CBxC2; SET 0, D",
-			func(gbc *GBC) uint64 {
-				set(gbc, 0, D)
+			func(cpu *CPU) uint64 {
+				set(cpu, 0, D)
 				return 8
 			},
 		},
 		{
 			"CBxC3; SET 0, E",
-			func(gbc *GBC) uint64 {
-				set(gbc, 0, E)
+			func(cpu *CPU) uint64 {
+				set(cpu, 0, E)
 				return 8
 			},
 		},
 		{
 			"CBxC4; SET 0, H",
-			func(gbc *GBC) uint64 {
-				set(gbc, 0, H)
+			func(cpu *CPU) uint64 {
+				set(cpu, 0, H)
 				return 8
 			},
 		},
 		{
 			"CBxC5; SET 0, L",
-			func(gbc *GBC) uint64 {
-				set(gbc, 0, L)
+			func(cpu *CPU) uint64 {
+				set(cpu, 0, L)
 				return 8
 			},
 		},
 		{
 			"CBxC6; SET 0, (HL)",
-			func(gbc *GBC) uint64 {
-				_set(gbc, 0, HL)
+			func(cpu *CPU) uint64 {
+				_set(cpu, 0, HL)
 				return 16
 			},
 		},
 		{
 			"CBxC7; SET 0, A",
-			func(gbc *GBC) uint64 {
-				set(gbc, 0, A)
+			func(cpu *CPU) uint64 {
+				set(cpu, 0, A)
 				return 8
 			},
 		},
 		{
 			"CBxC8; SET 1, B",
-			func(gbc *GBC) uint64 {
-				set(gbc, 1, B)
+			func(cpu *CPU) uint64 {
+				set(cpu, 1, B)
 				return 8
 			},
 		},
 		{
 			"CBxC9; SET 1, C",
-			func(gbc *GBC) uint64 {
-				set(gbc, 1, C)
+			func(cpu *CPU) uint64 {
+				set(cpu, 1, C)
 				return 8
 			},
 		},
 		{
 			"CBxCA; SET 1, D",
-			func(gbc *GBC) uint64 {
-				set(gbc, 1, D)
+			func(cpu *CPU) uint64 {
+				set(cpu, 1, D)
 				return 8
 			},
 		},
 		{
 			"CBxCB; SET 1, E",
-			func(gbc *GBC) uint64 {
-				set(gbc, 1, E)
+			func(cpu *CPU) uint64 {
+				set(cpu, 1, E)
 				return 8
 			},
 		},
 		{
 			"CBxCC; SET 1, H",
-			func(gbc *GBC) uint64 {
-				set(gbc, 1, H)
+			func(cpu *CPU) uint64 {
+				set(cpu, 1, H)
 				return 8
 			},
 		},
 		{
 			"CBxCD; SET 1, L",
-			func(gbc *GBC) uint64 {
-				set(gbc, 1, L)
+			func(cpu *CPU) uint64 {
+				set(cpu, 1, L)
 				return 8
 			},
 		},
 		{
 			"CBxCE; SET 1, (HL)",
-			func(gbc *GBC) uint64 {
-				_set(gbc, 1, HL)
+			func(cpu *CPU) uint64 {
+				_set(cpu, 1, HL)
 				return 16
 			},
 		},
 		{
 			"CBxCF; SET 1, A",
-			func(gbc *GBC) uint64 {
-				set(gbc, 1, A)
+			func(cpu *CPU) uint64 {
+				set(cpu, 1, A)
 				return 8
 			},
 		},
 		{
 			"CBxD0; SET 2, B",
-			func(gbc *GBC) uint64 {
-				set(gbc, 2, B)
+			func(cpu *CPU) uint64 {
+				set(cpu, 2, B)
 				return 8
 			},
 		},
 		{
 			"CBxD1; SET 2, C",
-			func(gbc *GBC) uint64 {
-				set(gbc, 2, C)
+			func(cpu *CPU) uint64 {
+				set(cpu, 2, C)
 				return 8
 			},
 		},
 		{
 			"CBxD2; SET 2, D",
-			func(gbc *GBC) uint64 {
-				set(gbc, 2, D)
+			func(cpu *CPU) uint64 {
+				set(cpu, 2, D)
 				return 8
 			},
 		},
 		{
 			"CBxD3; SET 2, E",
-			func(gbc *GBC) uint64 {
-				set(gbc, 2, E)
+			func(cpu *CPU) uint64 {
+				set(cpu, 2, E)
 				return 8
 			},
 		},
 		{
 			"CBxD4; SET 2, H",
-			func(gbc *GBC) uint64 {
-				set(gbc, 2, H)
+			func(cpu *CPU) uint64 {
+				set(cpu, 2, H)
 				return 8
 			},
 		},
 		{
 			"CBxD5; SET 2, L",
-			func(gbc *GBC) uint64 {
-				set(gbc, 2, L)
+			func(cpu *CPU) uint64 {
+				set(cpu, 2, L)
 				return 8
 			},
 		},
 		{
 			"CBxD6; SET 2, (HL)",
-			func(gbc *GBC) uint64 {
-				_set(gbc, 2, HL)
+			func(cpu *CPU) uint64 {
+				_set(cpu, 2, HL)
 				return 16
 			},
 		},
 		{
 			"CBxD7; SET 2, A",
-			func(gbc *GBC) uint64 {
-				set(gbc, 2, A)
+			func(cpu *CPU) uint64 {
+				set(cpu, 2, A)
 				return 8
 			},
 		},
 		{
 			"CBxD8; SET 3, B",
-			func(gbc *GBC) uint64 {
-				set(gbc, 3, B)
+			func(cpu *CPU) uint64 {
+				set(cpu, 3, B)
 				return 8
 			},
 		},
 		{
 			"CBxD9; SET 3, C",
-			func(gbc *GBC) uint64 {
-				set(gbc, 3, C)
+			func(cpu *CPU) uint64 {
+				set(cpu, 3, C)
 				return 8
 			},
 		},
 		{
 			"CBxDA; SET 3, D",
-			func(gbc *GBC) uint64 {
-				set(gbc, 3, D)
+			func(cpu *CPU) uint64 {
+				set(cpu, 3, D)
 				return 8
 			},
 		},
 		{
 			"CBxDB; SET 3, E",
-			func(gbc *GBC) uint64 {
-				set(gbc, 3, E)
+			func(cpu *CPU) uint64 {
+				set(cpu, 3, E)
 				return 8
 			},
 		},
 		{
 			"CBxDC; SET 3, H",
-			func(gbc *GBC) uint64 {
-				set(gbc, 3, H)
+			func(cpu *CPU) uint64 {
+				set(cpu, 3, H)
 				return 8
 			},
 		},
 		{
 			"CBxDD; SET 3, L",
-			func(gbc *GBC) uint64 {
-				set(gbc, 3, L)
+			func(cpu *CPU) uint64 {
+				set(cpu, 3, L)
 				return 8
 			},
 		},
 		{
 			"CBxDE; SET 3, (HL)",
-			func(gbc *GBC) uint64 {
-				_set(gbc, 3, HL)
+			func(cpu *CPU) uint64 {
+				_set(cpu, 3, HL)
 				return 16
 			},
 		},
 		{
 			"CBxDF; SET 3, A",
-			func(gbc *GBC) uint64 {
-				set(gbc, 3, A)
+			func(cpu *CPU) uint64 {
+				set(cpu, 3, A)
 				return 8
 			},
 		},
 		{
 			"CBxE0; SET 4, B",
-			func(gbc *GBC) uint64 {
-				set(gbc, 4, B)
+			func(cpu *CPU) uint64 {
+				set(cpu, 4, B)
 				return 8
 			},
 		},
 		{
 			"CBxE1; SET 4, C",
-			func(gbc *GBC) uint64 {
-				set(gbc, 4, C)
+			func(cpu *CPU) uint64 {
+				set(cpu, 4, C)
 				return 8
 			},
 		},
 		{
 			"CBxE2; SET 4, D",
-			func(gbc *GBC) uint64 {
-				set(gbc, 4, D)
+			func(cpu *CPU) uint64 {
+				set(cpu, 4, D)
 				return 8
 			},
 		},
 		{
 			"CBxE3; SET 4, E",
-			func(gbc *GBC) uint64 {
-				set(gbc, 4, E)
+			func(cpu *CPU) uint64 {
+				set(cpu, 4, E)
 				return 8
 			},
 		},
 		{
 			"CBxE4; SET 4, H",
-			func(gbc *GBC) uint64 {
-				set(gbc, 4, H)
+			func(cpu *CPU) uint64 {
+				set(cpu, 4, H)
 				return 8
 			},
 		},
 		{
 			"CBxE5; SET 4, L",
-			func(gbc *GBC) uint64 {
-				set(gbc, 4, L)
+			func(cpu *CPU) uint64 {
+				set(cpu, 4, L)
 				return 8
 			},
 		},
 		{
 			"CBxE6; SET 4, (HL)",
-			func(gbc *GBC) uint64 {
-				_set(gbc, 4, HL)
+			func(cpu *CPU) uint64 {
+				_set(cpu, 4, HL)
 				return 16
 			},
 		},
 		{
 			"CBxE7; SET 4, A",
-			func(gbc *GBC) uint64 {
-				set(gbc, 4, A)
+			func(cpu *CPU) uint64 {
+				set(cpu, 4, A)
 				return 8
 			},
 		},
 		{
 			"CBxE8; SET 5, B",
-			func(gbc *GBC) uint64 {
-				set(gbc, 5, B)
+			func(cpu *CPU) uint64 {
+				set(cpu, 5, B)
 				return 8
 			},
 		},
 		{
 			"CBxE9; SET 5, C",
-			func(gbc *GBC) uint64 {
-				set(gbc, 5, C)
+			func(cpu *CPU) uint64 {
+				set(cpu, 5, C)
 				return 8
 			},
 		},
 		{
 			"CBxEA; SET 5, D",
-			func(gbc *GBC) uint64 {
-				set(gbc, 5, D)
+			func(cpu *CPU) uint64 {
+				set(cpu, 5, D)
 				return 8
 			},
 		},
 		{
 			"CBxEB; SET 5, E",
-			func(gbc *GBC) uint64 {
-				set(gbc, 5, E)
+			func(cpu *CPU) uint64 {
+				set(cpu, 5, E)
 				return 8
 			},
 		},
 		{
 			"CBxEC; SET 5, H",
-			func(gbc *GBC) uint64 {
-				set(gbc, 5, H)
+			func(cpu *CPU) uint64 {
+				set(cpu, 5, H)
 				return 8
 			},
 		},
 		{
 			"CBxED; SET 5, L",
-			func(gbc *GBC) uint64 {
-				set(gbc, 5, L)
+			func(cpu *CPU) uint64 {
+				set(cpu, 5, L)
 				return 8
 			},
 		},
 		{
 			"CBxEE; SET 5, (HL)",
-			func(gbc *GBC) uint64 {
-				_set(gbc, 5, HL)
+			func(cpu *CPU) uint64 {
+				_set(cpu, 5, HL)
 				return 16
 			},
 		},
 		{
 			"CBxEF; SET 5, A",
-			func(gbc *GBC) uint64 {
-				set(gbc, 5, A)
+			func(cpu *CPU) uint64 {
+				set(cpu, 5, A)
 				return 8
 			},
 		},
 		{
 			"CBxF0; SET 6, B",
-			func(gbc *GBC) uint64 {
-				set(gbc, 6, B)
+			func(cpu *CPU) uint64 {
+				set(cpu, 6, B)
 				return 8
 			},
 		},
 		{
 			"CBxF1; SET 6, C",
-			func(gbc *GBC) uint64 {
-				set(gbc, 6, C)
+			func(cpu *CPU) uint64 {
+				set(cpu, 6, C)
 				return 8
 			},
 		},
 		{
 			"CBxF2; SET 6, D",
-			func(gbc *GBC) uint64 {
-				set(gbc, 6, D)
+			func(cpu *CPU) uint64 {
+				set(cpu, 6, D)
 				return 8
 			},
 		},
 		{
 			"CBxF3; SET 6, E",
-			func(gbc *GBC) uint64 {
-				set(gbc, 6, E)
+			func(cpu *CPU) uint64 {
+				set(cpu, 6, E)
 				return 8
 			},
 		},
 		{
 			"CBxF4; SET 6, H",
-			func(gbc *GBC) uint64 {
-				set(gbc, 6, H)
+			func(cpu *CPU) uint64 {
+				set(cpu, 6, H)
 				return 8
 			},
 		},
 		{
 			"CBxF5; SET 6, L",
-			func(gbc *GBC) uint64 {
-				set(gbc, 6, L)
+			func(cpu *CPU) uint64 {
+				set(cpu, 6, L)
 				return 8
 			},
 		},
 		{
 			"CBxF6; SET 6, (HL)",
-			func(gbc *GBC) uint64 {
-				_set(gbc, 6, HL)
+			func(cpu *CPU) uint64 {
+				_set(cpu, 6, HL)
 				return 16
 			},
 		},
 		{
 			"CBxF7; SET 6, A",
-			func(gbc *GBC) uint64 {
-				set(gbc, 6, A)
+			func(cpu *CPU) uint64 {
+				set(cpu, 6, A)
 				return 8
 			},
 		},
 		{
 			"CBxF8; SET 7, B",
-			func(gbc *GBC) uint64 {
-				set(gbc, 7, B)
+			func(cpu *CPU) uint64 {
+				set(cpu, 7, B)
 				return 8
 			},
 		},
 		{
 			"CBxF9; SET 7, C",
-			func(gbc *GBC) uint64 {
-				set(gbc, 7, C)
+			func(cpu *CPU) uint64 {
+				set(cpu, 7, C)
 				return 8
 			},
 		},
 		{
 			"CBxFA; SET 7, D",
-			func(gbc *GBC) uint64 {
-				set(gbc, 7, D)
+			func(cpu *CPU) uint64 {
+				set(cpu, 7, D)
 				return 8
 			},
 		},
 		{
 			"CBxFB; SET 7, E",
-			func(gbc *GBC) uint64 {
-				set(gbc, 7, E)
+			func(cpu *CPU) uint64 {
+				set(cpu, 7, E)
 				return 8
 			},
 		},
 		{
 			"CBxFC; SET 7, H",
-			func(gbc *GBC) uint64 {
-				set(gbc, 7, H)
+			func(cpu *CPU) uint64 {
+				set(cpu, 7, H)
 				return 8
 			},
 		},
 		{
 			"CBxFD; SET 7, L",
-			func(gbc *GBC) uint64 {
-				set(gbc, 7, L)
+			func(cpu *CPU) uint64 {
+				set(cpu, 7, L)
 				return 8
 			},
 		},
 		{
 			"CBxFE; SET 7, (HL)",
-			func(gbc *GBC) uint64 {
-				_set(gbc, 7, HL)
+			func(cpu *CPU) uint64 {
+				_set(cpu, 7, HL)
 				return 16
 			},
 		},
 		{
 			"CBxFF; SET 7, A",
-			func(gbc *GBC) uint64 {
-				set(gbc, 7, A)
+			func(cpu *CPU) uint64 {
+				set(cpu, 7, A)
 				return 8
 			},
 		},
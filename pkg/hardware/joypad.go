@@ -0,0 +1,44 @@
+package hardware
+
+// BUTTON is a set of pressed joypad buttons. The low nibble is the direction
+// pad and the high nibble the action buttons, in the order P1 reports them.
+type BUTTON byte
+
+const (
+	BUTTON_RIGHT BUTTON = 1 << iota
+	BUTTON_LEFT
+	BUTTON_UP
+	BUTTON_DOWN
+	BUTTON_A
+	BUTTON_B
+	BUTTON_SELECT
+	BUTTON_START
+)
+
+const P1 = 0xFF00
+
+// SetButtons replaces the set of held buttons, raising the joypad interrupt
+// if any of them were just pressed.
+func (gbc *GBC) SetButtons(b BUTTON) {
+	if b&^gbc.buttons != 0 {
+		gbc.Poke(0xFF0F, gbc.MMU.Read(0xFF0F)|0x10)
+	}
+	gbc.buttons = b
+}
+
+// Buttons returns the set of held buttons.
+func (gbc *GBC) Buttons() BUTTON { return gbc.buttons }
+
+// joypad returns P1 with the lines picked by its select bits pulled low for
+// each held button.
+func (gbc *GBC) joypad() byte {
+	sel := gbc.MMU.Read(P1) & 0x30
+	lines := byte(0x0F)
+	if sel&0x10 == 0 {
+		lines &^= byte(gbc.buttons) & 0x0F
+	}
+	if sel&0x20 == 0 {
+		lines &^= byte(gbc.buttons) >> 4
+	}
+	return 0xC0 | sel | lines
+}
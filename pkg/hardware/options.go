@@ -0,0 +1,83 @@
+package hardware
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"log"
+)
+
+var ErrBootROMSize = errors.New("boot rom must be 256 (DMG) or 2304 (CGB) bytes")
+
+// Option configures a GBC created with New.
+type Option func(*GBC) error
+
+// WithModel overrides the model picked from the cartridge header.
+func WithModel(model MODEL) Option {
+	return func(gbc *GBC) error {
+		gbc.model = model
+		return nil
+	}
+}
+
+// WithBootROM maps a boot ROM over the start of the cartridge and starts
+// execution at 0x0000 instead of skipping straight to 0x0100.
+func WithBootROM(boot []byte) Option {
+	return func(gbc *GBC) error {
+		if len(boot) != 0x100 && len(boot) != 0x900 {
+			return ErrBootROMSize
+		}
+		gbc.bootROM = append([]byte(nil), boot...)
+		return nil
+	}
+}
+
+// WithSaveRAM restores the cartridge RAM from a previous SaveRAM.
+func WithSaveRAM(sram []byte) Option {
+	return func(gbc *GBC) error {
+		gbc.sram = sram
+		return nil
+	}
+}
+
+// WithAudioSink sends the APU's samples to sink. There is no APU yet, so
+// for now sink receives nothing.
+func WithAudioSink(sink AudioSink) Option {
+	return func(gbc *GBC) error {
+		gbc.audio = sink
+		return nil
+	}
+}
+
+func WithSerial(peer SerialPeer) Option {
+	return func(gbc *GBC) error {
+		gbc.serial = peer
+		return nil
+	}
+}
+
+// WithLogger sends debug output to l. By default it is discarded.
+func WithLogger(l *log.Logger) Option {
+	return func(gbc *GBC) error {
+		gbc.log = l
+		return nil
+	}
+}
+
+// WithTraceCompare checks the CPU state before every instruction against a
+// Gameboy Doctor style log read from r.
+func WithTraceCompare(r io.Reader) Option {
+	return func(gbc *GBC) error {
+		gbc.debug_compare = bufio.NewScanner(r)
+		return nil
+	}
+}
+
+// WithBlockCache runs code from a cache of pre-decoded blocks, which
+// roughly halves the time RunFrame takes, see SetBlockCache.
+func WithBlockCache() Option {
+	return func(gbc *GBC) error {
+		gbc.SetBlockCache(true)
+		return nil
+	}
+}
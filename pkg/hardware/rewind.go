@@ -0,0 +1,171 @@
+package hardware
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+var ErrNoRewind = errors.New("no rewind history")
+
+// RewindBuffer keeps a bounded history of save states taken every few
+// frames. Only the newest snapshot is kept whole; each older one is stored
+// as the XOR of it and the snapshot after it, with the runs of zero bytes
+// that leaves run-length encoded. Consecutive frames differ in a few hundred
+// bytes at most, so a snapshot usually costs far less than a full state.
+type RewindBuffer struct {
+	gbc      *GBC
+	interval int
+	budget   int
+
+	frames int
+	latest []byte
+	// deltas is ordered oldest first. deltas[i] turns the snapshot after it
+	// back into snapshot i.
+	deltas [][]byte
+	size   int
+	buf    bytes.Buffer
+}
+
+// NewRewindBuffer snapshots gbc every interval frames, dropping the oldest
+// snapshots once they take more than budget bytes.
+func NewRewindBuffer(gbc *GBC, interval, budget int) *RewindBuffer {
+	if interval < 1 {
+		interval = 1
+	}
+	return &RewindBuffer{gbc: gbc, interval: interval, budget: budget}
+}
+
+// Frame should be called once after every emulated frame.
+func (r *RewindBuffer) Frame() error {
+	r.frames++
+	if r.frames < r.interval && r.latest != nil {
+		return nil
+	}
+	r.frames = 0
+	return r.snapshot()
+}
+
+func (r *RewindBuffer) snapshot() error {
+	r.buf.Reset()
+	if err := r.gbc.SaveState(&r.buf); err != nil {
+		return err
+	}
+	state := append([]byte(nil), r.buf.Bytes()...)
+	if r.latest != nil {
+		delta := xorDelta(state, r.latest)
+		r.deltas = append(r.deltas, delta)
+		r.size += len(delta)
+		r.size -= len(r.latest)
+	}
+	r.latest = state
+	r.size += len(state)
+	for r.size > r.budget && len(r.deltas) > 0 {
+		r.size -= len(r.deltas[0])
+		r.deltas[0] = nil
+		r.deltas = r.deltas[1:]
+	}
+	return nil
+}
+
+// Len returns the number of snapshots held.
+func (r *RewindBuffer) Len() int {
+	if r.latest == nil {
+		return 0
+	}
+	return len(r.deltas) + 1
+}
+
+// Size returns the number of bytes the snapshots take.
+func (r *RewindBuffer) Size() int { return r.size }
+
+// Rewind restores the machine to the snapshot at least frames frames ago, or
+// the oldest one held if the history is shorter. The snapshots after it are
+// discarded. It returns the number of frames actually rewound.
+func (r *RewindBuffer) Rewind(frames int) (int, error) {
+	if r.latest == nil {
+		return 0, ErrNoRewind
+	}
+	// The newest snapshot is r.frames frames old, each one before it is
+	// another interval further back.
+	back := r.frames
+	state := r.latest
+	for back < frames && len(r.deltas) > 0 {
+		delta := r.deltas[len(r.deltas)-1]
+		r.deltas = r.deltas[:len(r.deltas)-1]
+		r.size -= len(delta)
+		state = applyDelta(state, delta)
+		back += r.interval
+	}
+	if err := r.gbc.LoadState(bytes.NewReader(state)); err != nil {
+		return 0, err
+	}
+	r.size += len(state) - len(r.latest)
+	r.latest = state
+	r.frames = 0
+	return back, nil
+}
+
+// xorDelta encodes the XOR of cur and prev as the length of prev followed by
+// pairs of a zero run length and a literal length, then the literal bytes.
+func xorDelta(cur, prev []byte) []byte {
+	out := appendUvarint(nil, uint64(len(prev)))
+	x := func(i int) byte {
+		var a, b byte
+		if i < len(cur) {
+			a = cur[i]
+		}
+		if i < len(prev) {
+			b = prev[i]
+		}
+		return a ^ b
+	}
+	n := len(prev)
+	if len(cur) > n {
+		n = len(cur)
+	}
+	for i := 0; i < n; {
+		zeros := i
+		for i < n && x(i) == 0 {
+			i++
+		}
+		lits := i
+		// Short runs of zeros cost more to encode than to copy.
+		for i < n && (x(i) != 0 || (i+1 < n && x(i+1) != 0)) {
+			i++
+		}
+		out = appendUvarint(out, uint64(lits-zeros))
+		out = appendUvarint(out, uint64(i-lits))
+		for j := lits; j < i; j++ {
+			out = append(out, x(j))
+		}
+	}
+	return out
+}
+
+// applyDelta recovers prev from cur and the delta xorDelta(cur, prev).
+func applyDelta(cur, delta []byte) []byte {
+	n, k := binary.Uvarint(delta)
+	delta = delta[k:]
+	prev := make([]byte, n)
+	copy(prev, cur)
+	for i := 0; len(delta) > 0; {
+		zeros, k := binary.Uvarint(delta)
+		delta = delta[k:]
+		lits, k := binary.Uvarint(delta)
+		delta = delta[k:]
+		i += int(zeros)
+		for j := 0; j < int(lits); j, i = j+1, i+1 {
+			if i < len(prev) {
+				prev[i] ^= delta[j]
+			}
+		}
+		delta = delta[lits:]
+	}
+	return prev
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
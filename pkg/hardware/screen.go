@@ -0,0 +1,240 @@
+package hardware
+
+import (
+	"image"
+	"image/color"
+)
+
+const (
+	SCREEN_WIDTH  = 160
+	SCREEN_HEIGHT = 144
+)
+
+const (
+	LCDC = 0xFF40
+	SCY  = 0xFF42
+	SCX  = 0xFF43
+	BGP  = 0xFF47
+	OBP0 = 0xFF48
+	OBP1 = 0xFF49
+	WY   = 0xFF4A
+	WX   = 0xFF4B
+	VBK  = 0xFF4F
+	BCPS = 0xFF68
+	OCPS = 0xFF6A
+)
+
+// Screen draws the picture described by VRAM, OAM and the LCD registers as
+// they are now, without changing any of them. On a DMG each pixel is set to its shade 0-3 in the palette
+// chosen with SetPalette. On a CGB pixels 0-31 index the eight background
+// palettes and 32-63 the eight sprite palettes. The whole frame is drawn at
+// once, so effects that change registers between scanlines are not shown.
+func (gbc *GBC) Screen() *image.Paletted {
+	img := image.NewPaletted(image.Rect(0, 0, SCREEN_WIDTH, SCREEN_HEIGHT), nil)
+	gbc.DrawScreen(img)
+	return img
+}
+
+// DrawScreen draws the screen like Screen does into img, which must be
+// 160x144, replacing its palette. It only allocates the first time a CGB
+// screen is drawn into img.
+func (gbc *GBC) DrawScreen(img *image.Paletted) {
+	var l lcd
+	l.cgb = gbc.model == CGB
+	l.lcdc = gbc.Peek(LCDC)
+	if l.cgb {
+		img.Palette = gbc.cgbColours(img.Palette)
+	} else {
+		img.Palette = gbc.Palette()
+	}
+	if l.lcdc&0x80 == 0 {
+		for i := range img.Pix {
+			img.Pix[i] = 0
+		}
+		return
+	}
+	l.vram = gbc.video.vram
+	for y := 0; y < SCREEN_HEIGHT; y++ {
+		gbc.drawBackground(img, y, &l)
+		if l.lcdc&0x02 != 0 {
+			gbc.drawSprites(img, y, &l)
+		}
+	}
+}
+
+// lcd is what drawing one frame needs beyond the registers.
+type lcd struct {
+	cgb  bool
+	lcdc byte
+	vram [2][0x2000]byte
+	// bg holds the colour number of the background under each pixel of the
+	// line, which decides whether sprites behind the background show, with
+	// 0x80 set where a CGB tile has priority over sprites.
+	bg [SCREEN_WIDTH]byte
+}
+
+// tilePixel returns the colour number of pixel x, y of the tile at addr.
+func tilePixel(vram *[0x2000]byte, addr uint16, x, y int) byte {
+	i := addr - 0x8000 + uint16(y)*2
+	lo, hi := vram[i], vram[i+1]
+	bit := 7 - uint(x)
+	return (hi>>bit&1)<<1 | lo>>bit&1
+}
+
+// tileAddr returns the address of the background tile with index n.
+func tileAddr(lcdc, n byte) uint16 {
+	if lcdc&0x10 != 0 {
+		return 0x8000 + uint16(n)*16
+	}
+	return uint16(0x9000 + int(int8(n))*16)
+}
+
+func shade(palette, colour byte) uint8 {
+	return palette >> (colour * 2) & 3
+}
+
+// cgbColour converts a little endian RGB555 colour from palette RAM.
+func cgbColour(lo, hi byte) color.RGBA {
+	c := uint16(hi)<<8 | uint16(lo)
+	expand := func(v uint16) uint8 {
+		v &= 0x1F
+		return uint8(v<<3 | v>>2)
+	}
+	return color.RGBA{expand(c), expand(c >> 5), expand(c >> 10), 0xFF}
+}
+
+// cgbColours returns the 64 colours of the background and sprite palettes,
+// reusing p if it is big enough.
+func (gbc *GBC) cgbColours(p color.Palette) color.Palette {
+	if cap(p) < 64 {
+		p = make(color.Palette, 64)
+	}
+	p = p[:64]
+	for i, pal := range gbc.video.palette {
+		for n := 0; n < 32; n++ {
+			p[i*32+n] = cgbColour(pal[n*2], pal[n*2+1])
+		}
+	}
+	return p
+}
+
+func (gbc *GBC) drawBackground(img *image.Paletted, y int, l *lcd) {
+	bgp := gbc.Peek(BGP)
+	scx, scy := int(gbc.Peek(SCX)), int(gbc.Peek(SCY))
+	wx, wy := int(gbc.Peek(WX))-7, int(gbc.Peek(WY))
+	window := l.lcdc&0x20 != 0 && y >= wy
+	for x := 0; x < SCREEN_WIDTH; x++ {
+		// On a CGB bit 0 takes away the background's priority instead of
+		// blanking it.
+		if l.lcdc&0x01 == 0 && !l.cgb {
+			l.bg[x] = 0
+			img.SetColorIndex(x, y, 0)
+			continue
+		}
+		mapBase, px, py := uint16(0x9800), (x+scx)&0xFF, (y+scy)&0xFF
+		if l.lcdc&0x08 != 0 {
+			mapBase = 0x9C00
+		}
+		if window && x >= wx {
+			mapBase, px, py = 0x9800, x-wx, y-wy
+			if l.lcdc&0x40 != 0 {
+				mapBase = 0x9C00
+			}
+		}
+		index, colour, attr := l.bgPixel(bgp, mapBase, px, py)
+		l.bg[x] = colour
+		if attr&0x80 != 0 && l.lcdc&0x01 != 0 {
+			l.bg[x] |= 0x80
+		}
+		img.SetColorIndex(x, y, index)
+	}
+}
+
+// bgPixel returns the palette index, colour number and CGB attributes of
+// pixel px, py of the 256x256 background map at mapBase.
+func (l *lcd) bgPixel(bgp byte, mapBase uint16, px, py int) (index, colour, attr byte) {
+	entry := mapBase + uint16(py/8*32+px/8) - 0x8000
+	n := l.vram[0][entry]
+	if !l.cgb {
+		colour = tilePixel(&l.vram[0], tileAddr(l.lcdc, n), px%8, py%8)
+		return shade(bgp, colour), colour, 0
+	}
+	attr = l.vram[1][entry]
+	tx, ty := px%8, py%8
+	if attr&0x20 != 0 {
+		tx = 7 - tx
+	}
+	if attr&0x40 != 0 {
+		ty = 7 - ty
+	}
+	colour = tilePixel(&l.vram[attr>>3&1], tileAddr(l.lcdc, n), tx, ty)
+	return attr&7*4 + colour, colour, attr
+}
+
+func (gbc *GBC) drawSprites(img *image.Paletted, y int, l *lcd) {
+	height := 8
+	if l.lcdc&0x04 != 0 {
+		height = 16
+	}
+	// The first ten sprites on the line in OAM order are drawn. Where they
+	// overlap on a DMG the one with the smaller X wins, then the one earlier
+	// in OAM; on a CGB only OAM order counts.
+	var line [10]int
+	count := 0
+	for i := 0; i < 40 && count < len(line); i++ {
+		sy := int(gbc.Peek(0xFE00+uint16(i)*4)) - 16
+		if y >= sy && y < sy+height {
+			line[count] = i
+			count++
+		}
+	}
+	var owner [SCREEN_WIDTH]int
+	for i := count - 1; i >= 0; i-- {
+		oam := 0xFE00 + uint16(line[i])*4
+		sy := int(gbc.Peek(oam)) - 16
+		sx := int(gbc.Peek(oam+1)) - 8
+		tile, attr := gbc.Peek(oam+2), gbc.Peek(oam+3)
+		if height == 16 {
+			tile &= 0xFE
+		}
+		row := y - sy
+		if attr&0x40 != 0 {
+			row = height - 1 - row
+		}
+		palette := gbc.Peek(OBP0)
+		if attr&0x10 != 0 {
+			palette = gbc.Peek(OBP1)
+		}
+		vram := &l.vram[0]
+		if l.cgb {
+			vram = &l.vram[attr>>3&1]
+		}
+		for col := 0; col < 8; col++ {
+			x := sx + col
+			if x < 0 || x >= SCREEN_WIDTH {
+				continue
+			}
+			if !l.cgb && owner[x] != 0 && sx > owner[x]-1 {
+				continue
+			}
+			px := col
+			if attr&0x20 != 0 {
+				px = 7 - col
+			}
+			colour := tilePixel(vram, 0x8000+uint16(tile)*16, px, row)
+			if colour == 0 {
+				continue
+			}
+			behind := attr&0x80 != 0 || l.bg[x]&0x80 != 0
+			if behind && l.bg[x]&3 != 0 && (!l.cgb || l.lcdc&0x01 != 0) {
+				continue
+			}
+			owner[x] = sx + 1
+			if l.cgb {
+				img.SetColorIndex(x, y, 32+attr&7*4+colour)
+			} else {
+				img.SetColorIndex(x, y, shade(palette, colour))
+			}
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package hardware
+
+// SerialPeer is whatever is plugged into the other end of the link cable.
+type SerialPeer interface {
+	// Transfer shifts out one byte and returns the byte shifted in.
+	Transfer(out byte) byte
+}
+
+func (gbc *GBC) serialTransfer() {
+	if gbc.MMU.Read(0xFF02) != 0x81 {
+		return
+	}
+	in := byte(0xFF)
+	if gbc.serial != nil {
+		in = gbc.serial.Transfer(gbc.MMU.Read(0xFF01))
+	}
+	gbc.MMU.Write(0xFF01, in)
+	gbc.MMU.Write(0xFF02, 0x00)
+	gbc.Poke(0xFF0F, gbc.MMU.Read(0xFF0F)|0x08)
+}
+
+// SetSerial plugs peer into the link port, replacing whatever was there.
+func (gbc *GBC) SetSerial(peer SerialPeer) { gbc.serial = peer }
+
+// Serial returns the peer plugged into the link port, or nil.
+func (gbc *GBC) Serial() SerialPeer { return gbc.serial }
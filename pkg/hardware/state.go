@@ -0,0 +1,431 @@
+package hardware
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// A save state is STATE_MAGIC, a little-endian uint16 version and then a
+// sequence of chunks ending with an empty "END " chunk or the end of the
+// file. Each chunk is a four byte id, a little-endian uint32 length and that
+// many bytes of payload. Loaders skip chunks they don't know and leave
+// anything without a chunk at its current value, so components can be added
+// without invalidating older states.
+const (
+	STATE_MAGIC   = "GBCSTATE"
+	STATE_VERSION = 1
+)
+
+var (
+	ErrNotState       = errors.New("not a save state")
+	ErrStateVersion   = errors.New("save state is from a newer version")
+	ErrStateCartridge = errors.New("save state is for a different cartridge")
+)
+
+// StateComponent is implemented by parts of the machine that keep state
+// outside the CPU, the MBC registers and memory, such as a cartridge clock.
+// Each one is saved in its own chunk. None are built in: this tree has no
+// timer, PPU, APU or DMA that runs between instructions, only their IO
+// registers, which the MEM chunk holds. Whichever of them grows state of
+// its own should save it as a component.
+type StateComponent interface {
+	StateID() [4]byte
+	SaveState(w io.Writer) error
+	LoadState(r io.Reader) error
+}
+
+// AddStateComponent includes c in save states.
+func (gbc *GBC) AddStateComponent(c StateComponent) {
+	gbc.components = append(gbc.components, c)
+}
+
+// Component returns the state component with the given id, or nil if there
+// isn't one.
+func (gbc *GBC) Component(id [4]byte) StateComponent {
+	for _, c := range gbc.components {
+		if c.StateID() == id {
+			return c
+		}
+	}
+	return nil
+}
+
+var (
+	chunkCPU  = [4]byte{'C', 'P', 'U', ' '}
+	chunkCart = [4]byte{'C', 'A', 'R', 'T'}
+	chunkMBC  = [4]byte{'M', 'B', 'C', ' '}
+	chunkBoot = [4]byte{'B', 'O', 'O', 'T'}
+	chunkMem  = [4]byte{'M', 'E', 'M', ' '}
+	chunkVRAM = [4]byte{'V', 'R', 'A', 'M'}
+	chunkWRAM = [4]byte{'W', 'R', 'A', 'M'}
+	chunkPal  = [4]byte{'P', 'A', 'L', ' '}
+	chunkSRAM = [4]byte{'S', 'R', 'A', 'M'}
+	chunkEnd  = [4]byte{'E', 'N', 'D', ' '}
+)
+
+type cpuState struct {
+	REG        [8]byte
+	SP         uint16
+	PC         uint16
+	IME        bool
+	PendingIME bool
+	Halted     bool
+	Stopped    bool
+	CurrOP     byte
+	CurrPC     uint16
+	Cycles     uint64
+	ROMBank    uint16
+}
+
+// mbcState is the MBC's banking registers as trackBank sees them. ROMBank
+// is also in cpuState for states from before this chunk existed.
+type mbcState struct {
+	ROMBank    uint16
+	RAMBank    byte
+	RAMEnabled bool
+	Mode       byte
+}
+
+// memoryRegisters are the IO registers whose writes have side effects, such
+// as resetting DIV, starting a DMA or writing palette RAM, and so are not
+// written back when the address space is restored.
+var memoryRegisters = map[uint16]bool{
+	0xFF04: true, 0xFF46: true, 0xFF50: true, 0xFF55: true, 0xFF69: true, 0xFF6B: true,
+}
+
+// wramBanks returns work RAM without going through Poke: on a CGB the eight
+// 4KB banks in order, switching SVBK with MMU.Write and back, and on a DMG
+// the 8KB at C000.
+func (gbc *GBC) wramBanks() []byte {
+	if gbc.model != CGB {
+		wram := make([]byte, 0x2000)
+		for i := range wram {
+			wram[i] = gbc.MMU.Read(0xC000 + uint16(i))
+		}
+		return wram
+	}
+	wram := make([]byte, 8*0x1000)
+	for i := 0; i < 0x1000; i++ {
+		wram[i] = gbc.MMU.Read(0xC000 + uint16(i))
+	}
+	old := gbc.MMU.Read(0xFF70)
+	for bank := 1; bank < 8; bank++ {
+		gbc.MMU.Write(0xFF70, byte(bank))
+		for i := 0; i < 0x1000; i++ {
+			wram[bank*0x1000+i] = gbc.MMU.Read(0xD000 + uint16(i))
+		}
+	}
+	gbc.MMU.Write(0xFF70, old)
+	return wram
+}
+
+// loadWRAM puts back work RAM laid out as wramBanks returns it, dropping
+// any blocks decoded from it.
+func (gbc *GBC) loadWRAM(wram []byte) {
+	old := gbc.MMU.Read(0xFF70)
+	for i, v := range wram {
+		addr := 0xC000 + uint16(i)
+		if gbc.model == CGB && i >= 0x1000 {
+			if i%0x1000 == 0 {
+				gbc.MMU.Write(0xFF70, byte(i/0x1000))
+			}
+			addr = 0xD000 + uint16(i%0x1000)
+		}
+		if addr >= 0xE000 {
+			break
+		}
+		gbc.MMU.Write(addr, v)
+	}
+	if gbc.model == CGB {
+		gbc.MMU.Write(0xFF70, old)
+	}
+	if gbc.blocks != nil {
+		for page := uint16(0xC000); page < 0xE000; page += 0x100 {
+			gbc.blocks.invalidate(page)
+		}
+	}
+}
+
+// SaveState writes a snapshot of the whole machine to w.
+func (gbc *GBC) SaveState(w io.Writer) error {
+	if _, err := io.WriteString(w, STATE_MAGIC); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint16(STATE_VERSION)); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	chunk := func(id [4]byte, save func(w io.Writer) error) error {
+		buf.Reset()
+		if err := save(&buf); err != nil {
+			return fmt.Errorf("saving %s: %w", id[:], err)
+		}
+		if _, err := w.Write(id[:]); err != nil {
+			return err
+		}
+		if err := binary.Write(w, binary.LittleEndian, uint32(buf.Len())); err != nil {
+			return err
+		}
+		_, err := w.Write(buf.Bytes())
+		return err
+	}
+
+	if err := chunk(chunkCart, func(w io.Writer) error {
+		_, err := w.Write(gbc.cart.header[:])
+		return err
+	}); err != nil {
+		return err
+	}
+	if err := chunk(chunkCPU, func(w io.Writer) error {
+		return binary.Write(w, binary.LittleEndian, cpuState{
+			REG:        gbc.REG,
+			SP:         gbc.SP,
+			PC:         gbc.PC,
+			IME:        gbc.IME,
+			PendingIME: gbc.setPendingIME,
+			Halted:     gbc.halted,
+			Stopped:    gbc.stoped,
+			CurrOP:     gbc.currOP,
+			CurrPC:     gbc.currPC,
+			Cycles:     gbc.cycles,
+			ROMBank:    uint16(gbc.romBank),
+		})
+	}); err != nil {
+		return err
+	}
+	if err := chunk(chunkMBC, func(w io.Writer) error {
+		return binary.Write(w, binary.LittleEndian, mbcState{
+			ROMBank:    uint16(gbc.romBank),
+			RAMBank:    byte(gbc.ramBank),
+			RAMEnabled: gbc.ramEnabled,
+			Mode:       gbc.mbcMode,
+		})
+	}); err != nil {
+		return err
+	}
+	if gbc.bootROM != nil {
+		if err := chunk(chunkBoot, func(w io.Writer) error {
+			_, err := w.Write(gbc.bootROM)
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+	// MEM is the address space as the CPU sees it, for OAM, the IO
+	// registers and HRAM. Banked memory has chunks of its own.
+	if err := chunk(chunkMem, func(w io.Writer) error {
+		var mem [0x8000]byte
+		for i := range mem {
+			mem[i] = gbc.Peek(0x8000 + uint16(i))
+		}
+		_, err := w.Write(mem[:])
+		return err
+	}); err != nil {
+		return err
+	}
+	if err := chunk(chunkVRAM, func(w io.Writer) error {
+		for _, bank := range gbc.video.vram {
+			if _, err := w.Write(bank[:]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if err := chunk(chunkWRAM, func(w io.Writer) error {
+		_, err := w.Write(gbc.wramBanks())
+		return err
+	}); err != nil {
+		return err
+	}
+	if err := chunk(chunkPal, func(w io.Writer) error {
+		for _, pal := range gbc.video.palette {
+			if _, err := w.Write(pal[:]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if gbc.cart.ramSize > 0 {
+		if err := chunk(chunkSRAM, func(w io.Writer) error {
+			_, err := w.Write(gbc.SaveRAM())
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+	for _, c := range gbc.components {
+		if err := chunk(c.StateID(), c.SaveState); err != nil {
+			return err
+		}
+	}
+	return chunk(chunkEnd, func(w io.Writer) error { return nil })
+}
+
+// LoadState restores a snapshot written by SaveState. The state is read and
+// checked in full before anything is changed, and components already loaded
+// are put back if a later one fails, so a bad state leaves the machine as
+// it was.
+func (gbc *GBC) LoadState(r io.Reader) error {
+	var magic [len(STATE_MAGIC)]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil || string(magic[:]) != STATE_MAGIC {
+		return ErrNotState
+	}
+	var version uint16
+	if err := binary.Read(r, binary.LittleEndian, &version); err != nil {
+		return ErrNotState
+	}
+	if version > STATE_VERSION {
+		return ErrStateVersion
+	}
+
+	chunks := map[[4]byte][]byte{}
+	for {
+		var id [4]byte
+		if _, err := io.ReadFull(r, id[:]); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		var n uint32
+		if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+			return err
+		}
+		if id == chunkEnd {
+			break
+		}
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return err
+		}
+		chunks[id] = payload
+	}
+
+	if header, ok := chunks[chunkCart]; ok && !bytes.Equal(header, gbc.cart.header[:]) {
+		return ErrStateCartridge
+	}
+	var cpu cpuState
+	if payload, ok := chunks[chunkCPU]; ok {
+		if err := binary.Read(bytes.NewReader(payload), binary.LittleEndian, &cpu); err != nil {
+			return fmt.Errorf("loading CPU: %w", err)
+		}
+	}
+	mbc := mbcState{ROMBank: uint16(gbc.romBank), RAMBank: byte(gbc.ramBank), RAMEnabled: gbc.ramEnabled, Mode: gbc.mbcMode}
+	if _, ok := chunks[chunkCPU]; ok {
+		mbc.ROMBank = cpu.ROMBank
+	}
+	if payload, ok := chunks[chunkMBC]; ok {
+		if err := binary.Read(bytes.NewReader(payload), binary.LittleEndian, &mbc); err != nil {
+			return fmt.Errorf("loading MBC: %w", err)
+		}
+	}
+
+	wramSize := 0x2000
+	if gbc.model == CGB {
+		wramSize = 0x8000
+	}
+	for id, n := range map[[4]byte]int{chunkMem: 0x8000, chunkVRAM: 0x4000, chunkWRAM: wramSize, chunkPal: 0x80} {
+		if payload, ok := chunks[id]; ok && len(payload) != n {
+			return fmt.Errorf("loading %s: %w", id[:], ErrNotState)
+		}
+	}
+	if sram, ok := chunks[chunkSRAM]; ok && len(sram) != gbc.cart.ramSize {
+		return ErrSaveRAMSize
+	}
+	if err := gbc.loadComponents(chunks); err != nil {
+		return err
+	}
+
+	if sram, ok := chunks[chunkSRAM]; ok {
+		gbc.loadSaveRAM(sram)
+	}
+	vram, hasVRAM := chunks[chunkVRAM]
+	if hasVRAM {
+		gbc.loadVRAM(vram)
+	}
+	wram, hasWRAM := chunks[chunkWRAM]
+	if hasWRAM {
+		gbc.loadWRAM(wram)
+	}
+	if pal, ok := chunks[chunkPal]; ok {
+		gbc.loadPaletteRAM(false, pal[:0x40])
+		gbc.loadPaletteRAM(true, pal[0x40:])
+	}
+	if mem, ok := chunks[chunkMem]; ok {
+		restore := func(from, to int) {
+			for addr := from; addr < to; addr++ {
+				if !memoryRegisters[uint16(addr)] {
+					gbc.Poke(uint16(addr), mem[addr-0x8000])
+				}
+			}
+		}
+		// States from before the banked chunks only have the banks the
+		// CPU could see.
+		if !hasVRAM {
+			restore(0x8000, 0xA000)
+		}
+		if !hasWRAM {
+			restore(0xC000, 0xE000)
+		}
+		restore(0xFE00, 0xFF00)
+		restore(0xFF80, 0xFFFF)
+		// The IO registers go last, so VBK, SVBK and the palette indexes
+		// end up as saved after the loads above have used them.
+		restore(0xFF00, 0xFF80)
+		restore(0xFFFF, 0x10000)
+	}
+	if _, ok := chunks[chunkCPU]; ok {
+		gbc.REG = cpu.REG
+		gbc.SP = cpu.SP
+		gbc.PC = cpu.PC
+		gbc.IME = cpu.IME
+		gbc.setPendingIME = cpu.PendingIME
+		gbc.halted = cpu.Halted
+		gbc.stoped = cpu.Stopped
+		gbc.currOP = cpu.CurrOP
+		gbc.currPC = cpu.CurrPC
+		gbc.cycles = cpu.Cycles
+	}
+	gbc.romBank, gbc.ramBank, gbc.ramEnabled, gbc.mbcMode = int(mbc.ROMBank), int(mbc.RAMBank), mbc.RAMEnabled, mbc.Mode
+	gbc.restoreBanking()
+	gbc.bootROM = nil
+	if boot, ok := chunks[chunkBoot]; ok {
+		gbc.bootROM = boot
+	}
+	return nil
+}
+
+// loadComponents loads each component that has a chunk. If one fails, those
+// already loaded are put back as they were.
+func (gbc *GBC) loadComponents(chunks map[[4]byte][]byte) error {
+	var loaded []StateComponent
+	var saved [][]byte
+	for _, c := range gbc.components {
+		id := c.StateID()
+		payload, ok := chunks[id]
+		if !ok {
+			continue
+		}
+		var old bytes.Buffer
+		if err := c.SaveState(&old); err != nil {
+			return fmt.Errorf("saving %s: %w", id[:], err)
+		}
+		if err := c.LoadState(bytes.NewReader(payload)); err != nil {
+			c.LoadState(bytes.NewReader(old.Bytes()))
+			for i, prev := range loaded {
+				prev.LoadState(bytes.NewReader(saved[i]))
+			}
+			return fmt.Errorf("loading %s: %w", id[:], err)
+		}
+		loaded = append(loaded, c)
+		saved = append(saved, old.Bytes())
+	}
+	return nil
+}
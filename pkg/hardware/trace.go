@@ -0,0 +1,173 @@
+package hardware
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+)
+
+// Tracer receives execution events from a GBC. With no tracer installed each
+// hook costs a single nil check.
+type Tracer interface {
+	// Instruction is called before the opcode at pc is executed.
+	Instruction(gbc *GBC, pc uint16, op byte)
+	// MemoryAccess is called for every bus read and write made by the CPU.
+	MemoryAccess(addr uint16, value byte, write bool)
+	// Interrupt is called when the CPU is dispatched to an interrupt vector.
+	Interrupt(vector uint16)
+}
+
+func WithTracer(t Tracer) Option {
+	return func(gbc *GBC) error {
+		gbc.tracer = t
+		return nil
+	}
+}
+
+// SetTracer replaces the installed tracer; nil disables tracing.
+func (gbc *GBC) SetTracer(t Tracer) { gbc.tracer = t }
+
+func (gbc *GBC) Tracer() Tracer { return gbc.tracer }
+
+type multiTracer []Tracer
+
+// MultiTracer fans every event out to each of ts in order.
+func MultiTracer(ts ...Tracer) Tracer {
+	return multiTracer(ts)
+}
+
+func (m multiTracer) Instruction(gbc *GBC, pc uint16, op byte) {
+	for _, t := range m {
+		t.Instruction(gbc, pc, op)
+	}
+}
+
+func (m multiTracer) MemoryAccess(addr uint16, value byte, write bool) {
+	for _, t := range m {
+		t.MemoryAccess(addr, value, write)
+	}
+}
+
+func (m multiTracer) Interrupt(vector uint16) {
+	for _, t := range m {
+		t.Interrupt(vector)
+	}
+}
+
+const hexDigits = "0123456789ABCDEF"
+
+func appendHex8(b []byte, v byte) []byte {
+	return append(b, hexDigits[v>>4], hexDigits[v&0xF])
+}
+
+func appendHex16(b []byte, v uint16) []byte {
+	return appendHex8(appendHex8(b, byte(v>>8)), byte(v))
+}
+
+// AppendDoctorLine appends the CPU state in Gameboy Doctor format, without a
+// trailing newline.
+func AppendDoctorLine(b []byte, gbc *GBC) []byte {
+	regs := [...]struct {
+		name string
+		r    REGISTER8
+	}{{"A: ", A}, {" F: ", F}, {" B: ", B}, {" C: ", C}, {" D: ", D}, {" E: ", E}, {" H: ", H}, {" L: ", L}}
+	for _, reg := range regs {
+		b = appendHex8(append(b, reg.name...), gbc.REG[reg.r])
+	}
+	b = appendHex16(append(b, " SP: "...), gbc.SP)
+	b = appendHex16(append(b, " PC: 00:"...), gbc.PC)
+	b = append(b, " ("...)
+	for i := uint16(0); i < 4; i++ {
+		if i > 0 {
+			b = append(b, ' ')
+		}
+		b = appendHex8(b, gbc.Peek(gbc.PC+i))
+	}
+	return append(b, ')')
+}
+
+// DoctorWriter is a Tracer that writes one Gameboy Doctor line per
+// instruction. Call Flush when done.
+type DoctorWriter struct {
+	// Names, if set, names the bank and address of each instruction, which
+	// is added to its line as a "; name" comment. Gameboy Doctor itself
+	// doesn't accept such lines.
+	Names func(bank int, addr uint16) (string, bool)
+
+	w   *bufio.Writer
+	buf []byte
+}
+
+func NewDoctorWriter(w io.Writer) *DoctorWriter {
+	return &DoctorWriter{w: bufio.NewWriter(w), buf: make([]byte, 0, 96)}
+}
+
+func (d *DoctorWriter) Instruction(gbc *GBC, pc uint16, op byte) {
+	d.buf = AppendDoctorLine(d.buf[:0], gbc)
+	if d.Names != nil {
+		if name, ok := d.Names(gbc.BankOf(pc), pc); ok {
+			d.buf = append(append(d.buf, " ; "...), name...)
+		}
+	}
+	d.w.Write(append(d.buf, '\n'))
+}
+
+func (d *DoctorWriter) MemoryAccess(addr uint16, value byte, write bool) {}
+func (d *DoctorWriter) Interrupt(vector uint16)                          {}
+func (d *DoctorWriter) Flush() error                                     { return d.w.Flush() }
+
+// Record tags used by BinaryWriter. Each record is a tag byte followed by a
+// little-endian payload:
+//
+//	TRACE_INSTRUCTION  pc u16, op u8, b c d e h l f a u8, sp u16  (13 bytes)
+//	TRACE_READ         addr u16, value u8                         (3 bytes)
+//	TRACE_WRITE        addr u16, value u8                         (3 bytes)
+//	TRACE_INTERRUPT    vector u16                                 (2 bytes)
+const (
+	TRACE_INSTRUCTION byte = 'I'
+	TRACE_READ        byte = 'R'
+	TRACE_WRITE       byte = 'W'
+	TRACE_INTERRUPT   byte = 'X'
+)
+
+// BinaryWriter is a Tracer that writes a compact binary record per event.
+// Memory records are only written when Memory is set. Call Flush when done.
+type BinaryWriter struct {
+	Memory bool
+	w      *bufio.Writer
+	buf    [14]byte
+}
+
+func NewBinaryWriter(w io.Writer) *BinaryWriter {
+	return &BinaryWriter{w: bufio.NewWriter(w)}
+}
+
+func (bw *BinaryWriter) Instruction(gbc *GBC, pc uint16, op byte) {
+	bw.buf[0] = TRACE_INSTRUCTION
+	binary.LittleEndian.PutUint16(bw.buf[1:], pc)
+	bw.buf[3] = op
+	copy(bw.buf[4:12], gbc.REG[:])
+	binary.LittleEndian.PutUint16(bw.buf[12:], gbc.SP)
+	bw.w.Write(bw.buf[:14])
+}
+
+func (bw *BinaryWriter) MemoryAccess(addr uint16, value byte, write bool) {
+	if !bw.Memory {
+		return
+	}
+	bw.buf[0] = TRACE_READ
+	if write {
+		bw.buf[0] = TRACE_WRITE
+	}
+	binary.LittleEndian.PutUint16(bw.buf[1:], addr)
+	bw.buf[3] = value
+	bw.w.Write(bw.buf[:4])
+}
+
+func (bw *BinaryWriter) Interrupt(vector uint16) {
+	bw.buf[0] = TRACE_INTERRUPT
+	binary.LittleEndian.PutUint16(bw.buf[1:], vector)
+	bw.w.Write(bw.buf[:3])
+}
+
+func (bw *BinaryWriter) Flush() error { return bw.w.Flush() }
@@ -0,0 +1,136 @@
+package hardware
+
+// videoMemory mirrors both banks of VRAM and the CGB palette RAM as Poke
+// writes them, so the screen, the viewers and save states can read them
+// without switching VBK or the palette index registers under the game. The
+// bank and palette index a write lands in are read from the MMU, so the
+// mirror can't drift from it.
+type videoMemory struct {
+	vram    [2][0x2000]byte
+	palette [2][0x40]byte
+}
+
+// seed copies what the MMU holds at power on. Nothing has run yet, so
+// switching banks behind Poke's back to read it can't be seen.
+func (v *videoMemory) seed(gbc *GBC) {
+	if gbc.model != CGB {
+		for i := range v.vram[0] {
+			v.vram[0][i] = gbc.MMU.Read(0x8000 + uint16(i))
+		}
+		return
+	}
+	old := gbc.MMU.Read(VBK)
+	for bank := range v.vram {
+		gbc.MMU.Write(VBK, byte(bank))
+		for i := range v.vram[bank] {
+			v.vram[bank][i] = gbc.MMU.Read(0x8000 + uint16(i))
+		}
+	}
+	gbc.MMU.Write(VBK, old)
+	for i, reg := range []uint16{BCPS, OCPS} {
+		old := gbc.MMU.Read(reg)
+		for n := range v.palette[i] {
+			gbc.MMU.Write(reg, byte(n))
+			v.palette[i][n] = gbc.MMU.Read(reg + 1)
+		}
+		gbc.MMU.Write(reg, old)
+	}
+}
+
+// vramBank returns the VRAM bank VBK selects.
+func (gbc *GBC) vramBank() int {
+	if gbc.model != CGB {
+		return 0
+	}
+	return int(gbc.MMU.Read(VBK) & 1)
+}
+
+// pokeVideo writes to VRAM or one of the CGB registers from HDMA5 to OCPD
+// and brings the mirror up to date with what the MMU did.
+func (gbc *GBC) pokeVideo(addr uint16, value byte) {
+	v := &gbc.video
+	switch {
+	case addr < 0xA000:
+		gbc.MMU.Write(addr, value)
+		v.vram[gbc.vramBank()][addr-0x8000] = gbc.MMU.Read(addr)
+	case gbc.model != CGB:
+		gbc.MMU.Write(addr, value)
+	case addr == 0xFF55:
+		// However the MMU carries out a VRAM DMA, the selected bank holds
+		// the result, so read it back rather than copy it here too.
+		gbc.MMU.Write(addr, value)
+		bank := gbc.vramBank()
+		for i := range v.vram[bank] {
+			v.vram[bank][i] = gbc.MMU.Read(0x8000 + uint16(i))
+		}
+	case addr == BCPS+1, addr == OCPS+1:
+		// The index has moved on by the time the write can be read back.
+		index := gbc.MMU.Read(addr-1) & 0x3F
+		gbc.MMU.Write(addr, value)
+		v.palette[(addr-BCPS)/2][index] = value
+	default:
+		gbc.MMU.Write(addr, value)
+	}
+}
+
+// PeekVRAM reads addr, 0x8000-0x9FFF, from VRAM bank 0 or 1 without
+// touching VBK. On a DMG bank 1 reads 0.
+func (gbc *GBC) PeekVRAM(bank int, addr uint16) byte {
+	return gbc.video.vram[bank&1][(addr-0x8000)&0x1FFF]
+}
+
+// PaletteRAM returns the 64 bytes of CGB background palette RAM, or with
+// sprites set those of the sprite palettes, without touching BCPS or OCPS.
+func (gbc *GBC) PaletteRAM(sprites bool) [0x40]byte {
+	if sprites {
+		return gbc.video.palette[1]
+	}
+	return gbc.video.palette[0]
+}
+
+// loadVRAM puts data, bank 0 followed on a CGB by bank 1, into VRAM and the
+// mirror. VBK is switched with MMU.Write and put back, so the game doesn't
+// see it change.
+func (gbc *GBC) loadVRAM(data []byte) {
+	old := gbc.MMU.Read(VBK)
+	for bank := range gbc.video.vram {
+		if bank > 0 && gbc.model != CGB {
+			break
+		}
+		if gbc.model == CGB {
+			gbc.MMU.Write(VBK, byte(bank))
+		}
+		for i := range gbc.video.vram[bank] {
+			if n := bank*0x2000 + i; n < len(data) {
+				gbc.MMU.Write(0x8000+uint16(i), data[n])
+				gbc.video.vram[bank][i] = data[n]
+			}
+		}
+	}
+	if gbc.model == CGB {
+		gbc.MMU.Write(VBK, old)
+	}
+}
+
+// loadPaletteRAM puts pal into the CGB background palette RAM, or with
+// sprites set the sprite palette RAM, and the mirror, leaving BCPS or OCPS
+// as it was.
+func (gbc *GBC) loadPaletteRAM(sprites bool, pal []byte) {
+	if gbc.model != CGB {
+		return
+	}
+	reg, i := uint16(BCPS), 0
+	if sprites {
+		reg, i = OCPS, 1
+	}
+	old := gbc.MMU.Read(reg)
+	for n, v := range pal {
+		if n >= len(gbc.video.palette[i]) {
+			break
+		}
+		gbc.MMU.Write(reg, byte(n))
+		gbc.MMU.Write(reg+1, v)
+		gbc.video.palette[i][n] = v
+	}
+	gbc.MMU.Write(reg, old)
+}
@@ -0,0 +1,185 @@
+package hardware
+
+import (
+	"image"
+	"image/color"
+)
+
+// The viewers below draw what is in VRAM, OAM and palette RAM now, for
+// debugging graphics. Like Screen they read banked VRAM and palette RAM
+// without switching banks, so they can be drawn while a game runs.
+
+// ViewportColour outlines the visible part of a tile map.
+var ViewportColour = color.RGBA{0xFF, 0x00, 0x00, 0xFF}
+
+// Tiles draws the 384 tiles of VRAM, 16 to a row in address order, with
+// each pixel set to its colour number 0-3 in the DMG palette. On a CGB the
+// tiles of bank 1 are drawn to the right of those of bank 0.
+func (gbc *GBC) Tiles() *image.Paletted {
+	cgb := gbc.model == CGB
+	vram := &gbc.video.vram
+	banks := 1
+	if cgb {
+		banks = 2
+	}
+	img := image.NewPaletted(image.Rect(0, 0, banks*128, 192), gbc.Palette())
+	for bank := 0; bank < banks; bank++ {
+		for t := 0; t < 384; t++ {
+			ox, oy := bank*128+t%16*8, t/16*8
+			for y := 0; y < 8; y++ {
+				for x := 0; x < 8; x++ {
+					img.SetColorIndex(ox+x, oy+y, tilePixel(&vram[bank], 0x8000+uint16(t)*16, x, y))
+				}
+			}
+		}
+	}
+	return img
+}
+
+// TileMap draws the whole 256x256 background map n, 0 for the one at
+// 0x9800 and 1 for the one at 0x9C00, using the tile data and palettes
+// selected now. The part SCX and SCY make visible is outlined in
+// ViewportColour, wrapping around the edges like the hardware does.
+func (gbc *GBC) TileMap(n int) *image.RGBA {
+	var l lcd
+	l.cgb = gbc.model == CGB
+	l.lcdc = gbc.Peek(LCDC)
+	l.vram = gbc.video.vram
+	colours := gbc.colours()
+	bgp := gbc.Peek(BGP)
+	base := uint16(0x9800 + n&1*0x400)
+
+	img := image.NewRGBA(image.Rect(0, 0, 256, 256))
+	for py := 0; py < 256; py++ {
+		for px := 0; px < 256; px++ {
+			index, _, _ := l.bgPixel(bgp, base, px, py)
+			img.Set(px, py, colours[index])
+		}
+	}
+	scx, scy := int(gbc.Peek(SCX)), int(gbc.Peek(SCY))
+	mark := func(x, y int) { img.Set(x&0xFF, y&0xFF, ViewportColour) }
+	for i := 0; i < SCREEN_WIDTH; i++ {
+		mark(scx+i, scy)
+		mark(scx+i, scy+SCREEN_HEIGHT-1)
+	}
+	for i := 0; i < SCREEN_HEIGHT; i++ {
+		mark(scx, scy+i)
+		mark(scx+SCREEN_WIDTH-1, scy+i)
+	}
+	return img
+}
+
+// Sprite is an entry of OAM.
+type Sprite struct {
+	Y, X, Tile, Attr byte
+}
+
+// OAM returns the 40 sprites as they are in OAM.
+func (gbc *GBC) OAM() (sprites [40]Sprite) {
+	for i := range sprites {
+		addr := 0xFE00 + uint16(i)*4
+		sprites[i] = Sprite{gbc.Peek(addr), gbc.Peek(addr + 1), gbc.Peek(addr + 2), gbc.Peek(addr + 3)}
+	}
+	return sprites
+}
+
+// OAMSheet draws the 40 sprites, 8 to a row in OAM order, each with the
+// tile, flips and palette it would be drawn with, 8x16 if LCDC selects tall
+// sprites. Colour 0 is transparent and cells are 1 pixel apart.
+func (gbc *GBC) OAMSheet() *image.RGBA {
+	cgb := gbc.model == CGB
+	vram := &gbc.video.vram
+	colours := gbc.colours()
+	height := 8
+	if gbc.Peek(LCDC)&0x04 != 0 {
+		height = 16
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 8*9-1, 5*(height+1)-1))
+	for i, s := range gbc.OAM() {
+		ox, oy := i%8*9, i/8*(height+1)
+		tile := s.Tile
+		if height == 16 {
+			tile &= 0xFE
+		}
+		bank := 0
+		if cgb {
+			bank = int(s.Attr >> 3 & 1)
+		}
+		for y := 0; y < height; y++ {
+			for x := 0; x < 8; x++ {
+				tx, ty := x, y
+				if s.Attr&0x20 != 0 {
+					tx = 7 - x
+				}
+				if s.Attr&0x40 != 0 {
+					ty = height - 1 - y
+				}
+				colour := tilePixel(&vram[bank], 0x8000+uint16(tile)*16, tx, ty)
+				if colour != 0 {
+					img.Set(ox+x, oy+y, colours[gbc.spriteIndex(s.Attr, colour)])
+				}
+			}
+		}
+	}
+	return img
+}
+
+// spriteIndex returns the index in colours of a sprite pixel.
+func (gbc *GBC) spriteIndex(attr, colour byte) byte {
+	if gbc.model == CGB {
+		return 32 + attr&7*4 + colour
+	}
+	if attr&0x10 != 0 {
+		return shade(gbc.Peek(OBP1), colour)
+	}
+	return shade(gbc.Peek(OBP0), colour)
+}
+
+// colours returns the colours the screen is drawn in: the DMG palette, or
+// the 64 colours of CGB palette RAM.
+func (gbc *GBC) colours() color.Palette {
+	if gbc.model == CGB {
+		return gbc.cgbColours(nil)
+	}
+	return gbc.Palette()
+}
+
+// PaletteSwatches draws a 16x16 square for each colour of each palette. On
+// a CGB the first row holds the eight background palettes, four colours
+// each, and the second the eight sprite palettes. On a DMG the rows are
+// BGP, OBP0 and OBP1 in the shades of the DMG palette.
+func (gbc *GBC) PaletteSwatches() *image.RGBA {
+	const size = 16
+	var rows [][]color.Color
+	if gbc.model == CGB {
+		colours := gbc.cgbColours(nil)
+		for i := 0; i < 2; i++ {
+			row := make([]color.Color, 32)
+			for n := range row {
+				row[n] = colours[i*32+n]
+			}
+			rows = append(rows, row)
+		}
+	} else {
+		shades := gbc.Palette()
+		for _, reg := range []uint16{BGP, OBP0, OBP1} {
+			p := gbc.Peek(reg)
+			row := make([]color.Color, 4)
+			for c := range row {
+				row[c] = shades[shade(p, byte(c))]
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, len(rows[0])*size, len(rows)*size))
+	for y, row := range rows {
+		for x, c := range row {
+			for i := 0; i < size*size; i++ {
+				img.Set(x*size+i%size, y*size+i/size, c)
+			}
+		}
+	}
+	return img
+}
@@ -0,0 +1,156 @@
+package hardware
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func sameColour(a, b color.Color) bool {
+	r1, g1, b1, a1 := a.RGBA()
+	r2, g2, b2, a2 := b.RGBA()
+	return r1 == r2 && g1 == g2 && b1 == b2 && a1 == a2
+}
+
+// vramGBC returns a DMG with tile 1 drawn as a row of colour 3, a row of
+// colour 1 and a single colour 3 pixel at the left of row 2.
+func vramGBC(t *testing.T) *GBC {
+	gbc, err := New(testROM(0x18, 0xFE))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range []byte{0xFF, 0xFF, 0xFF, 0x00, 0x80, 0x80} {
+		gbc.Poke(0x8010+uint16(i), v)
+	}
+	gbc.Poke(BGP, 0xE4)
+	gbc.Poke(OBP0, 0xE4)
+	gbc.Poke(OBP1, 0x1B)
+	return gbc
+}
+
+func TestTiles(t *testing.T) {
+	img := vramGBC(t).Tiles()
+	if img.Bounds() != image.Rect(0, 0, 128, 192) {
+		t.Fatalf("bounds %v, want 128x192", img.Bounds())
+	}
+	for _, p := range []struct {
+		x, y int
+		want uint8
+	}{
+		{8, 0, 3}, {15, 0, 3}, {8, 1, 1}, {8, 2, 3}, {9, 2, 0}, {7, 0, 0}, {16, 0, 0},
+	} {
+		if got := img.ColorIndexAt(p.x, p.y); got != p.want {
+			t.Errorf("pixel %d,%d is colour %d, want %d", p.x, p.y, got, p.want)
+		}
+	}
+}
+
+func TestTileMap(t *testing.T) {
+	gbc := vramGBC(t)
+	gbc.Poke(LCDC, 0x91)
+	gbc.Poke(0x9800+2*32+3, 1) // tile 1 at column 3, row 2
+	// The viewport runs off the right and bottom edges and wraps.
+	gbc.Poke(SCX, 200)
+	gbc.Poke(SCY, 100)
+	img := gbc.TileMap(0)
+
+	for _, p := range []struct {
+		x, y int
+		want color.Color
+	}{
+		{24, 16, DMGShades[3]},
+		{31, 17, DMGShades[1]},
+		{23, 16, DMGShades[0]},
+		// Top and bottom edges, either side of the wrap.
+		{200, 100, ViewportColour},
+		{255, 100, ViewportColour},
+		{0, 100, ViewportColour},
+		{103, 243, ViewportColour},
+		{150, 100, DMGShades[0]},
+		// Left edge at SCX and right edge at (SCX+159)&0xFF.
+		{200, 150, ViewportColour},
+		{103, 150, ViewportColour},
+		{104, 150, DMGShades[0]},
+		{0, 150, DMGShades[0]},
+		{200, 244, DMGShades[0]},
+	} {
+		if got := img.At(p.x, p.y); !sameColour(got, p.want) {
+			t.Errorf("pixel %d,%d is %v, want %v", p.x, p.y, got, p.want)
+		}
+	}
+}
+
+func TestOAMSheet(t *testing.T) {
+	gbc := vramGBC(t)
+	gbc.Poke(LCDC, 0x93)
+	for i, v := range []byte{
+		16, 8, 1, 0x20, // sprite 0: tile 1 flipped across
+		16, 8, 1, 0x10, // sprite 1: tile 1 in OBP1
+	} {
+		gbc.Poke(0xFE00+uint16(i), v)
+	}
+	img := gbc.OAMSheet()
+	if img.Bounds() != image.Rect(0, 0, 71, 44) {
+		t.Fatalf("bounds %v, want 71x44", img.Bounds())
+	}
+	transparent := color.RGBA{}
+	for _, p := range []struct {
+		x, y int
+		want color.Color
+	}{
+		{0, 0, DMGShades[3]},
+		{0, 1, DMGShades[1]},
+		{7, 2, DMGShades[3]},
+		{0, 2, transparent},
+		{8, 0, transparent},
+		{9, 0, DMGShades[0]},
+		{9, 1, DMGShades[2]},
+		{9, 2, DMGShades[0]},
+		{18, 0, transparent},
+	} {
+		if got := img.At(p.x, p.y); !sameColour(got, p.want) {
+			t.Errorf("pixel %d,%d is %v, want %v", p.x, p.y, got, p.want)
+		}
+	}
+}
+
+func TestPaletteSwatches(t *testing.T) {
+	img := vramGBC(t).PaletteSwatches()
+	if img.Bounds() != image.Rect(0, 0, 64, 48) {
+		t.Fatalf("DMG bounds %v, want 64x48", img.Bounds())
+	}
+	for _, p := range []struct {
+		x, y int
+		want color.Color
+	}{
+		{0, 0, DMGShades[0]}, {63, 15, DMGShades[3]}, {16, 16, DMGShades[1]}, {0, 32, DMGShades[3]}, {63, 47, DMGShades[0]},
+	} {
+		if got := img.At(p.x, p.y); !sameColour(got, p.want) {
+			t.Errorf("DMG pixel %d,%d is %v, want %v", p.x, p.y, got, p.want)
+		}
+	}
+
+	rom := testROM(0x18, 0xFE)
+	rom[0x143] = 0x80
+	gbc, err := New(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gbc.Poke(BCPS, 0x80|(1*4+2)*2) // background palette 1 colour 2
+	gbc.Poke(BCPS+1, 0x1F)         // red
+	gbc.Poke(BCPS+1, 0x00)
+	gbc.Poke(OCPS, 0x80|7*4*2+6) // sprite palette 7 colour 3
+	gbc.Poke(OCPS+1, 0x00)
+	gbc.Poke(OCPS+1, 0x7C) // blue
+	img = gbc.PaletteSwatches()
+	if img.Bounds() != image.Rect(0, 0, 512, 32) {
+		t.Fatalf("CGB bounds %v, want 512x32", img.Bounds())
+	}
+	red, blue := color.RGBA{0xFF, 0, 0, 0xFF}, color.RGBA{0, 0, 0xFF, 0xFF}
+	if got := img.At(6*16, 0); !sameColour(got, red) {
+		t.Errorf("background palette 1 colour 2 is %v, want %v", got, red)
+	}
+	if got := img.At(31*16+15, 31); !sameColour(got, blue) {
+		t.Errorf("sprite palette 7 colour 3 is %v, want %v", got, blue)
+	}
+}
@@ -0,0 +1,119 @@
+// Package headless runs a GBC without a frontend until an exit condition is
+// met. It is the engine behind cmd/gbheadless and the test ROM suite.
+package headless
+
+import (
+	"bytes"
+
+	"github.com/ifamakes/emu/pkg/hardware"
+)
+
+type Reason int
+
+const (
+	REASON_FRAMES      Reason = iota // ran Config.Frames frames
+	REASON_CYCLES                    // ran Config.Cycles cycles
+	REASON_PC                        // reached Config.UntilPC
+	REASON_LDBB                      // about to execute LD B,B
+	REASON_SERIAL_PASS               // serial output contained Config.SerialPass
+	REASON_SERIAL_FAIL               // serial output contained Config.SerialFail
+	REASON_ERROR                     // the GBC failed, see the error Run returns
+)
+
+func (r Reason) String() string {
+	return [...]string{"frame limit", "cycle limit", "reached PC", "LD B,B", "serial pass", "serial fail", "error"}[r]
+}
+
+// Config says when Run stops. Zero values disable a condition; Run stops at
+// whichever enabled condition is met first.
+type Config struct {
+	Frames  uint64
+	Cycles  uint64
+	UntilPC *uint16
+	// LDBB stops before the LD B,B software breakpoint mooneye and other
+	// test ROMs execute when they finish.
+	LDBB       bool
+	SerialPass []byte
+	SerialFail []byte
+}
+
+type Result struct {
+	Reason Reason
+	Frames uint64
+	Cycles uint64
+	// Serial is everything the ROM sent over the link port.
+	Serial []byte
+}
+
+// serialLog records the bytes sent over the link port and forwards them to
+// the peer that was plugged in before.
+type serialLog struct {
+	out  []byte
+	next hardware.SerialPeer
+}
+
+func (s *serialLog) Transfer(out byte) byte {
+	s.out = append(s.out, out)
+	if s.next != nil {
+		return s.next.Transfer(out)
+	}
+	return 0xFF
+}
+
+// Run steps gbc until a condition in c is met. With no limit set it runs
+// until another condition stops it, which may be forever.
+func Run(gbc *hardware.GBC, c Config) (Result, error) {
+	serial := &serialLog{next: gbc.Serial()}
+	gbc.SetSerial(serial)
+	defer gbc.SetSerial(serial.next)
+
+	start := gbc.Cycles()
+	var frameEnd, cycleEnd uint64
+	if c.Frames > 0 {
+		frameEnd = (gbc.FrameCount() + c.Frames) * hardware.CYCLES_PER_FRAME
+	}
+	if c.Cycles > 0 {
+		cycleEnd = start + c.Cycles
+	}
+	checked := 0
+
+	result := func(r Reason) Result {
+		return Result{
+			Reason: r,
+			Frames: (gbc.Cycles() - start) / hardware.CYCLES_PER_FRAME,
+			Cycles: gbc.Cycles() - start,
+			Serial: serial.out,
+		}
+	}
+	for {
+		switch {
+		case frameEnd > 0 && gbc.Cycles() >= frameEnd:
+			return result(REASON_FRAMES), nil
+		case cycleEnd > 0 && gbc.Cycles() >= cycleEnd:
+			return result(REASON_CYCLES), nil
+		case c.UntilPC != nil && gbc.PC == *c.UntilPC:
+			return result(REASON_PC), nil
+		case c.LDBB && gbc.Peek(gbc.PC) == 0x40:
+			return result(REASON_LDBB), nil
+		}
+		if len(serial.out) != checked {
+			checked = len(serial.out)
+			if len(c.SerialFail) > 0 && bytes.Contains(serial.out, c.SerialFail) {
+				return result(REASON_SERIAL_FAIL), nil
+			}
+			if len(c.SerialPass) > 0 && bytes.Contains(serial.out, c.SerialPass) {
+				return result(REASON_SERIAL_PASS), nil
+			}
+		}
+		if err := gbc.Step(); err != nil {
+			return result(REASON_ERROR), err
+		}
+	}
+}
+
+// MooneyePassed reports whether the registers hold the Fibonacci numbers
+// 3, 5, 8, 13, 21, 34 in B, C, D, E, H, L, which mooneye test ROMs load
+// before LD B,B when they pass. Failing ROMs load 0x42 into all of them.
+func MooneyePassed(gbc *hardware.GBC) bool {
+	return gbc.REG == [8]byte{3, 5, 8, 13, 21, 34, gbc.REG[hardware.REG_F], gbc.REG[hardware.REG_A]}
+}
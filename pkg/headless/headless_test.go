@@ -0,0 +1,25 @@
+package headless
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ifamakes/emu/pkg/hardware"
+)
+
+func TestRunError(t *testing.T) {
+	rom := make([]byte, 0x8000)
+	copy(rom[0x100:], []byte{0x18, 0xFE})
+	log := strings.NewReader("A:00 F:00 B:00 C:00 D:00 E:00 H:00 L:00 SP:0000 PC:0000 PCMEM:00,00,00,00\n")
+	gbc, err := hardware.New(rom, hardware.WithTraceCompare(log))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Run(gbc, Config{Cycles: 1000})
+	if err == nil || res.Reason != REASON_ERROR {
+		t.Errorf("Run stopped with %v, %v, want REASON_ERROR and the trace mismatch", res.Reason, err)
+	}
+	if res.Reason.String() != "error" {
+		t.Errorf("REASON_ERROR is %q", res.Reason)
+	}
+}
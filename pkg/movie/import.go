@@ -0,0 +1,236 @@
+package movie
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/ifamakes/emu/pkg/hardware"
+)
+
+var (
+	ErrUnsupported = errors.New("movie starts from a save state or resets the console")
+	ErrFormat      = errors.New("unknown movie format")
+)
+
+// Import reads a movie in our own format or one of the formats other
+// emulators record TAS runs in, picked by the file extension: .bk2
+// (BizHawk, including runs on its Gambatte core) or .vbm (VisualBoyAdvance).
+// rom is the image the movie was recorded on; it is checked against the
+// movie where the format records a checksum and its hash is stored in the
+// result.
+func Import(name string, data []byte, rom []byte) (*Movie, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".bk2":
+		return ImportBK2(bytes.NewReader(data), int64(len(data)), rom)
+	case ".vbm":
+		return ImportVBM(data, rom)
+	}
+	if bytes.HasPrefix(data, []byte(MOVIE_MAGIC)) {
+		return Read(bytes.NewReader(data))
+	}
+	return nil, ErrFormat
+}
+
+// imported returns an empty movie for rom with the model its header asks
+// for, for formats that don't record one.
+func imported(rom []byte) (*Movie, error) {
+	gbc, err := hardware.New(rom)
+	if err != nil {
+		return nil, err
+	}
+	return &Movie{ROMHash: sha256.Sum256(rom), Model: gbc.Model()}, nil
+}
+
+// bk2Buttons maps the names in a BizHawk LogKey to our buttons. Player
+// prefixes such as "P1 " are stripped first.
+var bk2Buttons = map[string]hardware.BUTTON{
+	"Up":     hardware.BUTTON_UP,
+	"Down":   hardware.BUTTON_DOWN,
+	"Left":   hardware.BUTTON_LEFT,
+	"Right":  hardware.BUTTON_RIGHT,
+	"A":      hardware.BUTTON_A,
+	"B":      hardware.BUTTON_B,
+	"Select": hardware.BUTTON_SELECT,
+	"Start":  hardware.BUTTON_START,
+}
+
+// ImportBK2 reads a BizHawk movie, a zip archive holding a Header.txt and an
+// Input Log.txt with one line per frame. Each line has one character per
+// button named in its LogKey, '.' when released.
+func ImportBK2(r io.ReaderAt, size int64, rom []byte) (*Movie, error) {
+	z, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string][]byte{}
+	for _, f := range z.File {
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		files[f.Name], err = ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	header, ok := files["Header.txt"]
+	if !ok {
+		return nil, fmt.Errorf("bk2: no Header.txt")
+	}
+	log, ok := files["Input Log.txt"]
+	if !ok {
+		return nil, fmt.Errorf("bk2: no Input Log.txt")
+	}
+
+	m, err := imported(rom)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(header), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "SHA1":
+			sum := sha1.Sum(rom)
+			if !strings.EqualFold(fields[1], hex.EncodeToString(sum[:])) {
+				return nil, ErrROMMismatch
+			}
+		case "Platform":
+			switch fields[1] {
+			case "GB":
+				m.Model = hardware.DMG
+			case "GBC":
+				m.Model = hardware.CGB
+			}
+		case "StartsFromSavestate":
+			if fields[1] == "True" {
+				return nil, ErrUnsupported
+			}
+		case "StartsFromSaveRam":
+			if fields[1] == "True" {
+				m.SRAM = files["SaveRam"]
+			}
+		}
+	}
+
+	var keys []string
+	s := bufio.NewScanner(bytes.NewReader(log))
+	for s.Scan() {
+		line := strings.TrimRight(s.Text(), "\r")
+		switch {
+		case strings.HasPrefix(line, "LogKey:"):
+			keys = nil
+			for _, key := range strings.Split(strings.TrimPrefix(line, "LogKey:"), "|") {
+				key = strings.TrimPrefix(key, "#")
+				if key == "" {
+					continue
+				}
+				if i := strings.IndexByte(key, ' '); i >= 0 && key[0] == 'P' {
+					key = key[i+1:]
+				}
+				keys = append(keys, key)
+			}
+		case strings.HasPrefix(line, "|"):
+			inputs := strings.ReplaceAll(line, "|", "")
+			var b hardware.BUTTON
+			for i, c := range inputs {
+				if c == '.' || c == ' ' || i >= len(keys) {
+					continue
+				}
+				if keys[i] == "Power" && len(m.Input) > 0 {
+					return nil, ErrUnsupported
+				}
+				b |= bk2Buttons[keys[i]]
+			}
+			m.Input = append(m.Input, b)
+		}
+	}
+	return m, s.Err()
+}
+
+// vbmButtons is the order of the buttons in a VBM frame, from bit 0 up.
+var vbmButtons = []hardware.BUTTON{
+	hardware.BUTTON_A, hardware.BUTTON_B, hardware.BUTTON_SELECT, hardware.BUTTON_START,
+	hardware.BUTTON_RIGHT, hardware.BUTTON_LEFT, hardware.BUTTON_UP, hardware.BUTTON_DOWN,
+}
+
+const (
+	vbmReset      = 0x0C00
+	vbmFromState  = 0x01
+	vbmFromSRAM   = 0x02
+	vbmSystemCGB  = 0x02
+	vbmHeaderSize = 0x100
+)
+
+// ImportVBM reads a VisualBoyAdvance movie: a 256 byte header followed by
+// two little-endian bytes per frame for each connected controller. Only the
+// first controller is used.
+func ImportVBM(data []byte, rom []byte) (*Movie, error) {
+	if len(data) < vbmHeaderSize || string(data[:4]) != "VBM\x1A" {
+		return nil, ErrFormat
+	}
+	le := binary.LittleEndian
+	frames := int(le.Uint32(data[0x0C:]))
+	start, controllers, system := data[0x14], data[0x15], data[0x16]
+	if start&vbmFromState != 0 {
+		return nil, ErrUnsupported
+	}
+	if len(rom) > 0x14D && data[0x31] != rom[0x14D] {
+		return nil, ErrROMMismatch
+	}
+
+	m, err := imported(rom)
+	if err != nil {
+		return nil, err
+	}
+	m.Model = hardware.DMG
+	if system&vbmSystemCGB != 0 {
+		m.Model = hardware.CGB
+	}
+	sramOffset, inputOffset := int(le.Uint32(data[0x38:])), int(le.Uint32(data[0x3C:]))
+	if start&vbmFromSRAM != 0 && sramOffset < inputOffset && inputOffset <= len(data) {
+		m.SRAM = append([]byte(nil), data[sramOffset:inputOffset]...)
+	}
+
+	stride := 0
+	for i := uint(0); i < 4; i++ {
+		if controllers&(1<<i) != 0 {
+			stride += 2
+		}
+	}
+	if stride == 0 {
+		stride = 2
+	}
+	for i := 0; i < frames; i++ {
+		off := inputOffset + i*stride
+		if off+2 > len(data) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		raw := le.Uint16(data[off:])
+		if raw&vbmReset != 0 && i > 0 {
+			return nil, ErrUnsupported
+		}
+		var b hardware.BUTTON
+		for bit, button := range vbmButtons {
+			if raw&(1<<uint(bit)) != 0 {
+				b |= button
+			}
+		}
+		m.Input = append(m.Input, b)
+	}
+	return m, nil
+}
@@ -0,0 +1,231 @@
+// Package movie records and replays joypad input one frame at a time.
+//
+// A movie holds everything needed to reproduce a session from power on: the
+// hash of the ROM, the model, the boot ROM, the starting cartridge RAM, and
+// the buttons held during each frame. Replaying it on the same ROM runs the
+// exact same instructions, so the final screen hash can be compared against
+// the one stored when it was recorded.
+//
+// The emulator has no MBC3 real-time clock, so a movie records none. A clock
+// that keeps time would have to be saved here, and MOVIE_VERSION bumped, for
+// games that read it to replay the same way.
+package movie
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ifamakes/emu/pkg/hardware"
+)
+
+const (
+	MOVIE_MAGIC   = "GBCMOVIE"
+	MOVIE_VERSION = 1
+)
+
+var (
+	ErrNotMovie    = errors.New("not a movie")
+	ErrVersion     = errors.New("movie is from a newer version")
+	ErrROMMismatch = errors.New("movie was recorded on a different ROM")
+	ErrStarted     = errors.New("recording must start at power on")
+)
+
+type Movie struct {
+	ROMHash [sha256.Size]byte
+	Model   hardware.MODEL
+	BootROM []byte
+	SRAM    []byte
+	// Input holds the buttons held during each frame.
+	Input []hardware.BUTTON
+	// ScreenHash is the ScreenHash after the last frame, or zero if the
+	// recording was never finished.
+	ScreenHash [sha256.Size]byte
+}
+
+// Record starts a movie of gbc, which must not have run yet. rom is the
+// image gbc was created from.
+func Record(gbc *hardware.GBC, rom []byte) (*Movie, error) {
+	if gbc.Cycles() != 0 {
+		return nil, ErrStarted
+	}
+	m := &Movie{
+		ROMHash: sha256.Sum256(rom),
+		Model:   gbc.Model(),
+		BootROM: append([]byte(nil), gbc.BootROM()...),
+		SRAM:    gbc.SaveRAM(),
+	}
+	return m, nil
+}
+
+// Frame records the buttons gbc holds for the coming frame. Call it once
+// before each RunFrame.
+func (m *Movie) Frame(gbc *hardware.GBC) {
+	m.Input = append(m.Input, gbc.Buttons())
+}
+
+// Finish stores the screen hash of gbc at the end of the recording.
+func (m *Movie) Finish(gbc *hardware.GBC) {
+	m.ScreenHash = ScreenHash(gbc)
+}
+
+// Start creates a GBC for rom in the state the movie was recorded from. opts
+// are applied after the movie's own, so things like tracers and serial peers
+// can be attached.
+func (m *Movie) Start(rom []byte, opts ...hardware.Option) (*hardware.GBC, error) {
+	if sha256.Sum256(rom) != m.ROMHash {
+		return nil, ErrROMMismatch
+	}
+	all := []hardware.Option{hardware.WithModel(m.Model)}
+	if len(m.BootROM) > 0 {
+		all = append(all, hardware.WithBootROM(m.BootROM))
+	}
+	if len(m.SRAM) > 0 {
+		all = append(all, hardware.WithSaveRAM(m.SRAM))
+	}
+	gbc, err := hardware.New(rom, append(all, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	return gbc, nil
+}
+
+// Play replays the whole movie on rom and returns the machine as it is after
+// the last frame.
+func (m *Movie) Play(rom []byte, opts ...hardware.Option) (*hardware.GBC, error) {
+	gbc, err := m.Start(rom, opts...)
+	if err != nil {
+		return nil, err
+	}
+	for i, b := range m.Input {
+		gbc.SetButtons(b)
+		if err := gbc.RunFrame(); err != nil {
+			return gbc, fmt.Errorf("frame %d: %w", i, err)
+		}
+	}
+	return gbc, nil
+}
+
+// ScreenHash hashes the picture gbc.Screen draws: the shade of each pixel on
+// a DMG, and on a CGB its palette index and the 64 colours of palette RAM.
+// VRAM the screen doesn't show and the palette chosen with WithPalette
+// don't change it.
+func ScreenHash(gbc *hardware.GBC) [sha256.Size]byte {
+	img := gbc.Screen()
+	h := sha256.New()
+	h.Write(img.Pix)
+	if gbc.Model() == hardware.CGB {
+		for _, c := range img.Palette {
+			r, g, b, _ := c.RGBA()
+			h.Write([]byte{byte(r >> 8), byte(g >> 8), byte(b >> 8)})
+		}
+	}
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+// WriteTo writes the movie as MOVIE_MAGIC, a little-endian uint16 version,
+// the header fields, and one byte of buttons per frame.
+func (m *Movie) WriteTo(w io.Writer) (int64, error) {
+	bw := &countingWriter{w: bufio.NewWriter(w)}
+	bw.Write([]byte(MOVIE_MAGIC))
+	binary.Write(bw, binary.LittleEndian, uint16(MOVIE_VERSION))
+	bw.Write(m.ROMHash[:])
+	bw.Write([]byte{byte(m.Model)})
+	for _, b := range [][]byte{m.BootROM, m.SRAM} {
+		binary.Write(bw, binary.LittleEndian, uint32(len(b)))
+		bw.Write(b)
+	}
+	bw.Write(m.ScreenHash[:])
+	binary.Write(bw, binary.LittleEndian, uint32(len(m.Input)))
+	for _, b := range m.Input {
+		bw.Write([]byte{byte(b)})
+	}
+	if err := bw.w.Flush(); err != nil {
+		return bw.n, err
+	}
+	return bw.n, bw.err
+}
+
+// Read reads a movie written by WriteTo.
+func Read(r io.Reader) (*Movie, error) {
+	br := bufio.NewReader(r)
+	var magic [len(MOVIE_MAGIC)]byte
+	if _, err := io.ReadFull(br, magic[:]); err != nil || string(magic[:]) != MOVIE_MAGIC {
+		return nil, ErrNotMovie
+	}
+	var version uint16
+	if err := binary.Read(br, binary.LittleEndian, &version); err != nil {
+		return nil, ErrNotMovie
+	}
+	if version > MOVIE_VERSION {
+		return nil, ErrVersion
+	}
+
+	m := &Movie{}
+	if _, err := io.ReadFull(br, m.ROMHash[:]); err != nil {
+		return nil, err
+	}
+	model, err := br.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	m.Model = hardware.MODEL(model)
+	for _, b := range []*[]byte{&m.BootROM, &m.SRAM} {
+		if *b, err = readBlob(br); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := io.ReadFull(br, m.ScreenHash[:]); err != nil {
+		return nil, err
+	}
+	input, err := readBlob(br)
+	if err != nil {
+		return nil, err
+	}
+	m.Input = make([]hardware.BUTTON, len(input))
+	for i, b := range input {
+		m.Input[i] = hardware.BUTTON(b)
+	}
+	return m, nil
+}
+
+func readBlob(r io.Reader) ([]byte, error) {
+	var n uint32
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	// The length is only as good as the file, so the buffer grows with
+	// the bytes that are really there rather than being allocated up front.
+	var b bytes.Buffer
+	if _, err := io.CopyN(&b, r, int64(n)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	if err != nil && c.err == nil {
+		c.err = err
+	}
+	return n, err
+}
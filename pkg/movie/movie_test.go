@@ -0,0 +1,180 @@
+package movie
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/ifamakes/emu/pkg/hardware"
+)
+
+func TestRecordAndPlay(t *testing.T) {
+	// Loop storing P1 to WRAM: LD A, (FF00); LD (C000), A; JR -8
+	rom := make([]byte, 0x8000)
+	copy(rom[0x100:], []byte{0xF0, 0x00, 0xEA, 0x00, 0xC0, 0x18, 0xF9})
+	gbc, err := hardware.New(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := Record(gbc, rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range []hardware.BUTTON{0, hardware.BUTTON_A, hardware.BUTTON_A | hardware.BUTTON_START, 0} {
+		gbc.SetButtons(b)
+		m.Frame(gbc)
+		if err := gbc.RunFrame(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.Finish(gbc)
+
+	var file bytes.Buffer
+	if _, err := m.WriteTo(&file); err != nil {
+		t.Fatal(err)
+	}
+	m, err = Read(&file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay, err := m.Play(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replay.Register != gbc.Register || replay.Cycles() != gbc.Cycles() {
+		t.Errorf("replay ended at %+v after %d cycles, want %+v after %d", replay.Register, replay.Cycles(), gbc.Register, gbc.Cycles())
+	}
+	if ScreenHash(replay) != m.ScreenHash {
+		t.Error("screen hash differs")
+	}
+	if _, err := m.Play(append([]byte{1}, rom[1:]...)); err != ErrROMMismatch {
+		t.Errorf("Play on another ROM: %v, want ErrROMMismatch", err)
+	}
+}
+
+func TestReadTruncated(t *testing.T) {
+	var file bytes.Buffer
+	file.WriteString(MOVIE_MAGIC)
+	file.Write([]byte{MOVIE_VERSION, 0})
+	file.Write(make([]byte, 32+1))
+	file.Write([]byte{0xFF, 0xFF, 0xFF, 0xFF}) // a 4GB boot ROM
+	file.Write([]byte{1, 2, 3})
+	if _, err := Read(&file); err != io.ErrUnexpectedEOF {
+		t.Errorf("Read of a movie claiming a 4GB boot ROM: %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+func TestImportBK2(t *testing.T) {
+	rom := make([]byte, 0x8000)
+	var file bytes.Buffer
+	z := zip.NewWriter(&file)
+	w, _ := z.Create("Header.txt")
+	fmt.Fprintf(w, "MovieVersion BizHawk v2.0\nPlatform GB\nSHA1 %X\n", sha1.Sum(rom))
+	w, _ = z.Create("Input Log.txt")
+	fmt.Fprint(w, "[Input]\nLogKey:#P1 Up|P1 Down|P1 Left|P1 Right|P1 Start|P1 Select|P1 B|P1 A|P1 Power|\n")
+	fmt.Fprint(w, "|.........|\n|U......A.|\n|....S....|\n[/Input]\n")
+	z.Close()
+
+	m, err := Import("run.bk2", file.Bytes(), rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []hardware.BUTTON{0, hardware.BUTTON_UP | hardware.BUTTON_A, hardware.BUTTON_START}
+	if fmt.Sprint(m.Input) != fmt.Sprint(want) {
+		t.Errorf("input %v, want %v", m.Input, want)
+	}
+	if _, err := Import("run.bk2", file.Bytes(), append([]byte{1}, rom[1:]...)); err != ErrROMMismatch {
+		t.Errorf("import for another ROM: %v, want ErrROMMismatch", err)
+	}
+}
+
+func TestImportVBM(t *testing.T) {
+	// testdata/run.vbm is a four frame VisualBoyAdvance movie for a blank
+	// DMG ROM with header checksum E7, recorded from power on with one
+	// controller.
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "run.vbm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rom := make([]byte, 0x8000)
+	rom[0x14D] = 0xE7
+
+	m, err := Import("run.vbm", data, rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []hardware.BUTTON{
+		0,
+		hardware.BUTTON_A | hardware.BUTTON_UP,
+		hardware.BUTTON_START,
+		hardware.BUTTON_B | hardware.BUTTON_LEFT | hardware.BUTTON_DOWN,
+	}
+	if fmt.Sprint(m.Input) != fmt.Sprint(want) || m.Model != hardware.DMG {
+		t.Errorf("input %v on %v, want %v on DMG", m.Input, m.Model, want)
+	}
+
+	replay, err := m.Play(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Finish(replay)
+	var file bytes.Buffer
+	if _, err := m.WriteTo(&file); err != nil {
+		t.Fatal(err)
+	}
+	back, err := Import("run.gbm", file.Bytes(), rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(back.Input) != fmt.Sprint(want) || back.Model != m.Model || back.ScreenHash != m.ScreenHash {
+		t.Errorf("round trip gave input %v on %v, want %v on %v", back.Input, back.Model, want, m.Model)
+	}
+
+	other := append([]byte(nil), rom...)
+	other[0x14D] = 0
+	if _, err := Import("run.vbm", data, other); err != ErrROMMismatch {
+		t.Errorf("import for another ROM: %v, want ErrROMMismatch", err)
+	}
+	fromState := append([]byte(nil), data...)
+	fromState[0x14] = 0x01
+	if _, err := Import("run.vbm", fromState, rom); err != ErrUnsupported {
+		t.Errorf("import of a movie from a save state: %v, want ErrUnsupported", err)
+	}
+}
+
+func TestScreenHash(t *testing.T) {
+	rom := make([]byte, 0x8000)
+	copy(rom[0x100:], []byte{0x18, 0xFE})
+	gbc, err := hardware.New(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blank := ScreenHash(gbc)
+	// With the LCD off nothing in VRAM shows.
+	gbc.Poke(0x8000, 0xFF)
+	gbc.Poke(hardware.BGP, 0xE4)
+	if ScreenHash(gbc) != blank {
+		t.Error("hash changed with the LCD off")
+	}
+	gbc.Poke(hardware.LCDC, 0x91)
+	shown := ScreenHash(gbc)
+	if shown == blank {
+		t.Error("hash unchanged after turning the LCD on over a drawn tile")
+	}
+
+	green, err := hardware.New(rom, hardware.WithPalette(hardware.PocketGreen))
+	if err != nil {
+		t.Fatal(err)
+	}
+	green.Poke(0x8000, 0xFF)
+	green.Poke(hardware.BGP, 0xE4)
+	green.Poke(hardware.LCDC, 0x91)
+	if ScreenHash(green) != shown {
+		t.Error("hash depends on the DMG palette")
+	}
+}
@@ -0,0 +1,270 @@
+package profile
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/ifamakes/emu/pkg/hardware"
+)
+
+// Function identifies code by the bank and address it was called at.
+// Interrupt handlers are functions too, with Interrupt set.
+type Function struct {
+	Bank      int
+	Addr      uint16
+	Interrupt bool
+}
+
+// frame is a function on the emulated call stack.
+type frame struct {
+	fn Function
+	// site is where the caller was when it made the call.
+	site uint64
+	// sp is the stack slot holding the return address, 0x10000 for the
+	// function the run started in.
+	sp int
+}
+
+type cycleSample struct {
+	stack        []uint64 // leaf first, see site
+	cycles, insn uint64
+}
+
+// CycleProfile is a Tracer that attributes T-cycles to the instruction and
+// call stack that spent them. Calls are followed through CALL and RST, and
+// returns through anything that moves SP above a return address, so code
+// that drops its return address or resets SP stays balanced. Interrupt
+// handlers are roots of their own rather than children of whatever they
+// interrupted.
+type CycleProfile struct {
+	gbc  *hardware.GBC
+	next hardware.Tracer
+	// Names, if set, names functions in reports and pprof output.
+	Names func(fn Function) (string, bool)
+
+	stack      []frame
+	lastPC     uint16
+	lastBank   int
+	lastOp     byte
+	lastCycles uint64
+	started    bool
+	// interrupt is the handler dispatched to before the next instruction,
+	// interrupted the PC it was dispatched from and interruptAt the cycle
+	// count before the dispatch.
+	interrupt   *Function
+	interrupted uint16
+	interruptAt uint64
+
+	functions []Function
+	index     map[Function]uint64
+	samples   map[string]*cycleSample
+	key       []byte
+}
+
+// NewCycleProfile installs a CycleProfile as gbc's tracer, forwarding events
+// to any tracer that was already there.
+func NewCycleProfile(gbc *hardware.GBC) *CycleProfile {
+	p := &CycleProfile{
+		gbc:     gbc,
+		next:    gbc.Tracer(),
+		index:   map[Function]uint64{},
+		samples: map[string]*cycleSample{},
+	}
+	gbc.SetTracer(p)
+	return p
+}
+
+func (p *CycleProfile) Instruction(gbc *hardware.GBC, pc uint16, op byte) {
+	if p.next != nil {
+		p.next.Instruction(gbc, pc, op)
+	}
+	end := gbc.Cycles()
+	if !p.started {
+		p.started = true
+		fn := Function{Bank: gbc.BankOf(pc), Addr: pc}
+		if p.interrupt != nil {
+			fn, p.interrupt = *p.interrupt, nil
+		}
+		p.stack = append(p.stack, frame{fn: fn, sp: 0x10000})
+	} else {
+		if p.interrupt != nil {
+			// The dispatch is charged to the handler.
+			end = p.interruptAt
+		}
+		p.sample(end-p.lastCycles, 1)
+		p.follow(gbc, pc)
+	}
+	p.lastPC, p.lastBank, p.lastOp, p.lastCycles = pc, gbc.BankOf(pc), op, end
+}
+
+func (p *CycleProfile) MemoryAccess(addr uint16, value byte, write bool) {
+	if p.next != nil {
+		p.next.MemoryAccess(addr, value, write)
+	}
+}
+
+func (p *CycleProfile) Interrupt(vector uint16) {
+	if p.next != nil {
+		p.next.Interrupt(vector)
+	}
+	p.interrupt = &Function{Addr: vector, Interrupt: true}
+	p.interrupted, p.interruptAt = p.gbc.PC, p.gbc.Cycles()
+}
+
+// follow updates the call stack for the move from the last instruction to
+// pc. An interrupt dispatched in between is followed after the last
+// instruction, from where that instruction left PC and SP.
+func (p *CycleProfile) follow(gbc *hardware.GBC, pc uint16) {
+	sp := int(gbc.SP)
+	if p.interrupt != nil {
+		pc, sp = p.interrupted, sp+2
+	}
+	for len(p.stack) > 1 && p.stack[len(p.stack)-1].sp < sp {
+		p.stack = p.stack[:len(p.stack)-1]
+	}
+	if length, ok := callLength(p.lastOp); ok && pc != p.lastPC+length {
+		site := p.site(p.stack[len(p.stack)-1].fn)
+		p.stack = append(p.stack, frame{fn: Function{Bank: gbc.BankOf(pc), Addr: pc}, site: site, sp: sp})
+	}
+	if p.interrupt != nil {
+		site := p.site(p.stack[len(p.stack)-1].fn)
+		p.stack = append(p.stack, frame{fn: *p.interrupt, site: site, sp: int(gbc.SP)})
+		p.interrupt = nil
+	}
+}
+
+// callLength returns the length of op if it is a call or restart.
+func callLength(op byte) (uint16, bool) {
+	switch op {
+	case 0xC4, 0xCC, 0xCD, 0xD4, 0xDC:
+		return 3, true
+	case 0xC7, 0xCF, 0xD7, 0xDF, 0xE7, 0xEF, 0xF7, 0xFF:
+		return 1, true
+	}
+	return 0, false
+}
+
+// site packs fn and the bank and address of the last instruction, which
+// ran in fn, into one number: the index of fn in functions << 32 | bank << 16
+// | address.
+func (p *CycleProfile) site(fn Function) uint64 {
+	i, ok := p.index[fn]
+	if !ok {
+		i = uint64(len(p.functions))
+		p.index[fn] = i
+		p.functions = append(p.functions, fn)
+	}
+	return i<<32 | uint64(p.lastBank&0xFFFF)<<16 | uint64(p.lastPC)
+}
+
+// sample charges cycles and instructions to the last instruction and the
+// stack it ran on.
+func (p *CycleProfile) sample(cycles, insn uint64) {
+	top := len(p.stack) - 1
+	leaf := p.site(p.stack[top].fn)
+	p.key = appendSite(p.key[:0], leaf)
+	for i := top; i > 0 && !p.stack[i].fn.Interrupt; i-- {
+		p.key = appendSite(p.key, p.stack[i].site)
+	}
+	s := p.samples[string(p.key)]
+	if s == nil {
+		s = &cycleSample{}
+		for i := 0; i < len(p.key); i += 8 {
+			s.stack = append(s.stack, binary.BigEndian.Uint64(p.key[i:]))
+		}
+		p.samples[string(p.key)] = s
+	}
+	s.cycles += cycles
+	s.insn += insn
+}
+
+// flush charges the cycles since the last instruction started, which the
+// next call to Instruction would otherwise charge.
+func (p *CycleProfile) flush() {
+	if !p.started {
+		return
+	}
+	now := p.gbc.Cycles()
+	p.sample(now-p.lastCycles, 0)
+	p.lastCycles = now
+}
+
+func appendSite(b []byte, site uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], site)
+	return append(b, buf[:]...)
+}
+
+// Name returns the name of fn given by Names, or its bank and address.
+func (p *CycleProfile) Name(fn Function) string {
+	if p.Names != nil {
+		if name, ok := p.Names(fn); ok {
+			return name
+		}
+	}
+	if fn.Interrupt {
+		return fmt.Sprintf("interrupt %04X", fn.Addr)
+	}
+	return fmt.Sprintf("%02X:%04X", fn.Bank, fn.Addr)
+}
+
+// FunctionCycles is the time spent in a function: Flat in its own code and
+// Cum including the functions it called.
+type FunctionCycles struct {
+	Function
+	Flat, Cum uint64
+}
+
+// Functions returns the cycles of every function seen, most flat cycles
+// first.
+func (p *CycleProfile) Functions() []FunctionCycles {
+	p.flush()
+	totals := make([]FunctionCycles, len(p.functions))
+	for i, fn := range p.functions {
+		totals[i].Function = fn
+	}
+	seen := make([]bool, len(p.functions))
+	for _, s := range p.samples {
+		totals[s.stack[0]>>32].Flat += s.cycles
+		for _, site := range s.stack {
+			if i := site >> 32; !seen[i] {
+				seen[i] = true
+				totals[i].Cum += s.cycles
+			}
+		}
+		for _, site := range s.stack {
+			seen[site>>32] = false
+		}
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		if totals[i].Flat != totals[j].Flat {
+			return totals[i].Flat > totals[j].Flat
+		}
+		return totals[i].Cum > totals[j].Cum
+	})
+	return totals
+}
+
+// WriteTop writes the n functions with the most flat cycles as a table.
+func (p *CycleProfile) WriteTop(w io.Writer, n int) error {
+	var total uint64
+	for _, s := range p.samples {
+		total += s.cycles
+	}
+	if total == 0 {
+		total = 1
+	}
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "FLAT\tFLAT%\tCUM\tCUM%\tFUNCTION\t")
+	for i, f := range p.Functions() {
+		if i == n {
+			break
+		}
+		fmt.Fprintf(tw, "%d\t%.1f%%\t%d\t%.1f%%\t%s\t\n",
+			f.Flat, 100*float64(f.Flat)/float64(total), f.Cum, 100*float64(f.Cum)/float64(total), p.Name(f.Function))
+	}
+	return tw.Flush()
+}
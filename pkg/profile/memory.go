@@ -0,0 +1,222 @@
+// Package profile collects statistics about where a ROM spends its time and
+// which memory it touches. It is the engine behind cmd/gbprof.
+package profile
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+
+	"github.com/ifamakes/emu/pkg/hardware"
+)
+
+// Counts is how often a byte of memory was accessed. Opcode and operand
+// fetches count as reads, and the first byte of each instruction run also
+// counts as an execute.
+type Counts struct {
+	Reads, Writes, Executes uint64
+}
+
+// MemoryProfile is a Tracer that counts accesses to every address. ROM is
+// counted per bank, so the same CPU address in different banks is kept
+// apart; everything from 0x8000 up is counted by CPU address.
+type MemoryProfile struct {
+	gbc  *hardware.GBC
+	next hardware.Tracer
+	rom  [][]Counts
+	high [0x8000]Counts
+}
+
+// NewMemoryProfile installs a MemoryProfile as gbc's tracer, forwarding
+// events to any tracer that was already there.
+func NewMemoryProfile(gbc *hardware.GBC) *MemoryProfile {
+	p := &MemoryProfile{gbc: gbc, next: gbc.Tracer()}
+	gbc.SetTracer(p)
+	return p
+}
+
+func (p *MemoryProfile) counts(addr uint16) *Counts {
+	if addr >= 0x8000 {
+		return &p.high[addr-0x8000]
+	}
+	bank := p.gbc.BankOf(addr)
+	for len(p.rom) <= bank {
+		p.rom = append(p.rom, nil)
+	}
+	if p.rom[bank] == nil {
+		p.rom[bank] = make([]Counts, 0x4000)
+	}
+	return &p.rom[bank][addr&0x3FFF]
+}
+
+func (p *MemoryProfile) Instruction(gbc *hardware.GBC, pc uint16, op byte) {
+	if p.next != nil {
+		p.next.Instruction(gbc, pc, op)
+	}
+	p.counts(pc).Executes++
+}
+
+func (p *MemoryProfile) MemoryAccess(addr uint16, value byte, write bool) {
+	if p.next != nil {
+		p.next.MemoryAccess(addr, value, write)
+	}
+	if write {
+		// Writes below 0x8000 go to the MBC, but are counted against the
+		// ROM they hit all the same.
+		p.counts(addr).Writes++
+	} else {
+		p.counts(addr).Reads++
+	}
+}
+
+func (p *MemoryProfile) Interrupt(vector uint16) {
+	if p.next != nil {
+		p.next.Interrupt(vector)
+	}
+}
+
+// ROM returns the counts of ROM bank n, indexed by offset into the bank, or
+// nil if nothing in it was touched.
+func (p *MemoryProfile) ROM(bank int) []Counts {
+	if bank < len(p.rom) {
+		return p.rom[bank]
+	}
+	return nil
+}
+
+// At returns the counts of addr, 0x8000 or above.
+func (p *MemoryProfile) At(addr uint16) Counts {
+	if addr < 0x8000 {
+		return Counts{}
+	}
+	return p.high[addr-0x8000]
+}
+
+// bankBase is where ROM bank n is seen by the CPU.
+func bankBase(bank int) uint16 {
+	if bank == 0 {
+		return 0x0000
+	}
+	return 0x4000
+}
+
+// Range is a run of bytes in a ROM bank, Start to End inclusive.
+type Range struct {
+	Bank       int
+	Start, End uint16
+}
+
+func (r Range) Len() int { return int(r.End-r.Start) + 1 }
+
+// Unused returns the runs of at least min bytes in ROM bank n that were
+// never read or executed: free space, or code and data the run didn't
+// reach.
+func (p *MemoryProfile) Unused(bank, min int) []Range {
+	counts := p.ROM(bank)
+	base := bankBase(bank)
+	var ranges []Range
+	start := -1
+	for i := 0; i <= 0x4000; i++ {
+		used := i == 0x4000 || (counts != nil && counts[i].Reads|counts[i].Executes != 0)
+		if !used && start < 0 {
+			start = i
+		}
+		if used && start >= 0 {
+			if i-start >= min {
+				ranges = append(ranges, Range{bank, base + uint16(start), base + uint16(i-1)})
+			}
+			start = -1
+		}
+	}
+	return ranges
+}
+
+// Hot is an address in ROM and how often it was accessed.
+type Hot struct {
+	Bank int
+	Addr uint16
+	Counts
+}
+
+// Hottest returns the n most executed ROM addresses, most executed first.
+func (p *MemoryProfile) Hottest(n int) []Hot {
+	var hot []Hot
+	for bank, counts := range p.rom {
+		for i, c := range counts {
+			if c.Executes > 0 {
+				hot = append(hot, Hot{bank, bankBase(bank) + uint16(i), c})
+			}
+		}
+	}
+	sort.Slice(hot, func(i, j int) bool {
+		if hot[i].Executes != hot[j].Executes {
+			return hot[i].Executes > hot[j].Executes
+		}
+		if hot[i].Bank != hot[j].Bank {
+			return hot[i].Bank < hot[j].Bank
+		}
+		return hot[i].Addr < hot[j].Addr
+	})
+	if len(hot) > n {
+		hot = hot[:n]
+	}
+	return hot
+}
+
+// HEATMAP_WIDTH is the width in bytes, and pixels, of every heatmap.
+const HEATMAP_WIDTH = 128
+
+// ROMHeatmap draws ROM bank n, see Heatmap.
+func (p *MemoryProfile) ROMHeatmap(bank int) *image.RGBA {
+	counts := p.ROM(bank)
+	if counts == nil {
+		counts = make([]Counts, 0x4000)
+	}
+	return Heatmap(counts)
+}
+
+// WRAMHeatmap draws 0xC000-0xDFFF, see Heatmap. On a CGB all WRAM banks are
+// counted together at the addresses they are switched in at.
+func (p *MemoryProfile) WRAMHeatmap() *image.RGBA {
+	return Heatmap(p.high[0xC000-0x8000 : 0xE000-0x8000])
+}
+
+// HRAMHeatmap draws 0xFF80-0xFFFE, see Heatmap.
+func (p *MemoryProfile) HRAMHeatmap() *image.RGBA {
+	return Heatmap(p.high[0xFF80-0x8000 : 0xFFFF-0x8000])
+}
+
+// Heatmap draws one pixel per byte, HEATMAP_WIDTH bytes to a row. Writes
+// are red, reads green and executes blue, each on a log scale up to the
+// most accessed byte, so code shows as cyan, data as green, variables as
+// yellow and untouched bytes as black.
+func Heatmap(counts []Counts) *image.RGBA {
+	var max Counts
+	for _, c := range counts {
+		if c.Reads > max.Reads {
+			max.Reads = c.Reads
+		}
+		if c.Writes > max.Writes {
+			max.Writes = c.Writes
+		}
+		if c.Executes > max.Executes {
+			max.Executes = c.Executes
+		}
+	}
+	scale := func(n, max uint64) uint8 {
+		if n == 0 {
+			return 0
+		}
+		// Anything touched at all is bright enough to see.
+		return uint8(48 + 207*math.Log1p(float64(n))/math.Log1p(float64(max)))
+	}
+	rows := (len(counts) + HEATMAP_WIDTH - 1) / HEATMAP_WIDTH
+	img := image.NewRGBA(image.Rect(0, 0, HEATMAP_WIDTH, rows))
+	for i, c := range counts {
+		img.SetRGBA(i%HEATMAP_WIDTH, i/HEATMAP_WIDTH, color.RGBA{
+			scale(c.Writes, max.Writes), scale(c.Reads, max.Reads), scale(c.Executes, max.Executes), 0xFF,
+		})
+	}
+	return img
+}
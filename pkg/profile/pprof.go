@@ -0,0 +1,168 @@
+package profile
+
+import (
+	"compress/gzip"
+	"io"
+	"sort"
+)
+
+// The pprof format is a gzipped protocol buffer, see
+// github.com/google/pprof/proto/profile.proto. The few messages needed are
+// encoded by hand to avoid the dependency.
+
+// Field numbers of the Profile message and its children.
+const (
+	pbProfileSampleType  = 1
+	pbProfileSample      = 2
+	pbProfileLocation    = 4
+	pbProfileFunction    = 5
+	pbProfileStringTable = 6
+	pbProfilePeriodType  = 11
+	pbProfilePeriod      = 12
+	pbProfileDefaultType = 14
+
+	pbValueTypeType = 1
+	pbValueTypeUnit = 2
+
+	pbSampleLocationID = 1
+	pbSampleValue      = 2
+
+	pbLocationID      = 1
+	pbLocationAddress = 3
+	pbLocationLine    = 4
+
+	pbLineFunctionID = 1
+
+	pbFunctionID         = 1
+	pbFunctionName       = 2
+	pbFunctionSystemName = 3
+	pbFunctionFilename   = 4
+)
+
+type protoBuffer struct {
+	b []byte
+}
+
+func (p *protoBuffer) varint(v uint64) {
+	for v >= 0x80 {
+		p.b = append(p.b, byte(v)|0x80)
+		v >>= 7
+	}
+	p.b = append(p.b, byte(v))
+}
+
+func (p *protoBuffer) uint64(field int, v uint64) {
+	p.varint(uint64(field) << 3)
+	p.varint(v)
+}
+
+func (p *protoBuffer) bytes(field int, b []byte) {
+	p.varint(uint64(field)<<3 | 2)
+	p.varint(uint64(len(b)))
+	p.b = append(p.b, b...)
+}
+
+// message encodes a nested message written by f.
+func (p *protoBuffer) message(field int, f func(m *protoBuffer)) {
+	var m protoBuffer
+	f(&m)
+	p.bytes(field, m.b)
+}
+
+// packed encodes a repeated integer field.
+func (p *protoBuffer) packed(field int, vs []uint64) {
+	var m protoBuffer
+	for _, v := range vs {
+		m.varint(v)
+	}
+	p.bytes(field, m.b)
+}
+
+// WritePprof writes the profile in pprof format, with samples of T-cycles
+// and instructions. Locations are addresses with the bank in bits 16 and
+// up, and rom names the file functions are reported to be in.
+func (p *CycleProfile) WritePprof(w io.Writer, rom string) error {
+	p.flush()
+	strings := map[string]uint64{"": 0}
+	table := []string{""}
+	str := func(s string) uint64 {
+		i, ok := strings[s]
+		if !ok {
+			i = uint64(len(table))
+			strings[s] = i
+			table = append(table, s)
+		}
+		return i
+	}
+
+	// Cycles come last, which older pprof versions show by default.
+	var out protoBuffer
+	for _, t := range [][2]string{{"instructions", "count"}, {"cycles", "count"}} {
+		t := t
+		out.message(pbProfileSampleType, func(m *protoBuffer) {
+			m.uint64(pbValueTypeType, str(t[0]))
+			m.uint64(pbValueTypeUnit, str(t[1]))
+		})
+	}
+
+	// Locations are numbered from 1 in the order they are first seen, in
+	// samples sorted by stack so the output is the same from run to run.
+	keys := make([]string, 0, len(p.samples))
+	for k := range p.samples {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	locations := map[uint64]uint64{}
+	var sites []uint64
+	for _, k := range keys {
+		s := p.samples[k]
+		ids := make([]uint64, len(s.stack))
+		for i, site := range s.stack {
+			id, ok := locations[site]
+			if !ok {
+				id = uint64(len(sites) + 1)
+				locations[site] = id
+				sites = append(sites, site)
+			}
+			ids[i] = id
+		}
+		out.message(pbProfileSample, func(m *protoBuffer) {
+			m.packed(pbSampleLocationID, ids)
+			m.packed(pbSampleValue, []uint64{s.insn, s.cycles})
+		})
+	}
+	for i, site := range sites {
+		out.message(pbProfileLocation, func(m *protoBuffer) {
+			m.uint64(pbLocationID, uint64(i+1))
+			m.uint64(pbLocationAddress, site&0xFFFFFFFF)
+			m.message(pbLocationLine, func(l *protoBuffer) {
+				l.uint64(pbLineFunctionID, site>>32+1)
+			})
+		})
+	}
+	for i, fn := range p.functions {
+		name := str(p.Name(fn))
+		file := str(rom)
+		out.message(pbProfileFunction, func(m *protoBuffer) {
+			m.uint64(pbFunctionID, uint64(i+1))
+			m.uint64(pbFunctionName, name)
+			m.uint64(pbFunctionSystemName, name)
+			m.uint64(pbFunctionFilename, file)
+		})
+	}
+	out.message(pbProfilePeriodType, func(m *protoBuffer) {
+		m.uint64(pbValueTypeType, str("cycles"))
+		m.uint64(pbValueTypeUnit, str("count"))
+	})
+	out.uint64(pbProfilePeriod, 1)
+	out.uint64(pbProfileDefaultType, str("cycles"))
+	for _, s := range table {
+		out.bytes(pbProfileStringTable, []byte(s))
+	}
+
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(out.b); err != nil {
+		return err
+	}
+	return gz.Close()
+}
@@ -0,0 +1,105 @@
+package profile
+
+import (
+	"testing"
+
+	"github.com/ifamakes/emu/pkg/hardware"
+)
+
+func testROM(code ...byte) []byte {
+	rom := make([]byte, 0x8000)
+	copy(rom[0x100:], code)
+	return rom
+}
+
+func TestMemoryProfile(t *testing.T) {
+	// loop: LD A, (C000); LD (C001), A; JR loop
+	gbc, err := hardware.New(testROM(0xFA, 0x00, 0xC0, 0xEA, 0x01, 0xC0, 0x18, 0xF8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewMemoryProfile(gbc)
+	for i := 0; i < 30; i++ {
+		gbc.Step()
+	}
+	if c := p.ROM(0)[0x100]; c.Executes != 10 || c.Reads != 10 {
+		t.Errorf("0100 counts %+v, want 10 executes and reads", c)
+	}
+	if c := p.At(0xC000); c.Reads != 10 || c.Writes != 0 {
+		t.Errorf("C000 counts %+v, want 10 reads", c)
+	}
+	if c := p.At(0xC001); c.Writes != 10 {
+		t.Errorf("C001 counts %+v, want 10 writes", c)
+	}
+	want := []Range{{0, 0x0000, 0x00FF}, {0, 0x0108, 0x3FFF}}
+	if got := p.Unused(0, 16); len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Unused = %v, want %v", got, want)
+	}
+}
+
+func TestCycleProfile(t *testing.T) {
+	// loop: CALL 0110; JR loop; 0110: NOP; RET
+	rom := testROM(0xCD, 0x10, 0x01, 0x18, 0xFB)
+	copy(rom[0x110:], []byte{0x00, 0xC9})
+	gbc, err := hardware.New(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewCycleProfile(gbc)
+	for i := 0; i < 40; i++ {
+		gbc.Step()
+	}
+	// Each pass is CALL 24, NOP 4, RET 16 and JR 12 cycles.
+	want := map[Function][2]uint64{
+		{Addr: 0x0100}: {10 * (24 + 12), 10 * 56},
+		{Addr: 0x0110}: {10 * (4 + 16), 10 * 20},
+	}
+	got := p.Functions()
+	if len(got) != len(want) {
+		t.Fatalf("got %d functions, want %d", len(got), len(want))
+	}
+	for _, f := range got {
+		if w := want[f.Function]; f.Flat != w[0] || f.Cum != w[1] {
+			t.Errorf("%s: flat %d cum %d, want %d and %d", p.Name(f.Function), f.Flat, f.Cum, w[0], w[1])
+		}
+	}
+}
+
+func TestCycleProfileInterrupt(t *testing.T) {
+	// EI; loop: CALL 0110; JR loop; 0110: NOP; RET; 0050: NOP; RETI
+	rom := testROM(0xFB, 0xCD, 0x10, 0x01, 0x18, 0xFB)
+	copy(rom[0x110:], []byte{0x00, 0xC9})
+	copy(rom[0x50:], []byte{0x00, 0xD9})
+	gbc, err := hardware.New(rom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewCycleProfile(gbc)
+	gbc.Poke(hardware.IE, 0x04)
+	gbc.Step() // EI
+	gbc.Step() // CALL
+	// Request the timer interrupt with the CPU just into 0110.
+	gbc.Poke(0xFF0F, 0x04)
+	for i := 0; i < 5; i++ {
+		gbc.Step()
+	}
+	if gbc.PC != 0x101 {
+		t.Fatalf("PC %04X after the handler, RET and JR, want 0101", gbc.PC)
+	}
+	// The handler is a root of its own: its dispatch 20, NOP 4 and RETI 16
+	// cycles aren't part of 0110 or the loop.
+	want := map[Function][2]uint64{
+		{Addr: 0x0100}:                  {4 + 24 + 12, 4 + 24 + 12 + 20},
+		{Addr: 0x0110}:                  {4 + 16, 4 + 16},
+		{Addr: 0x0050, Interrupt: true}: {20 + 4 + 16, 20 + 4 + 16},
+	}
+	got := p.Functions()
+	if len(got) != len(want) {
+		t.Fatalf("got %d functions, want %d", len(got), len(want))
+	}
+	for _, f := range got {
+		if w := want[f.Function]; f.Flat != w[0] || f.Cum != w[1] {
+			t.Errorf("%s: flat %d cum %d, want %d and %d", p.Name(f.Function), f.Flat, f.Cum, w[0], w[1])
+		}
+	}
+}
@@ -0,0 +1,212 @@
+// Package symbols reads the symbol files written by RGBDS (rgblink -n) and
+// wla-dx (wlalink -S), so tools can show Main.loop instead of 01:4123.
+package symbols
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var ErrSyntax = errors.New("symbol line is not BANK:ADDR NAME")
+
+// Symbol is a label and where it is: Bank only tells apart the switchable
+// ROM banks at 0x4000-0x7FFF, everything else is looked up by address.
+type Symbol struct {
+	Bank int
+	Addr uint16
+	Name string
+}
+
+// space is the bank that matters for addr: the ROM bank in 0x4000-0x7FFF
+// and 0 everywhere else, so WRAM and SRAM labels in different banks at the
+// same address share a name, the first in the file.
+func space(bank int, addr uint16) int {
+	if addr >= 0x4000 && addr < 0x8000 {
+		return bank
+	}
+	return 0
+}
+
+// region numbers the parts of the memory map an offset from a label can
+// reach across: ROM0, ROMX, VRAM, SRAM, WRAM, echo to IO, and HRAM.
+func region(addr uint16) int {
+	switch {
+	case addr >= 0xFF80:
+		return 8
+	case addr < 0x8000:
+		return int(addr >> 14)
+	}
+	return int(addr >> 13)
+}
+
+// Table is a loaded symbol file. A nil *Table has no symbols.
+type Table struct {
+	symbols []Symbol // by space then address, file order at the same place
+	at      map[int]string
+	names   map[string]Symbol
+}
+
+func key(bank int, addr uint16) int {
+	return space(bank, addr)<<16 | int(addr)
+}
+
+// Parse reads a symbol file. Lines are "BANK:ADDR NAME" in hex, with ';'
+// starting a comment. In wla-dx files only the [labels] section is read.
+func Parse(r io.Reader) (*Table, error) {
+	t := &Table{at: map[int]string{}, names: map[string]Symbol{}}
+	scanner := bufio.NewScanner(r)
+	labels := true
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, ';'); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			labels = line == "[labels]"
+			continue
+		}
+		if !labels {
+			continue
+		}
+		fields := strings.Fields(line)
+		colon := strings.IndexByte(fields[0], ':')
+		if len(fields) != 2 || colon < 0 {
+			return nil, fmt.Errorf("line %d: %w", n, ErrSyntax)
+		}
+		bank, err := strconv.ParseUint(fields[0][:colon], 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, ErrSyntax)
+		}
+		addr, err := strconv.ParseUint(fields[0][colon+1:], 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, ErrSyntax)
+		}
+		t.add(Symbol{Bank: int(bank), Addr: uint16(addr), Name: fields[1]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(t.symbols, func(i, j int) bool {
+		return key(t.symbols[i].Bank, t.symbols[i].Addr) < key(t.symbols[j].Bank, t.symbols[j].Addr)
+	})
+	return t, nil
+}
+
+func (t *Table) add(s Symbol) {
+	t.symbols = append(t.symbols, s)
+	if _, ok := t.at[key(s.Bank, s.Addr)]; !ok {
+		t.at[key(s.Bank, s.Addr)] = s.Name
+	}
+	if _, ok := t.names[s.Name]; !ok {
+		t.names[s.Name] = s
+	}
+}
+
+// Load reads the symbol file name.
+func Load(name string) (*Table, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	t, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return t, nil
+}
+
+// Open loads sym if it is set, or else the .sym file next to rom if there
+// is one. With neither it returns a nil Table and no error.
+func Open(sym, rom string) (*Table, error) {
+	if sym != "" {
+		return Load(sym)
+	}
+	t, err := Load(strings.TrimSuffix(rom, filepath.Ext(rom)) + ".sym")
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return t, err
+}
+
+// Symbols returns every symbol in address order.
+func (t *Table) Symbols() []Symbol {
+	if t == nil {
+		return nil
+	}
+	return t.symbols
+}
+
+// Lookup finds a symbol by name.
+func (t *Table) Lookup(name string) (Symbol, bool) {
+	if t == nil {
+		return Symbol{}, false
+	}
+	s, ok := t.names[name]
+	return s, ok
+}
+
+// Name returns the label at addr with ROM bank mapped at 0x4000.
+func (t *Table) Name(bank int, addr uint16) (string, bool) {
+	if t == nil {
+		return "", false
+	}
+	name, ok := t.at[key(bank, addr)]
+	return name, ok
+}
+
+// Names returns Name for a fixed ROM bank, in the form disasm's
+// Instruction.Format takes.
+func (t *Table) Names(bank int) func(addr uint16) (string, bool) {
+	return func(addr uint16) (string, bool) { return t.Name(bank, addr) }
+}
+
+// Describe names addr by the closest label at or before it in the same
+// part of memory, like "Main.loop+3".
+func (t *Table) Describe(bank int, addr uint16) (string, bool) {
+	if t == nil {
+		return "", false
+	}
+	k := key(bank, addr)
+	i := sort.Search(len(t.symbols), func(i int) bool {
+		return key(t.symbols[i].Bank, t.symbols[i].Addr) > k
+	}) - 1
+	if i < 0 {
+		return "", false
+	}
+	s := t.symbols[i]
+	if space(s.Bank, s.Addr) != space(bank, addr) || region(s.Addr) != region(addr) {
+		return "", false
+	}
+	name, _ := t.Name(s.Bank, s.Addr)
+	if s.Addr == addr {
+		return name, true
+	}
+	return fmt.Sprintf("%s+%d", name, addr-s.Addr), true
+}
+
+// Visible returns the labels the CPU sees with ROM bank mapped at 0x4000,
+// by address.
+func (t *Table) Visible(bank int) map[uint16]string {
+	names := map[uint16]string{}
+	for _, s := range t.Symbols() {
+		if s.Addr >= 0x4000 && s.Addr < 0x8000 && s.Bank != bank {
+			continue
+		}
+		if _, ok := names[s.Addr]; !ok {
+			names[s.Addr], _ = t.Name(s.Bank, s.Addr)
+		}
+	}
+	return names
+}
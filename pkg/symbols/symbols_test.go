@@ -0,0 +1,98 @@
+package symbols
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const rgbds = `; File generated by rgblink
+00:0150 Start
+00:0150 Start.entry
+01:4000 Main
+01:4010 Main.loop
+02:4000 Music
+00:c000 wFrame
+00:ff80 hJoypad
+`
+
+const wla = `; this file was created with wlalink
+[information]
+00000000 00000000 1
+[labels]
+0000:0150 main
+0001:4000 bank1
+[definitions]
+00000003 _sizeof_main
+`
+
+func TestParse(t *testing.T) {
+	table, err := Parse(strings.NewReader(rgbds))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []struct {
+		bank int
+		addr uint16
+		want string
+		ok   bool
+	}{
+		{0, 0x0150, "Start", true},
+		{1, 0x0150, "Start", true},
+		{1, 0x4010, "Main.loop", true},
+		{2, 0x4010, "", false},
+		{2, 0x4000, "Music", true},
+		{5, 0xC000, "wFrame", true},
+	}
+	for _, n := range names {
+		if got, ok := table.Name(n.bank, n.addr); got != n.want || ok != n.ok {
+			t.Errorf("Name(%d, %04X) = %q, %t, want %q, %t", n.bank, n.addr, got, ok, n.want, n.ok)
+		}
+	}
+
+	describe := []struct {
+		bank int
+		addr uint16
+		want string
+	}{
+		{1, 0x4013, "Main.loop+3"},
+		{1, 0x4000, "Main"},
+		{2, 0x4100, "Music+256"},
+		{3, 0x4000, ""},
+		{0, 0xC123, "wFrame+291"},
+		{0, 0xFF40, ""},
+		{0, 0x0100, ""},
+	}
+	for _, d := range describe {
+		if got, _ := table.Describe(d.bank, d.addr); got != d.want {
+			t.Errorf("Describe(%d, %04X) = %q, want %q", d.bank, d.addr, got, d.want)
+		}
+	}
+
+	if s, ok := table.Lookup("Main.loop"); !ok || s != (Symbol{1, 0x4010, "Main.loop"}) {
+		t.Errorf("Lookup(Main.loop) = %v, %t", s, ok)
+	}
+	if v := table.Visible(2); v[0x4000] != "Music" || v[0x4010] != "" || v[0x0150] != "Start" {
+		t.Errorf("Visible(2) = %v", v)
+	}
+
+	table, err = Parse(strings.NewReader(wla))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(table.Symbols()) != 2 {
+		t.Errorf("wla-dx: got %v, want only the [labels] section", table.Symbols())
+	}
+	if name, _ := table.Name(1, 0x4000); name != "bank1" {
+		t.Errorf("wla-dx: Name(1, 4000) = %q", name)
+	}
+
+	if _, err := Parse(strings.NewReader("00:0150 Start\nStart\n")); !errors.Is(err, ErrSyntax) {
+		t.Errorf("bad line: got %v, want ErrSyntax", err)
+	}
+
+	var none *Table
+	if _, ok := none.Describe(0, 0x150); ok {
+		t.Error("nil table described an address")
+	}
+}